@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// completionCmd represents the completion command
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generate a shell completion script",
+	Long: `Generate a shell completion script for registry.
+
+Participant reference numbers are completed using the
+participants held by the registry server.
+
+To load completions for the current bash session:
+
+  source <(registry completion bash)
+
+To load completions for every zsh session:
+
+  registry completion zsh > "${fpath[1]}/_registry"`,
+	ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactValidArgs(1)(cmd, args); err != nil {
+			return usageError{err}
+		}
+		return nil
+	},
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return cmd.Root().GenBashCompletion(os.Stdout)
+		case "zsh":
+			return cmd.Root().GenZshCompletion(os.Stdout)
+		case "fish":
+			return cmd.Root().GenFishCompletion(os.Stdout, true)
+		default:
+			return cmd.Root().GenPowerShellCompletion(os.Stdout)
+		}
+	},
+}
+
+// init the subcommand and add it to the root
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
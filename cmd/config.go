@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// configuration keys, these are used in the
+// config file and, upper-cased with the env prefix,
+// as environment variables (e.g. REGISTRY_GRPC_PORT)
+const (
+	cfgGRPCPort      = "grpc_port"
+	cfgLogFile       = "log_file"
+	cfgDrainTimeout  = "drain_timeout"
+	cfgServerAddress = "server_address"
+	cfgOutput        = "output"
+	cfgDefaultRegion = "default_region"
+	cfgPostcodes     = "postcode_formats"
+	cfgIDPattern     = "id_pattern"
+	cfgPseudonymKey  = "pseudonym_key_file"
+	cfgErasureKey    = "erasure_key_file"
+	cfgRetention     = "retention_rules"
+	cfgRetentionRun  = "retention_interval"
+	cfgWebhooks      = "webhooks"
+	cfgWebhookQueue  = "webhook_queue_dir"
+	cfgOutboxSinks   = "outbox_sinks"
+	cfgOutboxFile    = "outbox_file"
+	cfgIdempotency   = "idempotency_window"
+	cfgHistoryLimit  = "history_limit"
+)
+
+// envPrefix is prepended to configuration keys
+// when reading them from environment variables.
+const envPrefix = "REGISTRY"
+
+// command line arguments
+var (
+	configFile *string // config file to read settings from
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the registry configuration",
+	Long: `Inspect the registry configuration.
+
+Settings are layered, with later sources taking precedence:
+* defaults
+* the config file (YAML or TOML)
+* REGISTRY_* environment variables
+* command line flags
+
+By default, the config file is searched for as registry.yaml
+or registry.toml in the current directory and then in
+$HOME/.registry. Use --config to specify a file.`,
+}
+
+// configPrintCmd represents the config print command
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the effective configuration",
+	Long: `Print the effective configuration as YAML.
+
+This is the result of merging the defaults, config file,
+environment variables and flags. Secrets, e.g. webhook
+secrets and passwords in sink URLs, are redacted.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printConfig()
+	},
+}
+
+// init the command line arguments and add the subcommand to the root
+func init() {
+	cobra.OnInitialize(initConfig)
+	viper.SetDefault(cfgGRPCPort, DefaultgRPCport)
+	viper.SetDefault(cfgLogFile, DefaultLogFile)
+	viper.SetDefault(cfgDrainTimeout, DefaultDrainTimeout.String())
+	viper.SetDefault(cfgDefaultRegion, DefaultRegion)
+	viper.SetDefault(cfgIDPattern, "")
+	viper.SetDefault(cfgPseudonymKey, "")
+	viper.SetDefault(cfgErasureKey, "")
+	viper.SetDefault(cfgRetentionRun, DefaultRetentionInterval.String())
+	viper.SetDefault(cfgWebhookQueue, DefaultWebhookQueueDir)
+	viper.SetDefault(cfgOutboxFile, DefaultOutboxFile)
+	viper.SetDefault(cfgIdempotency, DefaultIdempotencyWindow.String())
+	viper.SetDefault(cfgHistoryLimit, DefaultHistoryLimit)
+	viper.SetDefault(cfgServerAddress, fmt.Sprintf("%s:%s", DefaultServerAddress, DefaultgRPCport))
+	configFile = rootCmd.PersistentFlags().String("config", "", "config file (default is ./registry.yaml or $HOME/.registry/registry.yaml)")
+	configCmd.AddCommand(configPrintCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// initConfig will set up the config file
+// and environment variable sources.
+func initConfig() {
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.AutomaticEnv()
+	for _, key := range flagKeys {
+		viper.BindEnv(key)
+	}
+	if *configFile != "" {
+		viper.SetConfigFile(*configFile)
+	} else {
+		viper.SetConfigName("registry")
+		viper.AddConfigPath(".")
+		if home, err := os.UserHomeDir(); err == nil {
+			viper.AddConfigPath(fmt.Sprintf("%s/.registry", home))
+		}
+	}
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			fmt.Fprintf(os.Stderr, "could not read config file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// flagKeys maps command line flags to the
+// configuration keys they can set.
+var flagKeys = map[string]string{
+	"grpcPort":          cfgGRPCPort,
+	"logFile":           cfgLogFile,
+	"drainTimeout":      cfgDrainTimeout,
+	"serverAddress":     cfgServerAddress,
+	"output":            cfgOutput,
+	"defaultRegion":     cfgDefaultRegion,
+	"idPattern":         cfgIDPattern,
+	"pseudonymKeyFile":  cfgPseudonymKey,
+	"erasureKeyFile":    cfgErasureKey,
+	"retentionInterval": cfgRetentionRun,
+	"webhookQueueDir":   cfgWebhookQueue,
+	"outboxFile":        cfgOutboxFile,
+	"idempotencyWindow": cfgIdempotency,
+	"historyLimit":      cfgHistoryLimit,
+}
+
+// bindFlags will bind the command line flags of the
+// command being run to their configuration keys, so
+// that the keys can also be set by the config file
+// or environment. This is done once the command is
+// known as commands share flag names.
+func bindFlags(cmd *cobra.Command) error {
+	for flag, key := range flagKeys {
+		if f := cmd.Flags().Lookup(flag); f != nil {
+			if err := viper.BindPFlag(key, f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// redacted replaces secrets in the printed configuration.
+const redacted = "REDACTED"
+
+// secretKeys are the words in configuration keys
+// whose values are secrets.
+var secretKeys = []string{"secret", "token", "password", "passwd", "credential"}
+
+// printConfig will write the effective configuration
+// to STDOUT as YAML, with secrets redacted.
+func printConfig() error {
+	settings := map[string]interface{}{}
+	for _, key := range viper.AllKeys() {
+		settings[key] = redactConfig(key, viper.Get(key))
+	}
+	out, err := yaml.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	if used := viper.ConfigFileUsed(); used != "" {
+		fmt.Fprintf(os.Stdout, "# config file: %s\n", used)
+	}
+	fmt.Fprint(os.Stdout, string(out))
+	return nil
+}
+
+// redactConfig will return the value of a configuration
+// key with any secrets redacted, including the secrets
+// nested in lists of settings (e.g. webhook subscriptions)
+// and the passwords in URLs.
+func redactConfig(key string, value interface{}) interface{} {
+	lower := strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(lower, secret) {
+			if value == nil || value == "" {
+				return value
+			}
+			return redacted
+		}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		settings := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			settings[k] = redactConfig(k, value)
+		}
+		return settings
+	case map[interface{}]interface{}:
+		settings := make(map[interface{}]interface{}, len(v))
+		for k, value := range v {
+			settings[k] = redactConfig(fmt.Sprint(k), value)
+		}
+		return settings
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, value := range v {
+			values[i] = redactConfig(key, value)
+		}
+		return values
+	case string:
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), redacted)
+				return u.String()
+			}
+		}
+	}
+	return value
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+)
+
+// command line arguments
+var (
+	dedupeMinScore *float64 // lowest score to report
+	dedupeID       *string  // only report duplicates of this participant
+)
+
+// dedupeCmd represents the dedupe command
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Find participants registered more than once",
+	Long: `Find participants registered more than once.
+
+Participants are scored on how likely they are to be the same
+person, using their date of birth, phone number and address.`,
+}
+
+// dedupeReportCmd represents the dedupe report command
+var dedupeReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Report the participants which may be duplicates",
+	Long: `Report the participants which may be duplicates.
+
+Each pair of possible duplicates is reported once, highest
+score first. Scores are between 0 and 1, and the server
+default minimum score is used unless --min-score is given.`,
+	Args: exactArgs(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDedupeReport(*dedupeID, *dedupeMinScore)
+	},
+}
+
+// init the command line arguments and add the subcommand to the root
+func init() {
+	dedupeCmd.PersistentFlags().StringP("serverAddress", "s", fmt.Sprintf("%s:%s", DefaultServerAddress, DefaultgRPCport), "address of the server hosting the registry service")
+	dedupeCmd.PersistentFlags().StringP("output", "o", outputTable, fmt.Sprintf("output format (%v)", strings.Join(outputFormats, "|")))
+	dedupeMinScore = dedupeReportCmd.Flags().Float64("min-score", 0, "lowest score to report, between 0 and 1 (default is set by the server)")
+	dedupeID = dedupeReportCmd.Flags().String("id", "", "only report possible duplicates of this participant")
+	dedupeCmd.AddCommand(dedupeReportCmd)
+	rootCmd.AddCommand(dedupeCmd)
+}
+
+// runDedupeReport will report the possible duplicates.
+func runDedupeReport(id string, minScore float64) error {
+	format, err := outputFormat()
+	if err != nil {
+		return err
+	}
+	if minScore < 0 || minScore > 1 {
+		return usageError{fmt.Errorf("--min-score must be between 0 and 1, not %v", minScore)}
+	}
+
+	// connect to the gRPC server and send the request
+	c, err := newRegistryClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	res, err := c.FindDuplicatesWithResponse(context.Background(), id, minScore)
+	if err != nil {
+		return fmt.Errorf("find duplicates request failed: %w", err)
+	}
+	return writeDuplicates(os.Stdout, format, res)
+}
+
+// duplicateOutput is the serialised form
+// of a possible duplicate.
+type duplicateOutput struct {
+	ID          string   `json:"id" yaml:"id"`
+	DuplicateID string   `json:"duplicate_id" yaml:"duplicate_id"`
+	Score       float64  `json:"score" yaml:"score"`
+	Reasons     []string `json:"reasons" yaml:"reasons"`
+}
+
+// writeDuplicates will write possible duplicates
+// to w using the requested output format. The raw
+// response is used for proto-text output.
+func writeDuplicates(w io.Writer, format string, res *api.FindDuplicatesResponse) error {
+	if format == outputProtoText {
+		return writeProtoText(w, res)
+	}
+	out := make([]duplicateOutput, 0, len(res.GetDuplicates()))
+	rows := make([][]string, 0, len(res.GetDuplicates()))
+	for _, duplicate := range res.GetDuplicates() {
+		do := duplicateOutput{
+			ID:          duplicate.GetId(),
+			DuplicateID: duplicate.GetDuplicateId(),
+			Score:       duplicate.GetScore(),
+			Reasons:     duplicate.GetReasons(),
+		}
+		out = append(out, do)
+		rows = append(rows, []string{do.ID, do.DuplicateID, strconv.FormatFloat(do.Score, 'f', 2, 64), strings.Join(do.Reasons, "; ")})
+	}
+	return writeRecords(w, format, out, []string{"id", "duplicate_id", "score", "reasons"}, rows)
+}
@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v2"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	"github.com/will-rowe/registry-microservice/pkg/client"
+)
+
+// defaultEditor is used if neither $VISUAL
+// or $EDITOR are set.
+const defaultEditor = "vi"
+
+// editHeader is written at the top of the
+// file opened in the editor.
+const editHeader = `# Edit the participant details below, then save and close the editor.
+# The id can not be changed and the dob must be YYYY-MM-DD.
+# Lines starting with '#' are ignored, making no changes aborts the edit.
+`
+
+// command line arguments
+var (
+	editYes *bool // apply changes without confirmation
+)
+
+// participantEditCmd represents the participant edit command
+var participantEditCmd = &cobra.Command{
+	Use:   "edit <reference_number>",
+	Short: "Edit a participant in your editor",
+	Long: `Edit a participant in your editor.
+
+The participant is retrieved from the registry and opened as
+YAML in $VISUAL or $EDITOR. Once the editor is closed, the
+changes are validated and shown as a diff for confirmation
+before the participant is updated.
+
+The update is rejected (exit code 7) if the participant was
+modified by someone else while it was being edited.`,
+	Args:              exactArgs(1),
+	ValidArgsFunction: completeParticipantIDs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runEdit(args[0])
+	},
+}
+
+// init the command line arguments and add the subcommand to the participant command
+func init() {
+	editYes = participantEditCmd.Flags().BoolP("yes", "y", false, "apply the changes without asking for confirmation")
+	participantCmd.AddCommand(participantEditCmd)
+}
+
+// runEdit will edit a participant in the registry.
+func runEdit(refNum string) error {
+	c, err := newRegistryClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// get the participant and the revision we are editing
+	original, revision, err := c.GetWithRevision(context.Background(), refNum)
+	if err != nil {
+		return fmt.Errorf("retrieve request failed: %w", err)
+	}
+
+	// edit the participant until it is valid or the user gives up
+	original = proto.Clone(original).(*api.Participant)
+	content, err := formatEdit(original)
+	if err != nil {
+		return err
+	}
+	var reader *bufio.Reader
+	var edited *api.Participant
+	for {
+		content, err = editInEditor(content)
+		if err != nil {
+			return err
+		}
+		edited, err = parseEdit(content, refNum)
+		if err == nil {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "invalid participant: %v\n", err)
+		if !stdinIsTerminal() {
+			return usageError{err}
+		}
+		if reader == nil {
+			reader = bufio.NewReader(os.Stdin)
+		}
+		if !confirm(reader, "re-open the editor? [Y/n]", true) {
+			return usageError{err}
+		}
+	}
+
+	// show the changes and confirm them
+	changes := diffParticipants(original, edited)
+	if len(changes) == 0 {
+		fmt.Fprintln(os.Stderr, "no changes made")
+		return nil
+	}
+	fmt.Fprintln(os.Stdout, strings.Join(changes, "\n"))
+	if !*editYes {
+		if !stdinIsTerminal() {
+			return usageError{errors.New("use --yes to apply changes when not running in a terminal")}
+		}
+		if reader == nil {
+			reader = bufio.NewReader(os.Stdin)
+		}
+		if !confirm(reader, "apply these changes? [y/N]", false) {
+			fmt.Fprintln(os.Stderr, "edit cancelled")
+			return nil
+		}
+	}
+
+	// update the participant, guarding against concurrent modification
+	if err := c.UpdateIfUnchanged(context.Background(), edited, revision); err != nil {
+		if errors.Is(err, client.ErrConflict) {
+			return fmt.Errorf("edit discarded, %w (retry the edit to start from the latest details)", err)
+		}
+		return fmt.Errorf("update request failed: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "participant %v updated\n", refNum)
+	return nil
+}
+
+// formatEdit will format a participant as
+// YAML for editing.
+func formatEdit(p *api.Participant) ([]byte, error) {
+	out, err := yaml.Marshal(newParticipantInput(p))
+	if err != nil {
+		return nil, fmt.Errorf("could not format participant for editing: %w", err)
+	}
+	return append([]byte(editHeader), out...), nil
+}
+
+// parseEdit will parse and validate an
+// edited participant.
+func parseEdit(content []byte, refNum string) (*api.Participant, error) {
+	input := &participantInput{}
+	if err := yaml.UnmarshalStrict(content, input); err != nil {
+		return nil, err
+	}
+	if input.ID != refNum {
+		return nil, fmt.Errorf("the id can not be changed from %v", refNum)
+	}
+	return input.toParticipant(refNum)
+}
+
+// diffParticipants returns the changed fields
+// between two participants, in diff format.
+func diffParticipants(a, b *api.Participant) []string {
+	before, after := newParticipantInput(a), newParticipantInput(b)
+	fields := []struct {
+		name          string
+		before, after string
+	}{
+		{"phone", before.Phone, after.Phone},
+		{"address", before.Address, after.Address},
+		{"dob", before.DOB, after.DOB},
+	}
+	changes := []string{}
+	for _, field := range fields {
+		if field.before != field.after {
+			changes = append(changes,
+				fmt.Sprintf("- %v: %v", field.name, field.before),
+				fmt.Sprintf("+ %v: %v", field.name, field.after),
+			)
+		}
+	}
+	return changes
+}
+
+// editInEditor will open the content in the user's
+// editor and return the edited content.
+func editInEditor(content []byte) ([]byte, error) {
+	f, err := ioutil.TempFile("", "registry-edit-*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	// run the editor attached to the terminal
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = defaultEditor
+	}
+	args := append(strings.Fields(editor), f.Name())
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+	return ioutil.ReadFile(f.Name())
+}
+
+// confirm will ask the user a yes/no question,
+// returning def if they just press return.
+func confirm(reader *bufio.Reader, question string, def bool) bool {
+	answer, err := prompt(reader, question)
+	if err != nil && err != io.EOF {
+		return false
+	}
+	switch strings.ToLower(answer) {
+	case "":
+		return def
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+)
+
+// TestParseEdit will check that edited participants
+// are validated and diffed against the original.
+func TestParseEdit(t *testing.T) {
+	original := &api.Participant{
+		Id:      "KFG-734",
+		Dob:     timestamppb.New(time.Date(1999, 1, 21, 0, 0, 0, 0, time.UTC)),
+		Phone:   "123",
+		Address: "house 1, street 2",
+	}
+
+	// an unedited participant has no changes
+	content, err := formatEdit(original)
+	assert.NilError(t, err)
+	p, err := parseEdit(content, original.GetId())
+	assert.NilError(t, err)
+	assert.Equal(t, len(diffParticipants(original, p)), 0)
+
+	// an edited participant shows the changes
+	p, err = parseEdit([]byte("id: KFG-734\ndob: 1999-01-21\nphone: \"123\"\naddress: house 3, street 4\n"), original.GetId())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, diffParticipants(original, p), []string{
+		"- address: house 1, street 2",
+		"+ address: house 3, street 4",
+	})
+
+	// invalid edits are rejected
+	for _, content := range []string{
+		"id: ABC-123\ndob: 1999-01-21\nphone: \"123\"\naddress: house 1\n",
+		"id: KFG-734\ndob: 21/01/1999\nphone: \"123\"\naddress: house 1\n",
+		"id: KFG-734\ndob: 1999-01-21\nphone: \"\"\naddress: house 1\n",
+		"id: KFG-734\nname: someone\n",
+	} {
+		if _, err := parseEdit([]byte(content), original.GetId()); err == nil {
+			t.Fatalf("invalid edit was accepted: %q", content)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// exit codes returned by the registry command
+const (
+	ExitOK            = 0 // request succeeded
+	ExitError         = 1 // unspecified error
+	ExitUsage         = 2 // invalid command line usage
+	ExitNotFound      = 3 // participant not found
+	ExitAlreadyExists = 4 // participant already exists
+	ExitInvalid       = 5 // request rejected as invalid
+	ExitUnavailable   = 6 // server unavailable or timed out
+	ExitConflict      = 7 // participant modified concurrently
+)
+
+// usageError is returned for invalid
+// command line usage.
+type usageError struct {
+	error
+}
+
+// Unwrap returns the underlying error.
+func (ue usageError) Unwrap() error {
+	return ue.error
+}
+
+// exitCode returns the exit code for an error,
+// using the gRPC status of the error if it has one.
+func exitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	if errors.As(err, &usageError{}) {
+		return ExitUsage
+	}
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &grpcErr) {
+		return ExitError
+	}
+	switch grpcErr.GRPCStatus().Code() {
+	case codes.NotFound:
+		return ExitNotFound
+	case codes.AlreadyExists:
+		return ExitAlreadyExists
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return ExitInvalid
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return ExitUnavailable
+	case codes.Aborted:
+		return ExitConflict
+	default:
+		return ExitError
+	}
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gotest.tools/assert"
+)
+
+// TestExitCode will check that errors are
+// mapped to the correct exit codes.
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{nil, ExitOK},
+		{errors.New("failed"), ExitError},
+		{usageError{errors.New("bad flag")}, ExitUsage},
+		{fmt.Errorf("wrapped: %w", status.Error(codes.NotFound, "")), ExitNotFound},
+		{status.Error(codes.AlreadyExists, ""), ExitAlreadyExists},
+		{status.Error(codes.InvalidArgument, ""), ExitInvalid},
+		{status.Error(codes.Unavailable, ""), ExitUnavailable},
+		{status.Error(codes.DeadlineExceeded, ""), ExitUnavailable},
+		{status.Error(codes.Internal, ""), ExitError},
+	}
+	for _, test := range tests {
+		assert.Equal(t, exitCode(test.err), test.code, fmt.Sprint(test.err))
+	}
+}
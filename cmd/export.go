@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+)
+
+// dobPrecisions maps the --dob flag values
+// to the DOB precision of the export.
+var dobPrecisions = map[string]api.DobPrecision{
+	"year":     api.DobPrecision_DOB_PRECISION_YEAR,
+	"age-band": api.DobPrecision_DOB_PRECISION_AGE_BAND,
+}
+
+// command line arguments
+var (
+	exportPseudonymise *bool   // pseudonymise the exported participants
+	exportDob          *string // DOB precision of a pseudonymised export
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the participants in the registry",
+	Long: `Export the participants in the registry.
+
+Participants who have withdrawn all of their consent are not
+exported. With --pseudonymise, direct identifiers are removed
+for research: reference numbers are replaced by pseudonyms
+(created by the server using its key, so they are the same in
+every export), the DOB is coarsened to the year of birth or the
+age band (--dob), postcodes are truncated to the district and
+phone numbers and addresses are dropped.`,
+	Args: exactArgs(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !*exportPseudonymise {
+			return runList(false)
+		}
+		return runExportPseudonymised(*exportDob)
+	},
+}
+
+// init the command line arguments and add the subcommand to the root
+func init() {
+	exportCmd.Flags().StringP("serverAddress", "s", fmt.Sprintf("%s:%s", DefaultServerAddress, DefaultgRPCport), "address of the server hosting the registry service")
+	exportCmd.Flags().StringP("output", "o", outputCSV, fmt.Sprintf("output format (%v)", strings.Join(outputFormats, "|")))
+	exportPseudonymise = exportCmd.Flags().Bool("pseudonymise", false, "remove direct identifiers from the exported participants")
+	exportDob = exportCmd.Flags().String("dob", "year", "DOB precision of a pseudonymised export (year|age-band)")
+	rootCmd.AddCommand(exportCmd)
+}
+
+// runExportPseudonymised will export the
+// pseudonymised participants.
+func runExportPseudonymised(dob string) error {
+	format, err := outputFormat()
+	if err != nil {
+		return err
+	}
+	precision, ok := dobPrecisions[dob]
+	if !ok {
+		return usageError{fmt.Errorf("unsupported --dob precision %q (year|age-band)", dob)}
+	}
+
+	// connect to the gRPC server and send the request
+	c, err := newRegistryClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	res, err := c.ExportPseudonymisedWithResponse(context.Background(), precision)
+	if err != nil {
+		return fmt.Errorf("export request failed: %w", err)
+	}
+	return writePseudonymised(os.Stdout, format, res)
+}
+
+// pseudonymisedOutput is the serialised form
+// of a pseudonymised participant.
+type pseudonymisedOutput struct {
+	Pseudonym        string `json:"pseudonym" yaml:"pseudonym"`
+	BirthYear        int32  `json:"birth_year,omitempty" yaml:"birth_year,omitempty"`
+	AgeBand          string `json:"age_band,omitempty" yaml:"age_band,omitempty"`
+	PostcodeDistrict string `json:"postcode_district" yaml:"postcode_district"`
+	Country          string `json:"country" yaml:"country"`
+}
+
+// writePseudonymised will write pseudonymised participants
+// to w using the requested output format. The raw response
+// is used for proto-text output.
+func writePseudonymised(w io.Writer, format string, res *api.ExportPseudonymisedResponse) error {
+	if format == outputProtoText {
+		return writeProtoText(w, res)
+	}
+	out := make([]pseudonymisedOutput, 0, len(res.GetParticipants()))
+	rows := make([][]string, 0, len(res.GetParticipants()))
+	for _, p := range res.GetParticipants() {
+		po := pseudonymisedOutput{
+			Pseudonym:        p.GetPseudonym(),
+			BirthYear:        p.GetBirthYear(),
+			AgeBand:          p.GetAgeBand(),
+			PostcodeDistrict: p.GetPostcodeDistrict(),
+			Country:          p.GetCountry(),
+		}
+		birthYear := ""
+		if po.BirthYear != 0 {
+			birthYear = strconv.Itoa(int(po.BirthYear))
+		}
+		out = append(out, po)
+		rows = append(rows, []string{po.Pseudonym, birthYear, po.AgeBand, po.PostcodeDistrict, po.Country})
+	}
+	return writeRecords(w, format, out, []string{"pseudonym", "birth_year", "age_band", "postcode_district", "country"}, rows)
+}
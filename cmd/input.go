@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gopkg.in/yaml.v2"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+)
+
+// participantInput is the serialised form of the
+// participant details collected by the client.
+type participantInput struct {
+	ID      string `json:"id" yaml:"id"`
+	Phone   string `json:"phone" yaml:"phone"`
+	Address string `json:"address" yaml:"address"`
+	DOB     string `json:"dob" yaml:"dob"`
+}
+
+// inputOptions holds the sources the client
+// can collect participant details from.
+type inputOptions struct {
+	phone    string // phone number given by flag
+	address  string // address given by flag
+	dob      string // date of birth given by flag
+	fromFile string // file to read details from ("-" for STDIN)
+}
+
+// readParticipantFile will read participant details
+// from a JSON or YAML file, or from STDIN if the
+// filename is "-". Unknown fields are rejected so
+// that misspelt details are not silently dropped.
+func readParticipantFile(filename string) (*participantInput, error) {
+	var data []byte
+	var err error
+	if filename == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	input := &participantInput{}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(input)
+	default:
+
+		// YAML is a superset of JSON so this
+		// also handles JSON piped on STDIN
+		err = yaml.UnmarshalStrict(data, input)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read participant from %v: %w", filename, err)
+	}
+	return input, nil
+}
+
+// stdinIsTerminal returns true if STDIN
+// is attached to a terminal.
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// prompt will ask the user for a value and
+// return their response without the newline.
+func prompt(reader *bufio.Reader, msg string) (string, error) {
+	fmt.Println(msg)
+	value, err := reader.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && len(value) > 0) {
+		return "", err
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// collectParticipant will build a Participant from the
+// input file and flags, with flags taking precedence.
+// Any missing details are prompted for if STDIN is
+// a terminal, otherwise an error is returned.
+func collectParticipant(ref string, opts inputOptions) (*api.Participant, error) {
+	// get any details from the input file
+	input := &participantInput{}
+	if opts.fromFile != "" {
+		var err error
+		input, err = readParticipantFile(opts.fromFile)
+		if err != nil {
+			return nil, err
+		}
+		if input.ID != "" && input.ID != ref {
+			return nil, fmt.Errorf("reference number in file (%v) does not match requested participant (%v)", input.ID, ref)
+		}
+	}
+
+	// override with any details from the flags
+	if opts.phone != "" {
+		input.Phone = opts.phone
+	}
+	if opts.address != "" {
+		input.Address = opts.address
+	}
+	if opts.dob != "" {
+		input.DOB = opts.dob
+	}
+
+	// prompt for anything that is missing
+	missing := []struct {
+		value *string
+		name  string
+		msg   string
+	}{
+		{&input.Phone, "phone", "enter phone number and press return:"},
+		{&input.Address, "address", "enter address and press return:"},
+		{&input.DOB, "dob", "enter date of birth (YYYY-MM-DD) and press return:"},
+	}
+	var reader *bufio.Reader
+	for _, field := range missing {
+		if strings.TrimSpace(*field.value) != "" {
+			continue
+		}
+		if opts.fromFile == "-" || !stdinIsTerminal() {
+			return nil, fmt.Errorf("no %v provided for participant (%v): use --%v or --from-file", field.name, ref, field.name)
+		}
+		if reader == nil {
+			fmt.Printf("collecting information for participant (%v)\n", ref)
+			reader = bufio.NewReader(os.Stdin)
+		}
+		value, err := prompt(reader, field.msg)
+		if err != nil {
+			return nil, err
+		}
+		*field.value = value
+	}
+
+	// create the participant
+	return input.toParticipant(ref)
+}
+
+// newParticipantInput will convert a Participant
+// to the form used for collecting participant details.
+func newParticipantInput(p *api.Participant) *participantInput {
+	input := &participantInput{
+		ID:      p.GetId(),
+		Phone:   p.GetPhone(),
+		Address: p.GetAddress(),
+	}
+	if p.GetDob() != nil {
+		input.DOB = p.GetDob().AsTime().UTC().Format(layoutISO)
+	}
+	return input
+}
+
+// toParticipant will validate the collected details
+// and return them as a Participant.
+func (input *participantInput) toParticipant(ref string) (*api.Participant, error) {
+	for _, field := range [][2]string{{"phone", input.Phone}, {"address", input.Address}, {"dob", input.DOB}} {
+		if strings.TrimSpace(field[1]) == "" {
+			return nil, fmt.Errorf("no %v provided for participant (%v)", field[0], ref)
+		}
+	}
+	birthdate, err := time.Parse(layoutISO, strings.TrimSpace(input.DOB))
+	if err != nil {
+		return nil, fmt.Errorf("date of birth must be YYYY-MM-DD: %w", err)
+	}
+	return &api.Participant{
+		Id:      ref,
+		Dob:     timestamppb.New(birthdate),
+		Phone:   strings.TrimSpace(input.Phone),
+		Address: strings.TrimSpace(input.Address),
+	}, nil
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+// TestCollectParticipant will check that participant
+// details are taken from the flags and input files,
+// with the flags taking precedence. STDIN is not a
+// terminal in the tests, so nothing is prompted for.
+func TestCollectParticipant(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"participant.json":  `{"id": "KFG-734", "phone": "07700 900123", "address": "The moon", "dob": "1990-01-02"}`,
+		"participant.yaml":  "id: KFG-734\nphone: 07700 900123\naddress: The moon\ndob: 1990-01-02\n",
+		"partial.yml":       "address: The moon\n",
+		"unknown.json":      `{"phone": "07700 900123", "adress": "The moon", "dob": "1990-01-02"}`,
+		"unknown.yaml":      "phone: 07700 900123\nadress: The moon\ndob: 1990-01-02\n",
+		"other.json":        `{"id": "ABC-123", "phone": "07700 900123", "address": "The moon", "dob": "1990-01-02"}`,
+		"participant.notes": "{\"phone\": \"07700 900123\", \"address\": \"The moon\", \"dob\": \"1990-01-02\"}\n",
+	}
+	for name, data := range files {
+		assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600))
+	}
+	tests := []struct {
+		name    string
+		opts    inputOptions
+		phone   string
+		address string
+		dob     string
+		err     string
+	}{
+		{
+			name:    "flags only",
+			opts:    inputOptions{phone: "07700 900456", address: "Mars", dob: "1985-03-04"},
+			phone:   "07700 900456",
+			address: "Mars",
+			dob:     "1985-03-04",
+		},
+		{
+			name:    "json file",
+			opts:    inputOptions{fromFile: "participant.json"},
+			phone:   "07700 900123",
+			address: "The moon",
+			dob:     "1990-01-02",
+		},
+		{
+			name:    "yaml file",
+			opts:    inputOptions{fromFile: "participant.yaml"},
+			phone:   "07700 900123",
+			address: "The moon",
+			dob:     "1990-01-02",
+		},
+		{
+			name:    "other extensions are read as yaml",
+			opts:    inputOptions{fromFile: "participant.notes"},
+			phone:   "07700 900123",
+			address: "The moon",
+			dob:     "1990-01-02",
+		},
+		{
+			name:    "file with flag overrides",
+			opts:    inputOptions{fromFile: "participant.yaml", phone: "07700 900456", dob: "1985-03-04"},
+			phone:   "07700 900456",
+			address: "The moon",
+			dob:     "1985-03-04",
+		},
+		{
+			name:    "partial file completed by flags",
+			opts:    inputOptions{fromFile: "partial.yml", phone: "07700 900456", dob: "1985-03-04"},
+			phone:   "07700 900456",
+			address: "The moon",
+			dob:     "1985-03-04",
+		},
+		{
+			name: "unknown json field",
+			opts: inputOptions{fromFile: "unknown.json"},
+			err:  `unknown field "adress"`,
+		},
+		{
+			name: "unknown yaml field",
+			opts: inputOptions{fromFile: "unknown.yaml"},
+			err:  "field adress not found",
+		},
+		{
+			name: "mismatched reference number",
+			opts: inputOptions{fromFile: "other.json"},
+			err:  "reference number in file (ABC-123) does not match requested participant (KFG-734)",
+		},
+		{
+			name: "missing details",
+			opts: inputOptions{fromFile: "partial.yml"},
+			err:  "no phone provided for participant (KFG-734)",
+		},
+		{
+			name: "invalid date of birth",
+			opts: inputOptions{phone: "07700 900456", address: "Mars", dob: "04/03/1985"},
+			err:  "date of birth must be YYYY-MM-DD",
+		},
+		{
+			name: "missing file",
+			opts: inputOptions{fromFile: "missing.json"},
+			err:  "no such file",
+		},
+	}
+	for _, test := range tests {
+		if test.opts.fromFile != "" {
+			test.opts.fromFile = filepath.Join(dir, test.opts.fromFile)
+		}
+		p, err := collectParticipant("KFG-734", test.opts)
+		if test.err != "" {
+			assert.ErrorContains(t, err, test.err, test.name)
+			continue
+		}
+		assert.NilError(t, err, test.name)
+		assert.Equal(t, p.GetId(), "KFG-734", test.name)
+		assert.Equal(t, p.GetPhone(), test.phone, test.name)
+		assert.Equal(t, p.GetAddress(), test.address, test.name)
+		assert.Equal(t, p.GetDob().AsTime().Format(layoutISO), test.dob, test.name)
+	}
+}
+
+// TestNewParticipantInput will check that a participant
+// converted for editing can be read back unchanged.
+func TestNewParticipantInput(t *testing.T) {
+	input := &participantInput{ID: "KFG-734", Phone: "07700 900123", Address: "The moon", DOB: "1990-01-02"}
+	p, err := input.toParticipant("KFG-734")
+	assert.NilError(t, err)
+	assert.Equal(t, p.GetDob().AsTime(), time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC))
+	assert.DeepEqual(t, newParticipantInput(p), input)
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v2"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+)
+
+// supported client output formats
+const (
+	outputJSON      = "json"
+	outputYAML      = "yaml"
+	outputTable     = "table"
+	outputProtoText = "proto-text"
+	outputCSV       = "csv"
+)
+
+// outputFormats lists the supported client output formats.
+var outputFormats = []string{outputJSON, outputYAML, outputTable, outputProtoText, outputCSV}
+
+// participantOutput is the stable, serialised form
+// of a participant written by the client.
+type participantOutput struct {
+	ID      string `json:"id" yaml:"id"`
+	DOB     string `json:"dob" yaml:"dob"`
+	Phone   string `json:"phone" yaml:"phone"`
+	Address string `json:"address" yaml:"address"`
+}
+
+// participantHeader is the table and CSV header
+// for participants.
+var participantHeader = []string{"id", "dob", "phone", "address"}
+
+// row returns the participant fields
+// in the order of the participantHeader.
+func (po participantOutput) row() []string {
+	return []string{po.ID, po.DOB, po.Phone, po.Address}
+}
+
+// resultOutput is the serialised form of the
+// outcome of a create, update or delete request.
+type resultOutput struct {
+	ID      string `json:"id" yaml:"id"`
+	Request string `json:"request" yaml:"request"`
+	Success bool   `json:"success" yaml:"success"`
+}
+
+// receiptOutput is the serialised form
+// of an erasure receipt.
+type receiptOutput struct {
+	ReceiptID              string `json:"receipt_id" yaml:"receipt_id"`
+	Erased                 string `json:"erased" yaml:"erased"`
+	SubjectDigest          string `json:"subject_digest" yaml:"subject_digest"`
+	Nonce                  string `json:"nonce" yaml:"nonce"`
+	AliasesErased          uint32 `json:"aliases_erased" yaml:"aliases_erased"`
+	EnrollmentsErased      uint32 `json:"enrollments_erased" yaml:"enrollments_erased"`
+	IdentifiersErased      uint32 `json:"identifiers_erased" yaml:"identifiers_erased"`
+	HistoryEntriesScrubbed uint32 `json:"history_entries_scrubbed" yaml:"history_entries_scrubbed"`
+	Signature              string `json:"signature" yaml:"signature"`
+}
+
+// newParticipantOutput will convert a Participant
+// to its output form, with dates as RFC3339.
+func newParticipantOutput(p *api.Participant) participantOutput {
+	out := participantOutput{
+		ID:      p.GetId(),
+		Phone:   p.GetPhone(),
+		Address: p.GetAddress(),
+	}
+	if p.GetDob() != nil {
+		out.DOB = p.GetDob().AsTime().UTC().Format(time.RFC3339)
+	}
+	return out
+}
+
+// checkOutputFormat returns an error if the
+// requested output format is not supported.
+func checkOutputFormat(format string) error {
+	for _, f := range outputFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported output format %q: must be one of %v", format, outputFormats)
+}
+
+// writeParticipant will write a participant
+// to w using the requested output format.
+func writeParticipant(w io.Writer, format string, p *api.Participant) error {
+	if format == outputProtoText {
+		return writeProtoText(w, p)
+	}
+	out := newParticipantOutput(p)
+	return writeRecords(w, format, out, participantHeader, [][]string{out.row()})
+}
+
+// participantList is a response holding participants,
+// i.e. a list or search response.
+type participantList interface {
+	proto.Message
+	GetParticipants() []*api.Participant
+}
+
+// writeParticipantList will write a list of participants
+// to w using the requested output format. The raw
+// response is used for proto-text output.
+func writeParticipantList(w io.Writer, format string, res participantList) error {
+	if format == outputProtoText {
+		return writeProtoText(w, res)
+	}
+	out := make([]participantOutput, 0, len(res.GetParticipants()))
+	rows := make([][]string, 0, len(res.GetParticipants()))
+	for _, p := range res.GetParticipants() {
+		po := newParticipantOutput(p)
+		out = append(out, po)
+		rows = append(rows, po.row())
+	}
+	return writeRecords(w, format, out, participantHeader, rows)
+}
+
+// writeResult will write the outcome of a create,
+// update or delete request to w using the requested
+// output format. The raw response is used for
+// proto-text output.
+func writeResult(w io.Writer, format string, result resultOutput, res proto.Message) error {
+	if format == outputProtoText {
+		return writeProtoText(w, res)
+	}
+	return writeRecords(w, format, result,
+		[]string{"id", "request", "success"},
+		[][]string{{result.ID, result.Request, strconv.FormatBool(result.Success)}},
+	)
+}
+
+// writeReceipt will write an erasure receipt to w using
+// the requested output format, with the signature in
+// base64. The raw response is used for proto-text output.
+func writeReceipt(w io.Writer, format string, res *api.EraseResponse) error {
+	if format == outputProtoText {
+		return writeProtoText(w, res)
+	}
+	receipt := res.GetReceipt()
+	out := receiptOutput{
+		ReceiptID:              receipt.GetReceiptId(),
+		Erased:                 receipt.GetErased().AsTime().UTC().Format(time.RFC3339),
+		SubjectDigest:          receipt.GetSubjectDigest(),
+		Nonce:                  res.GetNonce(),
+		AliasesErased:          receipt.GetAliasesErased(),
+		EnrollmentsErased:      receipt.GetEnrollmentsErased(),
+		IdentifiersErased:      receipt.GetIdentifiersErased(),
+		HistoryEntriesScrubbed: receipt.GetHistoryEntriesScrubbed(),
+		Signature:              base64.StdEncoding.EncodeToString(receipt.GetSignature()),
+	}
+	count := func(n uint32) string { return strconv.FormatUint(uint64(n), 10) }
+	return writeRecords(w, format, out,
+		[]string{"receipt_id", "erased", "subject_digest", "nonce", "aliases_erased", "enrollments_erased", "identifiers_erased", "history_entries_scrubbed", "signature"},
+		[][]string{{out.ReceiptID, out.Erased, out.SubjectDigest, out.Nonce, count(out.AliasesErased), count(out.EnrollmentsErased), count(out.IdentifiersErased), count(out.HistoryEntriesScrubbed), out.Signature}},
+	)
+}
+
+// writeProtoText will write a message to w
+// in the protobuf text format.
+func writeProtoText(w io.Writer, m proto.Message) error {
+	out, err := prototext.MarshalOptions{Multiline: true}.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// writeRecords will write records to w as JSON or YAML
+// using v, or as a table or CSV using the header and rows.
+func writeRecords(w io.Writer, format string, v interface{}, header []string, rows [][]string) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case outputYAML:
+		out, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	case outputTable:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case outputCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.WriteAll(append([][]string{header}, rows...)); err != nil {
+			return err
+		}
+		return cw.Error()
+	default:
+		return checkOutputFormat(format)
+	}
+}
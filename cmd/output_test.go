@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+)
+
+// TestWriteParticipant will check the participant
+// output formats use stable field names and dates.
+func TestWriteParticipant(t *testing.T) {
+	p := &api.Participant{
+		Id:      "KFG-734",
+		Dob:     timestamppb.New(time.Date(1999, 1, 21, 0, 0, 0, 0, time.UTC)),
+		Phone:   "123",
+		Address: "house 1, street 2",
+	}
+	tests := map[string]string{
+		outputJSON:  "{\n  \"id\": \"KFG-734\",\n  \"dob\": \"1999-01-21T00:00:00Z\",\n  \"phone\": \"123\",\n  \"address\": \"house 1, street 2\"\n}\n",
+		outputYAML:  "id: KFG-734\ndob: \"1999-01-21T00:00:00Z\"\nphone: \"123\"\naddress: house 1, street 2\n",
+		outputCSV:   "id,dob,phone,address\nKFG-734,1999-01-21T00:00:00Z,123,\"house 1, street 2\"\n",
+		outputTable: "ID       DOB                   PHONE  ADDRESS\nKFG-734  1999-01-21T00:00:00Z  123    house 1, street 2\n",
+	}
+	for format, expected := range tests {
+		buf := &bytes.Buffer{}
+		assert.NilError(t, writeParticipant(buf, format, p))
+		assert.Equal(t, buf.String(), expected, format)
+	}
+	if err := writeParticipant(&bytes.Buffer{}, "xml", p); err == nil {
+		t.Fatal("unsupported output format was accepted")
+	}
+}
+
+// TestWritePseudonymised will check the pseudonymised
+// output formats, which drop the unset DOB precision.
+func TestWritePseudonymised(t *testing.T) {
+	res := &api.ExportPseudonymisedResponse{
+		Participants: []*api.PseudonymisedParticipant{{Pseudonym: "0f3c8a9e2b7d4c61", AgeBand: "20-29", PostcodeDistrict: "SW1A", Country: "GB"}},
+	}
+	tests := map[string]string{
+		outputJSON: "[\n  {\n    \"pseudonym\": \"0f3c8a9e2b7d4c61\",\n    \"age_band\": \"20-29\",\n    \"postcode_district\": \"SW1A\",\n    \"country\": \"GB\"\n  }\n]\n",
+		outputCSV:  "pseudonym,birth_year,age_band,postcode_district,country\n0f3c8a9e2b7d4c61,,20-29,SW1A,GB\n",
+	}
+	for format, expected := range tests {
+		buf := &bytes.Buffer{}
+		assert.NilError(t, writePseudonymised(buf, format, res))
+		assert.Equal(t, buf.String(), expected, format)
+	}
+}
+
+// TestWriteRetentionActions will check the
+// retention report output formats.
+func TestWriteRetentionActions(t *testing.T) {
+	res := &api.RetentionReportResponse{
+		Actions: []*api.RetentionAction{
+			{ParticipantId: "ABC-123", Delete: true, Rules: []string{"withdrawn"}, Due: timestamppb.New(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))},
+			{ParticipantId: "KFG-734", Fields: []string{"emails", "phones"}, Rules: []string{"contact"}, Due: timestamppb.New(time.Date(2020, 3, 31, 0, 0, 0, 0, time.UTC))},
+		},
+	}
+	buf := &bytes.Buffer{}
+	assert.NilError(t, writeRetentionActions(buf, outputCSV, res))
+	assert.Equal(t, buf.String(), "id,action,fields,rules,due\nABC-123,delete,,withdrawn,2021-06-01\nKFG-734,redact,emails; phones,contact,2020-03-31\n")
+}
@@ -0,0 +1,443 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/will-rowe/registry-microservice/pkg/client"
+)
+
+const (
+	// layoutISO is the date format for collecting the DoB from participants
+	layoutISO = "2006-01-02"
+)
+
+// command line arguments
+var (
+	participantDetails   inputOptions // participant details for create and update
+	listIncludeWithdrawn *bool        // include withdrawn participants in the list
+	idempotencyKey       string       // idempotency key for create, update and delete
+)
+
+// participantCmd represents the participant command
+var participantCmd = &cobra.Command{
+	Use:   "participant",
+	Short: "Send participant requests to the registry server",
+	Long: `Send participant requests to the registry server using gRPC.
+
+The subcommands connect to the server and make CRUD requests
+for participants held in the registry.
+
+Exit codes:
+  0  success
+  1  unspecified error
+  2  invalid command line usage
+  3  participant not found
+  4  participant already exists
+  5  request rejected as invalid
+  6  server unavailable or timed out
+  7  participant modified concurrently
+
+Create, update and delete requests are sent with an idempotency
+key. If one of these requests times out, it may still have been
+applied. Retrying it with --idempotency-key set to the key of the
+original request returns the original result rather than applying
+the request again. The key is printed when a request times out.`,
+}
+
+// participantCreateCmd represents the participant create command
+var participantCreateCmd = &cobra.Command{
+	Use:   "create [reference_number]",
+	Short: "Create a participant in the registry",
+	Long: `Create a participant in the registry.
+
+Participant details are taken from the --phone, --address and --dob
+flags and/or a JSON or YAML file given by --from-file. Missing details
+are prompted for when running in a terminal.
+
+The reference number can be omitted if the server allocates
+reference numbers (see registry serve --idPattern), in which
+case the allocated reference number is output.`,
+	Args: rangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		refNum := ""
+		if len(args) != 0 {
+			refNum = args[0]
+		}
+		return runCreate(refNum)
+	},
+}
+
+// participantGetCmd represents the participant get command
+var participantGetCmd = &cobra.Command{
+	Use:               "get <reference_number>",
+	Short:             "Get a participant from the registry",
+	Args:              exactArgs(1),
+	ValidArgsFunction: completeParticipantIDs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runGet(args[0])
+	},
+}
+
+// participantUpdateCmd represents the participant update command
+var participantUpdateCmd = &cobra.Command{
+	Use:   "update <reference_number>",
+	Short: "Update a participant in the registry",
+	Long: `Update a participant in the registry.
+
+All participant details are replaced. Details are taken from the
+--phone, --address and --dob flags and/or a JSON or YAML file given
+by --from-file. Missing details are prompted for when running in
+a terminal.`,
+	Args:              exactArgs(1),
+	ValidArgsFunction: completeParticipantIDs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUpdate(args[0])
+	},
+}
+
+// participantDeleteCmd represents the participant delete command
+var participantDeleteCmd = &cobra.Command{
+	Use:               "delete <reference_number>",
+	Short:             "Delete a participant from the registry",
+	Args:              exactArgs(1),
+	ValidArgsFunction: completeParticipantIDs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDelete(args[0])
+	},
+}
+
+// participantEraseCmd represents the participant erase command
+var participantEraseCmd = &cobra.Command{
+	Use:   "erase <reference_number>",
+	Short: "Erase a participant from the registry",
+	Long: `Erase a participant from the registry.
+
+Unlike delete, every record of the participant held by the
+registry is removed, including the aliases of participants
+merged into them, their enrollments and their mutation history.
+A signed erasure receipt is output, which does not identify the
+participant but can be checked against the reference number.`,
+	Args:              exactArgs(1),
+	ValidArgsFunction: completeParticipantIDs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runErase(args[0])
+	},
+}
+
+// participantListCmd represents the participant list command
+var participantListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the participants in the registry",
+	Args:  exactArgs(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runList(*listIncludeWithdrawn)
+	},
+}
+
+// participantSearchCmd represents the participant search command
+var participantSearchCmd = &cobra.Command{
+	Use:   "search <phone_number>",
+	Short: "Search for participants by phone number",
+	Long: `Search for participants by phone number.
+
+The phone number matches regardless of how it is formatted,
+numbers not in international format use the default region
+of the server.`,
+	Args: exactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSearch(args[0])
+	},
+}
+
+// init the command line arguments and add the subcommand to the root
+func init() {
+	participantCmd.PersistentFlags().StringP("serverAddress", "s", fmt.Sprintf("%s:%s", DefaultServerAddress, DefaultgRPCport), "address of the server hosting the registry service")
+	participantCmd.PersistentFlags().StringP("output", "o", outputProtoText, fmt.Sprintf("output format (%v)", strings.Join(outputFormats, "|")))
+	for _, cmd := range []*cobra.Command{participantCreateCmd, participantUpdateCmd} {
+		cmd.Flags().StringVar(&participantDetails.phone, "phone", "", "phone number of the participant")
+		cmd.Flags().StringVar(&participantDetails.address, "address", "", "address of the participant")
+		cmd.Flags().StringVar(&participantDetails.dob, "dob", "", "date of birth of the participant as YYYY-MM-DD")
+		cmd.Flags().StringVarP(&participantDetails.fromFile, "from-file", "f", "", "read participant details from a JSON or YAML file, use - for STDIN")
+	}
+	for _, cmd := range []*cobra.Command{participantCreateCmd, participantUpdateCmd, participantDeleteCmd} {
+		cmd.Flags().StringVar(&idempotencyKey, "idempotency-key", "", "idempotency key for the request, use the key of a timed out request to retry it safely (default is a new key)")
+	}
+	listIncludeWithdrawn = participantListCmd.Flags().Bool("include-withdrawn", false, "include participants who have withdrawn their consent")
+	participantCmd.AddCommand(participantCreateCmd, participantGetCmd, participantUpdateCmd, participantDeleteCmd, participantEraseCmd, participantListCmd, participantSearchCmd)
+	rootCmd.AddCommand(participantCmd)
+}
+
+// exactArgs returns a cobra argument validator which
+// reports the wrong number of arguments as a usage error.
+func exactArgs(n int) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(n)(cmd, args); err != nil {
+			return usageError{err}
+		}
+		return nil
+	}
+}
+
+// rangeArgs returns a cobra argument validator which
+// reports the wrong number of arguments as a usage error.
+func rangeArgs(min, max int) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := cobra.RangeArgs(min, max)(cmd, args); err != nil {
+			return usageError{err}
+		}
+		return nil
+	}
+}
+
+// newRegistryClient connects to the registry server.
+func newRegistryClient(opts ...client.Option) (*client.Client, error) {
+	opts = append([]client.Option{client.WithTimeout(DefaultRequestTimeout)}, opts...)
+	c, err := client.New(viper.GetString(cfgServerAddress), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to gRPC server: %w", err)
+	}
+	return c, nil
+}
+
+// outputFormat returns the requested output format,
+// or a usage error if it is not supported.
+func outputFormat() (string, error) {
+	format := viper.GetString(cfgOutput)
+	if err := checkOutputFormat(format); err != nil {
+		return "", usageError{err}
+	}
+	return format, nil
+}
+
+// idempotentContext returns a context with the idempotency
+// key given by --idempotency-key, or a new key if none was
+// given, along with the key.
+func idempotentContext() (context.Context, string, error) {
+	key := idempotencyKey
+	if key == "" {
+		var err error
+		if key, err = client.NewIdempotencyKey(); err != nil {
+			return nil, "", fmt.Errorf("could not create idempotency key: %w", err)
+		}
+	}
+	return client.WithIdempotencyKey(context.Background(), key), key, nil
+}
+
+// idempotencyHint will tell the user how to retry a request
+// which may have been applied before the server timed out or
+// became unavailable.
+func idempotencyHint(err error, key string) {
+	if exitCode(err) == ExitUnavailable {
+		fmt.Fprintf(os.Stderr, "the request may have been applied, retry with --idempotency-key %v to get its result without applying it twice\n", key)
+	}
+}
+
+// runCreate will create a participant in the registry.
+func runCreate(refNum string) error {
+	format, err := outputFormat()
+	if err != nil {
+		return err
+	}
+
+	// create the Participant
+	p, err := collectParticipant(refNum, participantDetails)
+	if err != nil {
+		return usageError{err}
+	}
+
+	// connect to the gRPC server and send the request
+	c, err := newRegistryClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	ctx, key, err := idempotentContext()
+	if err != nil {
+		return err
+	}
+	res, err := c.CreateWithResponse(ctx, p)
+	if err != nil {
+		idempotencyHint(err, key)
+		return fmt.Errorf("create request failed: %w", err)
+	}
+	for _, duplicate := range res.GetPossibleDuplicates() {
+		fmt.Fprintf(os.Stderr, "warning: %v may be a duplicate of %v (score %.2f: %v)\n",
+			duplicate.GetId(), duplicate.GetDuplicateId(), duplicate.GetScore(), strings.Join(duplicate.GetReasons(), ", "))
+	}
+	return writeResult(os.Stdout, format,
+		resultOutput{ID: res.GetId(), Request: "create", Success: true},
+		res,
+	)
+}
+
+// runGet will retrieve a participant from the registry.
+func runGet(refNum string) error {
+	format, err := outputFormat()
+	if err != nil {
+		return err
+	}
+
+	// connect to the gRPC server and send the request
+	c, err := newRegistryClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	p, err := c.Get(context.Background(), refNum)
+	if err != nil {
+		return fmt.Errorf("retrieve request failed: %w", err)
+	}
+	return writeParticipant(os.Stdout, format, p)
+}
+
+// runUpdate will update a participant in the registry.
+func runUpdate(refNum string) error {
+	format, err := outputFormat()
+	if err != nil {
+		return err
+	}
+
+	// create the Participant
+	p, err := collectParticipant(refNum, participantDetails)
+	if err != nil {
+		return usageError{err}
+	}
+
+	// connect to the gRPC server and send the request
+	c, err := newRegistryClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	ctx, key, err := idempotentContext()
+	if err != nil {
+		return err
+	}
+	res, err := c.UpdateWithResponse(ctx, p, 0)
+	if err != nil {
+		idempotencyHint(err, key)
+		return fmt.Errorf("update request failed: %w", err)
+	}
+	return writeResult(os.Stdout, format,
+		resultOutput{ID: refNum, Request: "update", Success: true},
+		res,
+	)
+}
+
+// runDelete will delete a participant from the registry.
+func runDelete(refNum string) error {
+	format, err := outputFormat()
+	if err != nil {
+		return err
+	}
+
+	// connect to the gRPC server and send the request
+	c, err := newRegistryClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	ctx, key, err := idempotentContext()
+	if err != nil {
+		return err
+	}
+	res, err := c.DeleteWithResponse(ctx, refNum)
+	if err != nil {
+		idempotencyHint(err, key)
+		return fmt.Errorf("delete request failed: %w", err)
+	}
+	return writeResult(os.Stdout, format,
+		resultOutput{ID: refNum, Request: "delete", Success: true},
+		res,
+	)
+}
+
+// runErase will erase a participant from the registry.
+func runErase(refNum string) error {
+	format, err := outputFormat()
+	if err != nil {
+		return err
+	}
+
+	// connect to the gRPC server and send the request
+	c, err := newRegistryClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	res, err := c.EraseWithResponse(context.Background(), refNum)
+	if err != nil {
+		return fmt.Errorf("erase request failed: %w", err)
+	}
+	return writeReceipt(os.Stdout, format, res)
+}
+
+// runList will list the participants in the registry.
+func runList(includeWithdrawn bool) error {
+	format, err := outputFormat()
+	if err != nil {
+		return err
+	}
+
+	// connect to the gRPC server and send the request
+	c, err := newRegistryClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	res, err := c.ListWithResponse(context.Background(), includeWithdrawn)
+	if err != nil {
+		return fmt.Errorf("list request failed: %w", err)
+	}
+	return writeParticipantList(os.Stdout, format, res)
+}
+
+// runSearch will search for participants in the registry.
+func runSearch(phone string) error {
+	format, err := outputFormat()
+	if err != nil {
+		return err
+	}
+
+	// connect to the gRPC server and send the request
+	c, err := newRegistryClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	res, err := c.SearchWithResponse(context.Background(), phone)
+	if err != nil {
+		return fmt.Errorf("search request failed: %w", err)
+	}
+	return writeParticipantList(os.Stdout, format, res)
+}
+
+// completeParticipantIDs will complete participant reference
+// numbers using the participants held by the registry server.
+func completeParticipantIDs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	c, err := newRegistryClient(client.WithTimeout(DefaultCompletionTimeout), client.WithRetries(0, 0))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	defer c.Close()
+	participants, err := c.List(context.Background())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	ids := []string{}
+	for _, p := range participants {
+		if strings.HasPrefix(p.GetId(), toComplete) {
+			ids = append(ids, p.GetId())
+		}
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp
+}
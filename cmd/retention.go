@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+)
+
+// command line arguments
+var (
+	retentionAsOf *string // date to evaluate the retention rules at
+)
+
+// retentionCmd represents the retention command
+var retentionCmd = &cobra.Command{
+	Use:   "retention",
+	Short: "Inspect the data retention rules",
+	Long: `Inspect the data retention rules.
+
+Retention rules are set in the server config file and say when
+participant details must be destroyed, by redacting fields or
+deleting the participant. The server enforces the rules at
+each retention interval.`,
+}
+
+// retentionReportCmd represents the retention report command
+var retentionReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Report the actions the retention rules require",
+	Long: `Report the actions the retention rules require.
+
+This is a dry run, the participants are not changed. Use
+--as-of to see what the rules will require on a later date.`,
+	Args: exactArgs(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRetentionReport(*retentionAsOf)
+	},
+}
+
+// init the command line arguments and add the subcommand to the root
+func init() {
+	retentionCmd.PersistentFlags().StringP("serverAddress", "s", fmt.Sprintf("%s:%s", DefaultServerAddress, DefaultgRPCport), "address of the server hosting the registry service")
+	retentionCmd.PersistentFlags().StringP("output", "o", outputTable, fmt.Sprintf("output format (%v)", strings.Join(outputFormats, "|")))
+	retentionAsOf = retentionReportCmd.Flags().String("as-of", "", fmt.Sprintf("date to evaluate the rules at (%v), default is now", layoutISO))
+	retentionCmd.AddCommand(retentionReportCmd)
+	rootCmd.AddCommand(retentionCmd)
+}
+
+// runRetentionReport will report the retention actions.
+func runRetentionReport(asOf string) error {
+	format, err := outputFormat()
+	if err != nil {
+		return err
+	}
+	var date time.Time
+	if asOf != "" {
+		if date, err = time.Parse(layoutISO, asOf); err != nil {
+			return usageError{fmt.Errorf("--as-of must be a date (%v), not %q", layoutISO, asOf)}
+		}
+	}
+
+	// connect to the gRPC server and send the request
+	c, err := newRegistryClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	res, err := c.RetentionReportWithResponse(context.Background(), date)
+	if err != nil {
+		return fmt.Errorf("retention report request failed: %w", err)
+	}
+	return writeRetentionActions(os.Stdout, format, res)
+}
+
+// retentionActionOutput is the serialised
+// form of a retention action.
+type retentionActionOutput struct {
+	ID     string   `json:"id" yaml:"id"`
+	Action string   `json:"action" yaml:"action"`
+	Fields []string `json:"fields,omitempty" yaml:"fields,omitempty"`
+	Rules  []string `json:"rules" yaml:"rules"`
+	Due    string   `json:"due" yaml:"due"`
+}
+
+// writeRetentionActions will write retention actions
+// to w using the requested output format. The raw
+// response is used for proto-text output.
+func writeRetentionActions(w io.Writer, format string, res *api.RetentionReportResponse) error {
+	if format == outputProtoText {
+		return writeProtoText(w, res)
+	}
+	out := make([]retentionActionOutput, 0, len(res.GetActions()))
+	rows := make([][]string, 0, len(res.GetActions()))
+	for _, action := range res.GetActions() {
+		ao := retentionActionOutput{
+			ID:     action.GetParticipantId(),
+			Action: "redact",
+			Fields: action.GetFields(),
+			Rules:  action.GetRules(),
+			Due:    action.GetDue().AsTime().Format(layoutISO),
+		}
+		if action.GetDelete() {
+			ao.Action = "delete"
+		}
+		out = append(out, ao)
+		rows = append(rows, []string{ao.ID, ao.Action, strings.Join(ao.Fields, "; "), strings.Join(ao.Rules, "; "), ao.Due})
+	}
+	return writeRecords(w, format, out, []string{"id", "action", "fields", "rules", "due"}, rows)
+}
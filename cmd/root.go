@@ -6,6 +6,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -16,6 +17,17 @@ const (
 	DefaultgRPCport      = "9090"
 	DefaultServerAddress = "localhost"
 	DefaultLogFile       = "./registry-microservice.log"
+	DefaultDrainTimeout  = 10 * time.Second
+	DefaultRegion        = "GB"
+
+	DefaultRetentionInterval = 24 * time.Hour
+	DefaultWebhookQueueDir   = "./registry-webhooks"
+	DefaultOutboxFile        = "./registry-outbox.jsonl"
+	DefaultIdempotencyWindow = 24 * time.Hour
+	DefaultHistoryLimit      = 100000
+
+	DefaultRequestTimeout    = 5 * time.Second
+	DefaultCompletionTimeout = 2 * time.Second
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -29,15 +41,32 @@ Currently supports:
 * retrieve
 * update
 * delete
+* list
 
 Run help on a subcommand to find out more.`,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return bindFlags(cmd)
+	},
+}
+
+// init the root command error handling
+func init() {
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError{err}
+	})
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	cmd, err := rootCmd.ExecuteC()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if exitCode(err) == ExitUsage {
+			fmt.Fprintf(os.Stderr, "Run '%v --help' for usage.\n", cmd.CommandPath())
+		}
+		os.Exit(exitCode(err))
 	}
 }
@@ -1,21 +1,33 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
 
+	"github.com/will-rowe/registry-microservice/pkg/allocate"
+	"github.com/will-rowe/registry-microservice/pkg/erasure"
+	"github.com/will-rowe/registry-microservice/pkg/idempotency"
+	"github.com/will-rowe/registry-microservice/pkg/normalise"
+	"github.com/will-rowe/registry-microservice/pkg/outbox"
 	server "github.com/will-rowe/registry-microservice/pkg/protocol/grpc"
-	service "github.com/will-rowe/registry-microservice/pkg/service/v1"
-)
-
-// command line arguments
-var (
-	grpcPort *string // TCP port to listen to by the gRPC server
-	logFile  *string // the log file
+	"github.com/will-rowe/registry-microservice/pkg/pseudonymise"
+	"github.com/will-rowe/registry-microservice/pkg/retention"
+	servicev1 "github.com/will-rowe/registry-microservice/pkg/service/v1"
+	servicev2 "github.com/will-rowe/registry-microservice/pkg/service/v2"
+	"github.com/will-rowe/registry-microservice/pkg/store"
+	"github.com/will-rowe/registry-microservice/pkg/webhook"
 )
 
 // serveCmd represents the serve command
@@ -25,7 +37,40 @@ var serveCmd = &cobra.Command{
 	Long: `Run the registry server using gRPC.
 
 Clients can then connect to the server and make CRUD requests
-for participants held in the registry.`,
+for participants held in the registry. The v1 and v2 APIs are
+served side-by-side and share the same participants.
+
+The server shuts down gracefully on SIGINT or SIGTERM, waiting
+for in-flight requests to finish before exiting. Requests still
+running after the drain timeout are cancelled.
+
+Retention rules given in the config file are enforced when
+the server starts and then at each retention interval (use 0
+to disable enforcement), redacting fields or deleting the
+participants they apply to. Use 'registry retention report'
+to see what the rules will do.
+
+Webhook subscriptions given in the config file are sent an
+HTTP POST when participants change. Deliveries are queued in
+the webhook queue directory, so they survive a restart, and
+retried with an exponential backoff until they are accepted.
+Deliveries are signed with a timestamp, so that they can not
+be replayed, and each URL is sent its deliveries separately.
+
+Outbox sinks given in the config file are sent every change,
+at least once, with an idempotency key. Changes are held in
+the outbox, which is written with the change, until every
+sink has accepted them. The outbox is appended to the outbox
+file, which is synced before the change is acknowledged, so
+changes which have not been published when the server stops
+or crashes are published when it is next started. During shut
+down, the server waits up to the drain timeout for the outbox
+to be published.
+
+Create, update and delete requests sent with an idempotency
+key have their result kept for the idempotency window (use 0
+to disable), and a retry with the same key is given the
+original result rather than being run again.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		runServer()
 	},
@@ -33,8 +78,18 @@ for participants held in the registry.`,
 
 // init the command line arguments and add the subcommand to the root
 func init() {
-	grpcPort = serveCmd.Flags().StringP("grpcPort", "g", DefaultgRPCport, "TCP port to listen to by the gRPC server")
-	logFile = serveCmd.Flags().StringP("logFile", "l", DefaultLogFile, "the file to write the server log to (use -l STDOUT for logging to standard out)")
+	serveCmd.Flags().StringP("grpcPort", "g", DefaultgRPCport, "TCP port to listen to by the gRPC server")
+	serveCmd.Flags().StringP("logFile", "l", DefaultLogFile, "the file to write the server log to (use -l STDOUT for logging to standard out)")
+	serveCmd.Flags().DurationP("drainTimeout", "d", DefaultDrainTimeout, "time to wait for in-flight requests to finish, and then for the outbox to be published, during shut down")
+	serveCmd.Flags().StringP("defaultRegion", "r", DefaultRegion, "region (ISO 3166-1 alpha-2) for phone numbers not in international format")
+	serveCmd.Flags().String("pseudonymKeyFile", "", fmt.Sprintf("file holding the secret key (at least %d bytes) for pseudonymised export, which is disabled if not set", pseudonymise.MinKeyLength))
+	serveCmd.Flags().String("erasureKeyFile", "", "file holding the hex Ed25519 private key seed which signs erasure receipts, a random key is used if not set")
+	serveCmd.Flags().Duration("retentionInterval", DefaultRetentionInterval, "time between enforcing the retention rules in the config file (0 disables enforcement)")
+	serveCmd.Flags().Int("historyLimit", DefaultHistoryLimit, "number of mutations to keep in the mutation history, dropping the oldest (0 keeps them all)")
+	serveCmd.Flags().String("webhookQueueDir", DefaultWebhookQueueDir, "directory to queue webhook deliveries in until they are sent (use \"\" to only hold them in memory)")
+	serveCmd.Flags().String("outboxFile", DefaultOutboxFile, "file to keep the outbox in until the changes are published to the outbox sinks (use \"\" to only hold them in memory)")
+	serveCmd.Flags().Duration("idempotencyWindow", DefaultIdempotencyWindow, "time to keep the results of requests with idempotency keys (0 disables idempotency keys)")
+	serveCmd.Flags().String("idPattern", "", fmt.Sprintf("allocate reference numbers to participants created without one, using this pattern (A letter, 9 digit, optional # check character, e.g. %v)", allocate.DefaultPattern))
 	rootCmd.AddCommand(serveCmd)
 }
 
@@ -42,8 +97,8 @@ func init() {
 func runServer() {
 
 	// set up the log
-	if *logFile != "STDOUT" {
-		file, err := os.OpenFile(*logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if logFile := viper.GetString(cfgLogFile); logFile != "STDOUT" {
+		file, err := os.OpenFile(logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%v\n", err)
 			os.Exit(1)
@@ -53,16 +108,272 @@ func runServer() {
 	}
 	log.Println("registry microservice launched")
 
-	// get top level context
-	ctx := context.Background()
+	// get top level context, cancelled on shut down signal
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// get the server API
-	serverAPI := service.NewRegistryService()
+	// get the server APIs, which share a participant store
+	normaliser, err := newNormaliser()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	var allocator *allocate.Allocator
+	if pattern := viper.GetString(cfgIDPattern); pattern != "" {
+		if allocator, err = allocate.New(pattern); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+	pseudonymiser, err := newPseudonymiser(viper.GetString(cfgPseudonymKey))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	signer, err := newErasureSigner(viper.GetString(cfgErasureKey))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	policy, err := newRetentionPolicy()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	dispatcher, err := newDispatcher()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	db := store.New()
+	db.SetRetention(policy)
+	db.SetHistoryLimit(viper.GetInt(cfgHistoryLimit))
+	dispatched := make(chan struct{})
+	if dispatcher != nil {
+		db.Observe(dispatcher)
+		go func() {
+			dispatcher.Run(ctx)
+			close(dispatched)
+		}()
+	}
+	sinks, err := newOutboxSinks()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	var relay *outbox.Relay
+	relayed := make(chan struct{})
+	if len(sinks) != 0 {
+		if err := openOutbox(db, viper.GetString(cfgOutboxFile)); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		relay = outbox.NewRelay(db, sinks)
+		go func() {
+			relay.Run(ctx)
+			close(relayed)
+		}()
+	}
+	var opts []grpc.ServerOption
+	if window := viper.GetDuration(cfgIdempotency); window > 0 {
+		cache := idempotency.New(window)
+		db.Observe(cache)
+		opts = append(opts, grpc.UnaryInterceptor(cache.UnaryServerInterceptor()))
+	}
+	// v1 requests are translated to and run by the v2 API
+	v2API := servicev2.NewRegistryService(db, normaliser, servicev2.Options{
+		Allocator:     allocator,
+		Pseudonymiser: pseudonymiser,
+		Signer:        signer,
+	})
+	v1API := servicev1.NewRegistryService(v2API)
+
+	// enforce the retention rules in the background
+	if interval := viper.GetDuration(cfgRetentionRun); policy != nil && interval > 0 {
+		go enforceRetention(ctx, db, interval)
+	}
 
 	// run the server until shutdown signal received
-	if err := server.RunServer(ctx, serverAPI, *grpcPort); err != nil {
+	err = server.RunServer(ctx, v1API, v2API, viper.GetString(cfgGRPCPort), viper.GetDuration(cfgDrainTimeout), opts...)
+
+	// persist the webhook deliveries queued during
+	// shut down and publish the changes left in the outbox
+	stop()
+	if dispatcher != nil {
+		<-dispatched
+		if err := dispatcher.Close(); err != nil {
+			log.Printf("could not persist webhook deliveries: %v", err)
+		}
+	}
+	if relay != nil {
+		<-relayed
+		drainOutbox(relay, viper.GetDuration(cfgDrainTimeout))
+	}
+	if err != nil {
 		log.Fatal(err)
 		os.Exit(1)
 	}
 	log.Println("finished")
 }
+
+// newPseudonymiser creates the pseudonymiser using the key held
+// in the key file. If there is no key file, pseudonymised export
+// is disabled, as pseudonyms must be the same in every export.
+func newPseudonymiser(keyFile string) (*pseudonymise.Pseudonymiser, error) {
+	if keyFile == "" {
+		log.Println("no pseudonym key file, pseudonymised export is disabled")
+		return nil, nil
+	}
+	key, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read pseudonym key file: %w", err)
+	}
+	return pseudonymise.New(bytes.TrimSpace(key))
+}
+
+// newErasureSigner creates the erasure receipt signer using the
+// hex key seed held in the key file. If there is no key file, a
+// random key is used and receipts can not be verified once the
+// server is restarted.
+func newErasureSigner(keyFile string) (*erasure.Signer, error) {
+	if keyFile == "" {
+		log.Println("no erasure key file, using a random key to sign erasure receipts")
+		return erasure.NewRandomSigner()
+	}
+	key, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read erasure key file: %w", err)
+	}
+	seed, err := hex.DecodeString(string(bytes.TrimSpace(key)))
+	if err != nil {
+		return nil, fmt.Errorf("could not read erasure key file: %w", err)
+	}
+	return erasure.NewSigner(seed)
+}
+
+// newNormaliser will create the normaliser, using the
+// default region and any postcode formats from the config.
+func newNormaliser() (*normalise.Normaliser, error) {
+	var postcodes []normalise.PostcodeFormat
+	if err := viper.UnmarshalKey(cfgPostcodes, &postcodes); err != nil {
+		return nil, fmt.Errorf("could not read postcode formats: %w", err)
+	}
+	return normalise.New(viper.GetString(cfgDefaultRegion), postcodes...)
+}
+
+// newRetentionPolicy creates the retention policy from the
+// rules in the config file, or nil if there are no rules.
+func newRetentionPolicy() (*retention.Policy, error) {
+	var rules []retention.Rule
+	if err := viper.UnmarshalKey(cfgRetention, &rules); err != nil {
+		return nil, fmt.Errorf("could not read retention rules: %w", err)
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	return retention.New(rules)
+}
+
+// enforceRetention will enforce the retention policy of the
+// store immediately and then at each interval, until the
+// context is cancelled. The actions are recorded in the
+// participant history, so only the counts are logged.
+func enforceRetention(ctx context.Context, db *store.Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		actions, err := db.EnforceRetention(time.Now())
+		if err != nil {
+			log.Printf("could not enforce retention rules: %v", err)
+		} else {
+			deleted := 0
+			for _, action := range actions {
+				if action.Delete {
+					deleted++
+				}
+			}
+			log.Printf("retention rules enforced: %d participants redacted, %d deleted", len(actions)-deleted, deleted)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// newDispatcher creates the webhook dispatcher from the
+// subscriptions in the config file, or nil if there are
+// no subscriptions.
+func newDispatcher() (*webhook.Dispatcher, error) {
+	var subscriptions []webhook.Subscription
+	if err := viper.UnmarshalKey(cfgWebhooks, &subscriptions); err != nil {
+		return nil, fmt.Errorf("could not read webhook subscriptions: %w", err)
+	}
+	if len(subscriptions) == 0 {
+		return nil, nil
+	}
+	dispatcher, err := webhook.New(subscriptions, viper.GetString(cfgWebhookQueue))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("sending webhooks to %d subscriptions, %d deliveries queued", len(subscriptions), dispatcher.Pending())
+	return dispatcher, nil
+}
+
+// drainOutbox will publish the changes left in the outbox
+// during shut down, giving up after the timeout, and then
+// close the outbox sinks.
+func drainOutbox(relay *outbox.Relay, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := relay.Drain(ctx); err != nil {
+		log.Printf("could not publish the outbox within %v: %v", timeout, err)
+	} else {
+		log.Println("outbox published")
+	}
+	if err := relay.Close(); err != nil {
+		log.Printf("could not close outbox sinks: %v", err)
+	}
+}
+
+// openOutbox will open the outbox file, if there is one,
+// so that the changes which were not published before the
+// server last stopped are published.
+func openOutbox(db *store.Store, path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := db.OpenOutbox(path); err != nil {
+		return err
+	}
+	entries, err := db.Outbox(0)
+	if err != nil {
+		return err
+	}
+	log.Printf("keeping the outbox in %v, %d changes to publish", path, len(entries))
+	return nil
+}
+
+// newOutboxSinks creates the outbox sinks from the config file.
+func newOutboxSinks() ([]outbox.Sink, error) {
+	var configs []outbox.SinkConfig
+	if err := viper.UnmarshalKey(cfgOutboxSinks, &configs); err != nil {
+		return nil, fmt.Errorf("could not read outbox sinks: %w", err)
+	}
+	sinks := make([]outbox.Sink, 0, len(configs))
+	for i, config := range configs {
+		sink, err := outbox.NewSink(config)
+		if err != nil {
+			for _, sink := range sinks {
+				sink.Close()
+			}
+			return nil, fmt.Errorf("invalid outbox sink %d (%v): %w", i+1, config.Type, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) != 0 {
+		log.Printf("publishing changes to %d outbox sinks", len(sinks))
+	}
+	return sinks, nil
+}
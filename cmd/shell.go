@@ -0,0 +1,371 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/peterh/liner"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	"github.com/will-rowe/registry-microservice/pkg/client"
+)
+
+// historyFile is the file in the user's home
+// directory that the shell history is kept in.
+const historyFile = ".registry/shell_history"
+
+// shellCmd represents the shell command
+var shellCmd = &cobra.Command{
+	Use:   "shell",
+	Short: "Start an interactive shell for the registry",
+	Long: `Start an interactive shell for the registry.
+
+The shell keeps a single connection open to the registry server,
+so many requests can be made without reconnecting. Commands and
+participant reference numbers can be tab completed, and the
+command history is kept between sessions.
+
+Type help in the shell for a list of commands.`,
+	Args: exactArgs(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runShell()
+	},
+}
+
+// init the command line arguments and add the subcommand to the root
+func init() {
+	shellCmd.Flags().StringP("serverAddress", "s", fmt.Sprintf("%s:%s", DefaultServerAddress, DefaultgRPCport), "address of the server hosting the registry service")
+	shellCmd.Flags().StringP("output", "o", outputTable, fmt.Sprintf("output format (%v)", strings.Join(outputFormats, "|")))
+	shellCommands = map[string]shellCommand{
+		"help":   {"help", "list the available commands", false, (*shell).help},
+		"get":    {"get <id>", "get a participant", true, (*shell).get},
+		"list":   {"list", "list all participants", false, (*shell).list},
+		"find":   {"find <text>", "find participants with an id, phone or address containing text", false, (*shell).find},
+		"edit":   {"edit <id> <field>=<value>...", "edit the phone, address or dob of a participant", true, (*shell).edit},
+		"delete": {"delete <id>", "delete a participant", true, (*shell).delete},
+		"output": {"output <format>", fmt.Sprintf("set the output format (%v)", strings.Join(outputFormats, "|")), false, (*shell).setOutput},
+		"exit":   {"exit", "exit the shell", false, nil},
+	}
+	rootCmd.AddCommand(shellCmd)
+}
+
+// shellCommand is a command run by the shell.
+type shellCommand struct {
+	usage       string                               // usage of the command
+	help        string                               // description of the command
+	completeIDs bool                                 // true if the first argument is a participant id
+	run         func(sh *shell, args []string) error // runs the command
+}
+
+// shellCommands are the commands available in the shell,
+// these are set during init as help refers to them.
+var shellCommands map[string]shellCommand
+
+// errExit is returned by a shell command
+// when the shell should exit.
+var errExit = errors.New("exit")
+
+// shell is an interactive registry shell which
+// reuses a single client connection.
+type shell struct {
+	client *client.Client
+	out    io.Writer
+	format string
+}
+
+// runShell will connect to the registry server
+// and run the interactive shell until exit.
+func runShell() error {
+	format := viper.GetString(cfgOutput)
+	if err := checkOutputFormat(format); err != nil {
+		return usageError{err}
+	}
+	c, err := newRegistryClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	sh := &shell{client: c, out: os.Stdout, format: format}
+
+	// set up the line editor
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	line.SetCompleter(sh.complete)
+	history := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		history = filepath.Join(home, historyFile)
+		if f, err := os.Open(history); err == nil {
+			line.ReadHistory(f)
+			f.Close()
+		}
+	}
+
+	// run commands until exit
+	fmt.Fprintf(sh.out, "connected to %v, type help for a list of commands\n", viper.GetString(cfgServerAddress))
+	for {
+		input, err := line.Prompt("registry> ")
+		if err == liner.ErrPromptAborted {
+			continue
+		}
+		if err != nil {
+			if err == io.EOF {
+				fmt.Fprintln(sh.out)
+				break
+			}
+			return err
+		}
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+		line.AppendHistory(input)
+		if err := sh.exec(input); err == errExit {
+			break
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+
+	// save the history
+	if history != "" {
+		if err := os.MkdirAll(filepath.Dir(history), 0700); err == nil {
+			if f, err := os.OpenFile(history, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600); err == nil {
+				line.WriteHistory(f)
+				f.Close()
+			}
+		}
+	}
+	return nil
+}
+
+// exec will parse and run a line of shell input.
+func (sh *shell) exec(input string) error {
+	args, err := splitArgs(input)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	command, ok := shellCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q, type help for a list of commands", args[0])
+	}
+	if command.run == nil {
+		return errExit
+	}
+	return command.run(sh, args[1:])
+}
+
+// complete returns the completions for the
+// shell input, completing command names and
+// then participant reference numbers.
+func (sh *shell) complete(input string) []string {
+	args := strings.Fields(input)
+	trailingSpace := strings.HasSuffix(input, " ")
+	switch {
+	case len(args) == 0 || (len(args) == 1 && !trailingSpace):
+		prefix := ""
+		if len(args) == 1 {
+			prefix = args[0]
+		}
+		completions := []string{}
+		for name := range shellCommands {
+			if strings.HasPrefix(name, prefix) {
+				completions = append(completions, name+" ")
+			}
+		}
+		sort.Strings(completions)
+		return completions
+	case (len(args) == 1 && trailingSpace) || (len(args) == 2 && !trailingSpace):
+		if !shellCommands[args[0]].completeIDs {
+			return nil
+		}
+		prefix := ""
+		if len(args) == 2 {
+			prefix = args[1]
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultCompletionTimeout)
+		defer cancel()
+		participants, err := sh.client.List(ctx)
+		if err != nil {
+			return nil
+		}
+		completions := []string{}
+		for _, p := range participants {
+			if strings.HasPrefix(p.GetId(), prefix) {
+				completions = append(completions, args[0]+" "+p.GetId()+" ")
+			}
+		}
+		return completions
+	default:
+		return nil
+	}
+}
+
+// help will print the shell commands.
+func (sh *shell) help(args []string) error {
+	names := make([]string, 0, len(shellCommands))
+	for name := range shellCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(sh.out, "  %-30s %v\n", shellCommands[name].usage, shellCommands[name].help)
+	}
+	return nil
+}
+
+// get will print a participant.
+func (sh *shell) get(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: get <id>")
+	}
+	p, err := sh.client.Get(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+	return writeParticipant(sh.out, sh.format, p)
+}
+
+// list will print all participants.
+func (sh *shell) list(args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: list")
+	}
+	participants, err := sh.client.List(context.Background())
+	if err != nil {
+		return err
+	}
+	return writeParticipantList(sh.out, sh.format, &api.ListResponse{ApiVersion: client.APIVersion, Participants: participants})
+}
+
+// find will print the participants with an id, phone
+// or address containing the search text.
+func (sh *shell) find(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: find <text>")
+	}
+	text := strings.ToLower(strings.Join(args, " "))
+	participants, err := sh.client.List(context.Background())
+	if err != nil {
+		return err
+	}
+	found := []*api.Participant{}
+	for _, p := range participants {
+		for _, field := range []string{p.GetId(), p.GetPhone(), p.GetAddress()} {
+			if strings.Contains(strings.ToLower(field), text) {
+				found = append(found, p)
+				break
+			}
+		}
+	}
+	return writeParticipantList(sh.out, sh.format, &api.ListResponse{ApiVersion: client.APIVersion, Participants: found})
+}
+
+// edit will update fields of a participant.
+func (sh *shell) edit(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: edit <id> <field>=<value>...")
+	}
+	p, revision, err := sh.client.GetWithRevision(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+	p = proto.Clone(p).(*api.Participant)
+	for _, arg := range args[1:] {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("fields must be given as <field>=<value>: %v", arg)
+		}
+		switch parts[0] {
+		case "phone":
+			p.Phone = parts[1]
+		case "address":
+			p.Address = parts[1]
+		case "dob":
+			dob, err := time.Parse(layoutISO, parts[1])
+			if err != nil {
+				return fmt.Errorf("date of birth must be YYYY-MM-DD: %w", err)
+			}
+			p.Dob = timestamppb.New(dob)
+		default:
+			return fmt.Errorf("unknown field %q: must be one of phone, address or dob", parts[0])
+		}
+	}
+	if err := sh.client.UpdateIfUnchanged(context.Background(), p, revision); err != nil {
+		return err
+	}
+	return writeParticipant(sh.out, sh.format, p)
+}
+
+// delete will delete a participant.
+func (sh *shell) delete(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: delete <id>")
+	}
+	if err := sh.client.Delete(context.Background(), args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(sh.out, "deleted %v\n", args[0])
+	return nil
+}
+
+// setOutput will set the shell output format.
+func (sh *shell) setOutput(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: output <format>, currently %v", sh.format)
+	}
+	if err := checkOutputFormat(args[0]); err != nil {
+		return err
+	}
+	sh.format = args[0]
+	return nil
+}
+
+// splitArgs will split shell input into arguments,
+// keeping quoted text together.
+func splitArgs(input string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range input {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	"github.com/will-rowe/registry-microservice/pkg/client"
+	mock "github.com/will-rowe/registry-microservice/pkg/mock"
+)
+
+// TestSplitArgs will check that shell input
+// is split into arguments.
+func TestSplitArgs(t *testing.T) {
+	args, err := splitArgs(`edit KFG-734 "address=house 1, street 2" phone=123`)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, args, []string{"edit", "KFG-734", "address=house 1, street 2", "phone=123"})
+	if _, err := splitArgs(`find "house`); err == nil {
+		t.Fatal("unterminated quote was accepted")
+	}
+}
+
+// TestShell will check that shell commands
+// reuse the client to make requests.
+func TestShell(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mock.NewMockRegistryServiceClient(ctrl)
+	participants := []*api.Participant{
+		{Id: "ABC-123", Phone: "123", Address: "house 1, street 2"},
+		{Id: "KFG-734", Phone: "456", Address: "flat 3, road 4"},
+	}
+	mockClient.EXPECT().List(gomock.Any(), gomock.Any()).AnyTimes().Return(&api.ListResponse{Participants: participants}, nil)
+	mockClient.EXPECT().Retrieve(gomock.Any(), gomock.Any()).Times(1).Return(&api.RetrieveResponse{Participant: participants[1]}, nil)
+	mockClient.EXPECT().Update(gomock.Any(), &api.UpdateRequest{
+		ApiVersion:  client.APIVersion,
+		Participant: &api.Participant{Id: "KFG-734", Phone: "789", Address: "flat 3, road 4"},
+	}).Times(1).Return(&api.UpdateResponse{Updated: true}, nil)
+	out := &bytes.Buffer{}
+	sh := &shell{client: client.NewFromService(mockClient), out: out, format: outputCSV}
+
+	// check find
+	assert.NilError(t, sh.exec("find STREET"))
+	assert.Equal(t, out.String(), "id,dob,phone,address\nABC-123,,123,\"house 1, street 2\"\n")
+
+	// check edit only changes the requested field
+	out.Reset()
+	assert.NilError(t, sh.exec("edit KFG-734 phone=789"))
+	assert.Equal(t, out.String(), "id,dob,phone,address\nKFG-734,,789,\"flat 3, road 4\"\n")
+
+	// check completion of commands and ids
+	assert.DeepEqual(t, sh.complete("de"), []string{"delete "})
+	assert.DeepEqual(t, sh.complete("get K"), []string{"get KFG-734 "})
+	assert.Equal(t, sh.exec("exit"), errExit)
+	if err := sh.exec("bogus"); err == nil {
+		t.Fatal("unknown command was accepted")
+	}
+}
@@ -5,13 +5,17 @@ go 1.16
 require (
 	github.com/golang/mock v1.5.0
 	github.com/golang/protobuf v1.4.3
+	github.com/nyaruka/phonenumbers v1.0.75
+	github.com/peterh/liner v1.2.1
 	github.com/spf13/cobra v1.1.3
+	github.com/spf13/viper v1.7.1
 	golang.org/x/net v0.0.0-20210226101413-39120d07d75e // indirect
 	golang.org/x/sys v0.0.0-20210225134936-a50acf3fe073 // indirect
-	golang.org/x/text v0.3.5 // indirect
+	golang.org/x/term v0.0.0-20201210144234-2321bbc49cbf
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 	google.golang.org/genproto v0.0.0-20210225212918-ad91960f0274 // indirect
 	google.golang.org/grpc v1.36.0
 	google.golang.org/protobuf v1.25.0
+	gopkg.in/yaml.v2 v2.4.0
 	gotest.tools v2.2.0+incompatible
 )
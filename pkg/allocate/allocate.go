@@ -0,0 +1,134 @@
+//Package allocate generates participant reference numbers
+//from a pattern, optionally including a check character so
+//that mistyped reference numbers can be detected.
+package allocate
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPattern gives reference numbers such as KFG-734X.
+const DefaultPattern = "AAA-999#"
+
+// pattern placeholders, other characters in
+// a pattern are copied to the reference number
+const (
+	letter = 'A'
+	digit  = '9'
+	check  = '#'
+)
+
+// alphabet holds the characters used by the check
+// character, which is calculated using Luhn mod N.
+const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// Allocator generates reference numbers from a pattern.
+type Allocator struct {
+
+	// pattern of the reference numbers
+	pattern string
+
+	// rand is the random number source
+	rand *rand.Rand
+
+	// rand lock
+	sync.Mutex
+}
+
+// New creates an Allocator for the pattern, where A is a
+// letter, 9 is a digit and # is the check character. The
+// pattern must have at least one letter or digit, and at
+// most one check character. Mistyped reference numbers
+// can only be detected if there is a check character.
+func New(pattern string) (*Allocator, error) {
+	if strings.Count(pattern, string(check)) > 1 {
+		return nil, fmt.Errorf("invalid reference number pattern %q: must have at most one check character (%c)", pattern, check)
+	}
+	if !strings.ContainsAny(pattern, string([]rune{letter, digit})) {
+		return nil, fmt.Errorf("invalid reference number pattern %q: must have a letter (%c) or digit (%c)", pattern, letter, digit)
+	}
+	return &Allocator{
+		pattern: pattern,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// Pattern returns the pattern of the reference numbers.
+func (a *Allocator) Pattern() string {
+	return a.pattern
+}
+
+// Next will return a random reference number, which may
+// have been returned before, so the caller must check it
+// is not in use.
+func (a *Allocator) Next() string {
+	a.Lock()
+	defer a.Unlock()
+	id := []byte(a.pattern)
+	for i, c := range id {
+		switch c {
+		case letter:
+			id[i] = alphabet[10+a.rand.Intn(26)]
+		case digit:
+			id[i] = alphabet[a.rand.Intn(10)]
+		}
+	}
+	if i := strings.IndexByte(a.pattern, check); i != -1 {
+		id[i] = checkCharacter(id, a.pattern)
+	}
+	return string(id)
+}
+
+// Matches returns true if the reference number has the
+// form of the pattern, without checking the value of
+// the check character.
+func (a *Allocator) Matches(id string) bool {
+	if len(id) != len(a.pattern) {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c, p := id[i], a.pattern[i]
+		switch {
+		case p == letter && (c < 'A' || c > 'Z'):
+			return false
+		case p == digit && (c < '0' || c > '9'):
+			return false
+		case p == check && strings.IndexByte(alphabet, c) == -1:
+			return false
+		case p != letter && p != digit && p != check && c != p:
+			return false
+		}
+	}
+	return true
+}
+
+// Valid returns true if the reference number matches
+// the pattern and, if the pattern has one, has the
+// correct check character.
+func (a *Allocator) Valid(id string) bool {
+	if !a.Matches(id) {
+		return false
+	}
+	i := strings.IndexByte(a.pattern, check)
+	return i == -1 || id[i] == checkCharacter([]byte(id), a.pattern)
+}
+
+// checkCharacter calculates the Luhn mod N check character
+// for the letters and digits of the reference number.
+func checkCharacter(id []byte, pattern string) byte {
+	n := len(alphabet)
+	factor, sum := 2, 0
+	for i := len(id) - 1; i >= 0; i-- {
+		if pattern[i] != letter && pattern[i] != digit {
+			continue
+		}
+		addend := factor * strings.IndexByte(alphabet, id[i])
+		factor = 3 - factor
+		sum += addend/n + addend%n
+	}
+	return alphabet[(n-sum%n)%n]
+}
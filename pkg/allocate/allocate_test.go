@@ -0,0 +1,77 @@
+package allocate
+
+import (
+	"regexp"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// TestNew will check that patterns
+// are validated.
+func TestNew(t *testing.T) {
+	for _, pattern := range []string{"AAA-999##", "-#", "---"} {
+		_, err := New(pattern)
+		assert.Assert(t, err != nil, pattern)
+	}
+	a, err := New(DefaultPattern)
+	assert.NilError(t, err)
+	assert.Equal(t, a.Pattern(), DefaultPattern)
+}
+
+// TestNoCheck will check that patterns
+// without a check character can be used.
+func TestNoCheck(t *testing.T) {
+	a, err := New("AAA-999")
+	assert.NilError(t, err)
+	format := regexp.MustCompile(`^[A-Z]{3}-[0-9]{3}$`)
+	for i := 0; i < 100; i++ {
+		id := a.Next()
+		assert.Assert(t, format.MatchString(id), id)
+		assert.Assert(t, a.Valid(id), id)
+	}
+	assert.Equal(t, a.Valid("KFG-734"), true)
+	assert.Equal(t, a.Valid("KFG-73A"), false)
+	assert.Equal(t, a.Valid("KFG-7340"), false)
+}
+
+// TestNext will check that allocated reference
+// numbers match the pattern and are valid.
+func TestNext(t *testing.T) {
+	a, err := New(DefaultPattern)
+	assert.NilError(t, err)
+	format := regexp.MustCompile(`^[A-Z]{3}-[0-9]{3}[0-9A-Z]$`)
+	for i := 0; i < 100; i++ {
+		id := a.Next()
+		assert.Assert(t, format.MatchString(id), id)
+		assert.Assert(t, a.Valid(id), id)
+	}
+}
+
+// TestValid will check that mistyped
+// reference numbers are detected.
+func TestValid(t *testing.T) {
+	a, err := New(DefaultPattern)
+	assert.NilError(t, err)
+	id := []byte(a.Next())
+	assert.Equal(t, a.Valid(string(id[:7])), false)
+
+	// changing any character or swapping adjacent
+	// characters invalidates the check character
+	for _, i := range []int{0, 1, 2, 4, 5, 6, 7} {
+		mistyped := append([]byte{}, id...)
+		if mistyped[i] == '0' || mistyped[i] == 'A' {
+			mistyped[i]++
+		} else {
+			mistyped[i]--
+		}
+		assert.Equal(t, a.Valid(string(mistyped)), false, string(mistyped))
+		assert.Equal(t, a.Matches(string(mistyped)), true, string(mistyped))
+	}
+	swapped := append([]byte{}, id...)
+	swapped[4], swapped[5] = swapped[5], swapped[4]
+	assert.Equal(t, a.Valid(string(swapped)), id[4] == id[5], string(swapped))
+	assert.Equal(t, a.Valid("KFG-734"), false)
+	assert.Equal(t, a.Matches("KFG-734"), false)
+	assert.Equal(t, a.Matches("KFG-734?"), false)
+}
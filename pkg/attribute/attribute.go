@@ -0,0 +1,116 @@
+//Package attribute validates custom participant attributes
+//against their attribute definitions.
+package attribute
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// layoutDate is the format of date attribute values.
+const layoutDate = "2006-01-02"
+
+// validName matches the allowed attribute names.
+var validName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Schema holds the attribute definitions, keyed by name.
+type Schema map[string]*api.AttributeDefinition
+
+// CheckDefinition checks that an attribute definition
+// is valid, returning an InvalidArgument error if not.
+func CheckDefinition(definition *api.AttributeDefinition) error {
+	if !validName.MatchString(definition.GetName()) {
+		return status.Errorf(codes.InvalidArgument,
+			"invalid attribute definition: name %q must be lower case letters, digits and underscores", definition.GetName())
+	}
+	switch definition.GetType() {
+	case api.AttributeType_ATTRIBUTE_TYPE_STRING, api.AttributeType_ATTRIBUTE_TYPE_INT, api.AttributeType_ATTRIBUTE_TYPE_DATE:
+		if len(definition.GetEnumValues()) != 0 {
+			return status.Errorf(codes.InvalidArgument,
+				"invalid attribute definition: enum values given for %v attribute %v", definition.GetType(), definition.GetName())
+		}
+	case api.AttributeType_ATTRIBUTE_TYPE_ENUM:
+		if len(definition.GetEnumValues()) == 0 {
+			return status.Errorf(codes.InvalidArgument,
+				"invalid attribute definition: no enum values given for enum attribute %v", definition.GetName())
+		}
+		seen := make(map[string]bool)
+		for _, value := range definition.GetEnumValues() {
+			if value == "" || seen[value] {
+				return status.Errorf(codes.InvalidArgument,
+					"invalid attribute definition: enum values for %v must be unique and not empty", definition.GetName())
+			}
+			seen[value] = true
+		}
+	default:
+		return status.Errorf(codes.InvalidArgument,
+			"invalid attribute definition: no type given for attribute %v", definition.GetName())
+	}
+	return nil
+}
+
+// Value will validate an attribute value against its
+// definition and return the value in canonical form,
+// i.e. trimmed, base 10 ints and YYYY-MM-DD dates.
+func Value(definition *api.AttributeDefinition, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch definition.GetType() {
+	case api.AttributeType_ATTRIBUTE_TYPE_INT:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", invalidValue(definition, value, "an integer")
+		}
+		return strconv.FormatInt(i, 10), nil
+	case api.AttributeType_ATTRIBUTE_TYPE_DATE:
+		date, err := time.Parse(layoutDate, value)
+		if err != nil {
+			return "", invalidValue(definition, value, "a date as YYYY-MM-DD")
+		}
+		return date.Format(layoutDate), nil
+	case api.AttributeType_ATTRIBUTE_TYPE_ENUM:
+		for _, allowed := range definition.GetEnumValues() {
+			if strings.EqualFold(value, allowed) {
+				return allowed, nil
+			}
+		}
+		return "", invalidValue(definition, value, "one of "+strings.Join(definition.GetEnumValues(), ", "))
+	}
+	return value, nil
+}
+
+// Attributes will validate participant attributes against
+// the schema, returning the attributes in canonical form.
+// An InvalidArgument error is returned for undefined
+// attributes and invalid values.
+func (schema Schema) Attributes(attributes map[string]string) (map[string]string, error) {
+	if len(attributes) == 0 {
+		return nil, nil
+	}
+	validated := make(map[string]string, len(attributes))
+	for name, value := range attributes {
+		definition, ok := schema[name]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"invalid attribute: attribute %q has not been defined", name)
+		}
+		value, err := Value(definition, value)
+		if err != nil {
+			return nil, err
+		}
+		validated[name] = value
+	}
+	return validated, nil
+}
+
+// invalidValue returns the error for an invalid value.
+func invalidValue(definition *api.AttributeDefinition, value, expected string) error {
+	return status.Errorf(codes.InvalidArgument,
+		"invalid attribute: value %q for attribute %v must be %v", value, definition.GetName(), expected)
+}
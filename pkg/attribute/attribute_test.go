@@ -0,0 +1,66 @@
+package attribute
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// TestCheckDefinition will check that invalid
+// attribute definitions are rejected.
+func TestCheckDefinition(t *testing.T) {
+	valid := []*api.AttributeDefinition{
+		{Name: "site", Type: api.AttributeType_ATTRIBUTE_TYPE_STRING},
+		{Name: "cohort_code", Type: api.AttributeType_ATTRIBUTE_TYPE_ENUM, EnumValues: []string{"A", "B"}},
+	}
+	for _, definition := range valid {
+		assert.NilError(t, CheckDefinition(definition))
+	}
+	invalid := []*api.AttributeDefinition{
+		{Name: "Site", Type: api.AttributeType_ATTRIBUTE_TYPE_STRING},
+		{Name: "site"},
+		{Name: "visits", Type: api.AttributeType_ATTRIBUTE_TYPE_INT, EnumValues: []string{"1"}},
+		{Name: "cohort_code", Type: api.AttributeType_ATTRIBUTE_TYPE_ENUM},
+		{Name: "cohort_code", Type: api.AttributeType_ATTRIBUTE_TYPE_ENUM, EnumValues: []string{"A", "A"}},
+	}
+	for _, definition := range invalid {
+		assert.Equal(t, status.Code(CheckDefinition(definition)), codes.InvalidArgument, definition.String())
+	}
+}
+
+// TestAttributes will check that attribute values are
+// validated and converted to their canonical form.
+func TestAttributes(t *testing.T) {
+	schema := Schema{
+		"site":        {Name: "site", Type: api.AttributeType_ATTRIBUTE_TYPE_STRING},
+		"visits":      {Name: "visits", Type: api.AttributeType_ATTRIBUTE_TYPE_INT},
+		"consented":   {Name: "consented", Type: api.AttributeType_ATTRIBUTE_TYPE_DATE},
+		"cohort_code": {Name: "cohort_code", Type: api.AttributeType_ATTRIBUTE_TYPE_ENUM, EnumValues: []string{"A1", "B2"}},
+	}
+	attributes, err := schema.Attributes(map[string]string{
+		"site":        " Leeds ",
+		"visits":      "007",
+		"consented":   "2021-03-04",
+		"cohort_code": "a1",
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, attributes, map[string]string{
+		"site":        "Leeds",
+		"visits":      "7",
+		"consented":   "2021-03-04",
+		"cohort_code": "A1",
+	})
+	for _, invalid := range []map[string]string{
+		{"clinician": "Dr Who"},
+		{"visits": "seven"},
+		{"consented": "04/03/2021"},
+		{"cohort_code": "C3"},
+	} {
+		_, err := schema.Attributes(invalid)
+		assert.Equal(t, status.Code(err), codes.InvalidArgument)
+	}
+}
@@ -0,0 +1,520 @@
+//Package client is a Go client library for the registry service.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+)
+
+// APIVersion is the registry service API
+// version used by the client.
+const APIVersion = "1"
+
+// default client options
+const (
+	DefaultTimeout    = 5 * time.Second
+	DefaultRetries    = 3
+	DefaultBackoff    = 100 * time.Millisecond
+	DefaultMaxBackoff = 2 * time.Second
+)
+
+// errors returned by the client, these can be
+// checked for using errors.Is
+var (
+	ErrNotFound      = errors.New("participant not found")
+	ErrAlreadyExists = errors.New("participant already exists")
+	ErrInvalid       = errors.New("invalid request")
+	ErrUnavailable   = errors.New("registry service unavailable")
+	ErrUnimplemented = errors.New("request not supported by registry service")
+	ErrConflict      = errors.New("participant modified concurrently")
+)
+
+// Client is a client for the registry service.
+type Client struct {
+	conn       *grpc.ClientConn
+	rpc        api.RegistryServiceClient
+	timeout    time.Duration
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	tlsConfig  *tls.Config
+	token      string
+	dialOpts   []grpc.DialOption
+}
+
+// Option is used to configure the Client.
+type Option func(*Client)
+
+// WithTimeout sets the time limit for each
+// request, including any retries. A timeout
+// of 0 means the request context is used as is.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetries sets how many times a request is retried
+// when the service is unavailable, and the initial
+// backoff between attempts, which doubles each retry.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// WithTLS sets the client to connect
+// using TLS with the provided config.
+func WithTLS(config *tls.Config) Option {
+	return func(c *Client) {
+		c.tlsConfig = config
+	}
+}
+
+// WithToken sets a bearer token to send in
+// the authorization metadata of each request.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithDialOptions adds gRPC dial options
+// to use when connecting to the server.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(c *Client) {
+		c.dialOpts = append(c.dialOpts, opts...)
+	}
+}
+
+// newClient returns a Client with the default
+// options and the provided options applied.
+func newClient(opts ...Option) *Client {
+	c := &Client{
+		timeout:    DefaultTimeout,
+		retries:    DefaultRetries,
+		backoff:    DefaultBackoff,
+		maxBackoff: DefaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// New creates a Client connected to the
+// registry service at the provided address.
+func New(address string, opts ...Option) (*Client, error) {
+	c := newClient(opts...)
+	dialOpts := []grpc.DialOption{}
+	if c.tlsConfig != nil {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(c.tlsConfig)))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithInsecure())
+	}
+	if c.token != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(tokenCredentials{
+			token:  c.token,
+			secure: c.tlsConfig != nil,
+		}))
+	}
+	conn, err := grpc.Dial(address, append(dialOpts, c.dialOpts...)...)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	c.rpc = api.NewRegistryServiceClient(conn)
+	return c, nil
+}
+
+// NewFromService creates a Client which uses the
+// provided service client, e.g. for testing.
+func NewFromService(rpc api.RegistryServiceClient, opts ...Option) *Client {
+	c := newClient(opts...)
+	c.rpc = rpc
+	return c
+}
+
+// Close will close the connection to the server.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Create will create a participant in the registry.
+func (c *Client) Create(ctx context.Context, participant *api.Participant) error {
+	_, err := c.CreateWithDuplicates(ctx, participant)
+	return err
+}
+
+// CreateWithDuplicates will create a participant in the
+// registry, returning any existing participants which may
+// be the same person. Possible duplicates are warnings and
+// do not stop the participant being created.
+func (c *Client) CreateWithDuplicates(ctx context.Context, participant *api.Participant) ([]*api.PossibleDuplicate, error) {
+	res, err := c.CreateWithResponse(ctx, participant)
+	return res.GetPossibleDuplicates(), err
+}
+
+// CreateWithResponse will create a participant in the
+// registry, returning the response of the server. This
+// includes the reference number of the participant, which
+// is allocated by the server if the participant does not
+// have one, and any possible duplicates.
+func (c *Client) CreateWithResponse(ctx context.Context, participant *api.Participant) (*api.CreateResponse, error) {
+	ctx, err := idempotent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var response *api.CreateResponse
+	err = c.call(ctx, func(ctx context.Context) error {
+		res, err := c.rpc.Create(ctx, &api.CreateRequest{
+			ApiVersion:  APIVersion,
+			Participant: participant,
+		})
+		if err == nil && !res.GetCreated() {
+			return status.Errorf(codes.Unknown, "participant was not created: %v", participant.GetId())
+		}
+		response = res
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Get will retrieve a participant from the registry.
+func (c *Client) Get(ctx context.Context, id string) (*api.Participant, error) {
+	var participant *api.Participant
+	err := c.call(ctx, func(ctx context.Context) error {
+		res, err := c.rpc.Retrieve(ctx, &api.RetrieveRequest{
+			ApiVersion: APIVersion,
+			Id:         id,
+		})
+		participant = res.GetParticipant()
+		return err
+	})
+	return participant, err
+}
+
+// GetWithRevision will retrieve a participant from the
+// registry, along with the revision of the participant
+// for use with UpdateIfUnchanged.
+func (c *Client) GetWithRevision(ctx context.Context, id string) (*api.Participant, uint64, error) {
+	var participant *api.Participant
+	var revision uint64
+	err := c.call(ctx, func(ctx context.Context) error {
+		res, err := c.rpc.Retrieve(ctx, &api.RetrieveRequest{
+			ApiVersion: APIVersion,
+			Id:         id,
+		})
+		participant = res.GetParticipant()
+		revision = res.GetRevision()
+		return err
+	})
+	return participant, revision, err
+}
+
+// Update will replace a participant in the registry.
+func (c *Client) Update(ctx context.Context, participant *api.Participant) error {
+	return c.UpdateIfUnchanged(ctx, participant, 0)
+}
+
+// UpdateIfUnchanged will replace a participant in the
+// registry, as long as the participant is still at the
+// provided revision. ErrConflict is returned if the
+// participant has been modified. A revision of 0 will
+// always update the participant.
+func (c *Client) UpdateIfUnchanged(ctx context.Context, participant *api.Participant, revision uint64) error {
+	_, err := c.UpdateWithResponse(ctx, participant, revision)
+	return err
+}
+
+// UpdateWithResponse will replace a participant in the
+// registry as for UpdateIfUnchanged, returning the
+// response of the server, which includes the new
+// revision of the participant.
+func (c *Client) UpdateWithResponse(ctx context.Context, participant *api.Participant, revision uint64) (*api.UpdateResponse, error) {
+	ctx, err := idempotent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var response *api.UpdateResponse
+	err = c.call(ctx, func(ctx context.Context) error {
+		res, err := c.rpc.Update(ctx, &api.UpdateRequest{
+			ApiVersion:       APIVersion,
+			Participant:      participant,
+			ExpectedRevision: revision,
+		})
+		if err == nil && !res.GetUpdated() {
+			return status.Errorf(codes.Unknown, "participant was not updated: %v", participant.GetId())
+		}
+		response = res
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Delete will delete a participant from the registry.
+func (c *Client) Delete(ctx context.Context, id string) error {
+	_, err := c.DeleteWithResponse(ctx, id)
+	return err
+}
+
+// DeleteWithResponse will delete a participant from the
+// registry, returning the response of the server.
+func (c *Client) DeleteWithResponse(ctx context.Context, id string) (*api.DeleteResponse, error) {
+	ctx, err := idempotent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var response *api.DeleteResponse
+	err = c.call(ctx, func(ctx context.Context) error {
+		res, err := c.rpc.Delete(ctx, &api.DeleteRequest{
+			ApiVersion: APIVersion,
+			Id:         id,
+		})
+		if err == nil && !res.GetDeleted() {
+			return status.Errorf(codes.Unknown, "participant was not deleted: %v", id)
+		}
+		response = res
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// List will list the participants in the registry,
+// excluding participants who have withdrawn.
+func (c *Client) List(ctx context.Context) ([]*api.Participant, error) {
+	return c.list(ctx, false)
+}
+
+// ListAll will list the participants in the registry,
+// including participants who have withdrawn.
+func (c *Client) ListAll(ctx context.Context) ([]*api.Participant, error) {
+	return c.list(ctx, true)
+}
+
+// list will list the participants in the registry.
+func (c *Client) list(ctx context.Context, includeWithdrawn bool) ([]*api.Participant, error) {
+	res, err := c.ListWithResponse(ctx, includeWithdrawn)
+	return res.GetParticipants(), err
+}
+
+// ListWithResponse will list the participants in the
+// registry, optionally including participants who have
+// withdrawn, returning the response of the server.
+func (c *Client) ListWithResponse(ctx context.Context, includeWithdrawn bool) (*api.ListResponse, error) {
+	var response *api.ListResponse
+	err := c.call(ctx, func(ctx context.Context) error {
+		res, err := c.rpc.List(ctx, &api.ListRequest{
+			ApiVersion:       APIVersion,
+			IncludeWithdrawn: includeWithdrawn,
+		})
+		response = res
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Search will find the participants in the registry with
+// the phone number, regardless of how it is formatted.
+func (c *Client) Search(ctx context.Context, phone string) ([]*api.Participant, error) {
+	res, err := c.SearchWithResponse(ctx, phone)
+	return res.GetParticipants(), err
+}
+
+// SearchWithResponse will search for participants by
+// phone number, returning the response of the server.
+func (c *Client) SearchWithResponse(ctx context.Context, phone string) (*api.SearchResponse, error) {
+	var response *api.SearchResponse
+	err := c.call(ctx, func(ctx context.Context) error {
+		res, err := c.rpc.Search(ctx, &api.SearchRequest{
+			ApiVersion: APIVersion,
+			Phone:      phone,
+		})
+		response = res
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// FindDuplicates will find participants which may be
+// registered more than once, or only the duplicates of the
+// participant if an id is given. The server default minimum
+// score is used if minScore is 0.
+func (c *Client) FindDuplicates(ctx context.Context, id string, minScore float64) ([]*api.PossibleDuplicate, error) {
+	res, err := c.FindDuplicatesWithResponse(ctx, id, minScore)
+	return res.GetDuplicates(), err
+}
+
+// FindDuplicatesWithResponse will find possible duplicates
+// as for FindDuplicates, returning the response of the server.
+func (c *Client) FindDuplicatesWithResponse(ctx context.Context, id string, minScore float64) (*api.FindDuplicatesResponse, error) {
+	var response *api.FindDuplicatesResponse
+	err := c.call(ctx, func(ctx context.Context) error {
+		res, err := c.rpc.FindDuplicates(ctx, &api.FindDuplicatesRequest{
+			ApiVersion: APIVersion,
+			Id:         id,
+			MinScore:   minScore,
+		})
+		response = res
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Erase will erase a participant from the registry,
+// returning the signed erasure receipt.
+func (c *Client) Erase(ctx context.Context, id string) (*api.ErasureReceipt, error) {
+	res, err := c.EraseWithResponse(ctx, id)
+	return res.GetReceipt(), err
+}
+
+// EraseWithResponse will erase a participant from the
+// registry, returning the response of the server.
+func (c *Client) EraseWithResponse(ctx context.Context, id string) (*api.EraseResponse, error) {
+	var response *api.EraseResponse
+	err := c.call(ctx, func(ctx context.Context) error {
+		res, err := c.rpc.Erase(ctx, &api.EraseRequest{
+			ApiVersion: APIVersion,
+			Id:         id,
+		})
+		response = res
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// ExportPseudonymised will get the participants in the
+// registry with their direct identifiers removed, with
+// their DOB coarsened to the requested precision.
+func (c *Client) ExportPseudonymised(ctx context.Context, dobPrecision api.DobPrecision) ([]*api.PseudonymisedParticipant, error) {
+	res, err := c.ExportPseudonymisedWithResponse(ctx, dobPrecision)
+	return res.GetParticipants(), err
+}
+
+// ExportPseudonymisedWithResponse will get the pseudonymised
+// participants as for ExportPseudonymised, returning the
+// response of the server.
+func (c *Client) ExportPseudonymisedWithResponse(ctx context.Context, dobPrecision api.DobPrecision) (*api.ExportPseudonymisedResponse, error) {
+	var response *api.ExportPseudonymisedResponse
+	err := c.call(ctx, func(ctx context.Context) error {
+		res, err := c.rpc.ExportPseudonymised(ctx, &api.ExportPseudonymisedRequest{
+			ApiVersion:   APIVersion,
+			DobPrecision: dobPrecision,
+		})
+		response = res
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// RetentionReport will get the actions the retention rules
+// of the server require at the provided time, without taking
+// them. The current time is used if asOf is zero.
+func (c *Client) RetentionReport(ctx context.Context, asOf time.Time) ([]*api.RetentionAction, error) {
+	res, err := c.RetentionReportWithResponse(ctx, asOf)
+	return res.GetActions(), err
+}
+
+// RetentionReportWithResponse will get the retention actions
+// as for RetentionReport, returning the response of the server.
+func (c *Client) RetentionReportWithResponse(ctx context.Context, asOf time.Time) (*api.RetentionReportResponse, error) {
+	request := &api.RetentionReportRequest{
+		ApiVersion: APIVersion,
+	}
+	if !asOf.IsZero() {
+		request.AsOf = timestamppb.New(asOf)
+	}
+	var response *api.RetentionReportResponse
+	err := c.call(ctx, func(ctx context.Context) error {
+		res, err := c.rpc.RetentionReport(ctx, request)
+		response = res
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// ServerInfo will get information about the server,
+// including the API versions it supports.
+func (c *Client) ServerInfo(ctx context.Context) (*api.GetServerInfoResponse, error) {
+	var info *api.GetServerInfoResponse
+	err := c.call(ctx, func(ctx context.Context) error {
+		res, err := c.rpc.GetServerInfo(ctx, &api.GetServerInfoRequest{
+			ApiVersion: APIVersion,
+		})
+		info = res
+		return err
+	})
+	return info, err
+}
+
+// call will run a request, retrying with an exponential
+// backoff if the service is unavailable, and map any
+// error to the client errors.
+func (c *Client) call(ctx context.Context, request func(context.Context) error) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		err := request(ctx)
+		if err == nil {
+			return nil
+		}
+		if status.Code(err) != codes.Unavailable || attempt >= c.retries {
+			return mapError(err)
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return mapError(err)
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	"github.com/will-rowe/registry-microservice/pkg/idempotency"
+	mock "github.com/will-rowe/registry-microservice/pkg/mock"
+)
+
+// TestClient_Get will check that requests are stamped
+// with the API version and responses are returned.
+func TestClient_Get(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mock.NewMockRegistryServiceClient(ctrl)
+	p := &api.Participant{Id: "KFG-734"}
+	mockClient.EXPECT().Retrieve(
+		gomock.Any(),
+		&api.RetrieveRequest{ApiVersion: APIVersion, Id: p.GetId()},
+	).Times(1).Return(&api.RetrieveResponse{ApiVersion: APIVersion, Participant: p}, nil)
+	c := NewFromService(mockClient)
+	res, err := c.Get(context.Background(), p.GetId())
+	assert.NilError(t, err)
+	assert.Equal(t, res.GetId(), p.GetId())
+}
+
+// TestClient_Errors will check that gRPC errors are
+// mapped to the client errors.
+func TestClient_Errors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mock.NewMockRegistryServiceClient(ctrl)
+	mockClient.EXPECT().Retrieve(gomock.Any(), gomock.Any()).Times(1).Return(nil, status.Error(codes.NotFound, "no participant"))
+	mockClient.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).Return(nil, status.Error(codes.AlreadyExists, "in use"))
+	c := NewFromService(mockClient)
+	_, err := c.Get(context.Background(), "KFG-734")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	assert.Equal(t, status.Code(err), codes.NotFound)
+	err = c.Create(context.Background(), &api.Participant{Id: "KFG-734"})
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("expected ErrAlreadyExists, got %v", err)
+	}
+}
+
+// TestClient_Retries will check that requests are
+// retried when the service is unavailable.
+func TestClient_Retries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mock.NewMockRegistryServiceClient(ctrl)
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	gomock.InOrder(
+		mockClient.EXPECT().Delete(gomock.Any(), gomock.Any()).Times(2).Return(nil, unavailable),
+		mockClient.EXPECT().Delete(gomock.Any(), gomock.Any()).Times(1).Return(&api.DeleteResponse{ApiVersion: APIVersion, Deleted: true}, nil),
+	)
+	c := NewFromService(mockClient, WithRetries(2, time.Millisecond))
+	assert.NilError(t, c.Delete(context.Background(), "KFG-734"))
+
+	// check the client gives up after the retries
+	mockClient.EXPECT().Delete(gomock.Any(), gomock.Any()).Times(2).Return(nil, unavailable)
+	c = NewFromService(mockClient, WithRetries(1, time.Millisecond))
+	if err := c.Delete(context.Background(), "KFG-734"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+}
+
+// TestClient_IdempotencyKey will check that mutating
+// requests send an idempotency key, which is kept
+// when the request is retried.
+func TestClient_IdempotencyKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mock.NewMockRegistryServiceClient(ctrl)
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	keys := []string{}
+	record := func(ctx context.Context, req *api.DeleteRequest, opts ...grpc.CallOption) {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		keys = append(keys, md.Get(idempotency.MetadataKey)...)
+	}
+	gomock.InOrder(
+		mockClient.EXPECT().Delete(gomock.Any(), gomock.Any()).Times(1).Do(record).Return(nil, unavailable),
+		mockClient.EXPECT().Delete(gomock.Any(), gomock.Any()).Times(2).Do(record).Return(&api.DeleteResponse{ApiVersion: APIVersion, Deleted: true}, nil),
+	)
+	c := NewFromService(mockClient, WithRetries(1, time.Millisecond))
+	assert.NilError(t, c.Delete(context.Background(), "KFG-734"))
+	assert.Equal(t, len(keys), 2)
+	assert.Assert(t, keys[0] != "")
+	assert.Equal(t, keys[0], keys[1])
+
+	// check a key from the context is used
+	assert.NilError(t, c.Delete(WithIdempotencyKey(context.Background(), "my-key"), "KFG-734"))
+	assert.Equal(t, keys[2], "my-key")
+}
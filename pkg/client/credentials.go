@@ -0,0 +1,21 @@
+package client
+
+import "context"
+
+// tokenCredentials sends a bearer token
+// in the metadata of each request.
+type tokenCredentials struct {
+	token  string
+	secure bool
+}
+
+// GetRequestMetadata returns the authorization metadata.
+func (tc tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + tc.token}, nil
+}
+
+// RequireTransportSecurity is true if the
+// token is only to be sent over TLS.
+func (tc tokenCredentials) RequireTransportSecurity() bool {
+	return tc.secure
+}
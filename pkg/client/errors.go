@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Error is returned by the client when a request fails,
+// it wraps one of the client errors (e.g. ErrNotFound)
+// and keeps the gRPC status returned by the server.
+type Error struct {
+	kind   error
+	status *status.Status
+}
+
+// Error returns the error message.
+func (e *Error) Error() string {
+	if e.kind == nil {
+		return e.status.Message()
+	}
+	return fmt.Sprintf("%v: %v", e.kind, e.status.Message())
+}
+
+// Unwrap returns the client error that
+// this error wraps, if any.
+func (e *Error) Unwrap() error {
+	return e.kind
+}
+
+// GRPCStatus returns the gRPC status
+// returned by the server.
+func (e *Error) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// mapError will map a gRPC error to
+// the corresponding client error.
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		st = status.FromContextError(err)
+	}
+	var kind error
+	switch st.Code() {
+	case codes.NotFound:
+		kind = ErrNotFound
+	case codes.AlreadyExists:
+		kind = ErrAlreadyExists
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		kind = ErrInvalid
+	case codes.Unavailable:
+		kind = ErrUnavailable
+	case codes.Unimplemented:
+		kind = ErrUnimplemented
+	case codes.Aborted:
+		kind = ErrConflict
+	case codes.DeadlineExceeded:
+		kind = context.DeadlineExceeded
+	case codes.Canceled:
+		kind = context.Canceled
+	}
+	return &Error{kind: kind, status: st}
+}
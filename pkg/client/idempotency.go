@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/will-rowe/registry-microservice/pkg/idempotency"
+)
+
+// idempotencyKey is the context key
+// holding the idempotency key.
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a context which sends the
+// idempotency key with create, update and delete requests.
+// If the server has already seen the key, it returns the
+// result of the original request rather than running the
+// request again, so a request which timed out can be
+// retried safely using the same key. Without a key, the
+// client uses a new key for each request, which is kept
+// when the request is retried by the client.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// NewIdempotencyKey returns a random idempotency key.
+func NewIdempotencyKey() (string, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// idempotent returns the context with the idempotency key
+// added to the request metadata, using a new key if the
+// context does not have one.
+func idempotent(ctx context.Context) (context.Context, error) {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	if key == "" {
+		var err error
+		if key, err = NewIdempotencyKey(); err != nil {
+			return nil, err
+		}
+	}
+	return metadata.AppendToOutgoingContext(ctx, idempotency.MetadataKey, key), nil
+}
@@ -0,0 +1,98 @@
+//Package consent manages the consent records held for participants.
+package consent
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// IsActive returns true if the consent
+// has not been withdrawn.
+func IsActive(consent *api.Consent) bool {
+	return consent.GetWithdrawn() == nil
+}
+
+// IsWithdrawn returns true if the participant has
+// consent records but none of them are active, i.e.
+// the participant has withdrawn all of their consent.
+// Participants without any consent records have not
+// withdrawn.
+func IsWithdrawn(p *api.Participant) bool {
+	for _, consent := range p.GetConsents() {
+		if IsActive(consent) {
+			return false
+		}
+	}
+	return len(p.GetConsents()) != 0
+}
+
+// Grant will return a copy of the participant with the consent
+// recorded at the provided time. If the participant has active
+// consent of the same type using an older version of the form,
+// it is withdrawn and replaced by the new consent.
+func Grant(p *api.Participant, consentType, formVersion string, now time.Time) (*api.Participant, *api.Consent, error) {
+	if consentType == "" || formVersion == "" {
+		return nil, nil, status.Error(codes.InvalidArgument,
+			"invalid consent: a consent type and form version are required")
+	}
+	timestamp, err := ptypes.TimestampProto(now)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "could not record consent time: %v", err)
+	}
+
+	// end any active consent of the same type
+	updated := proto.Clone(p).(*api.Participant)
+	for _, consent := range updated.GetConsents() {
+		if consent.GetType() != consentType || !IsActive(consent) {
+			continue
+		}
+		if consent.GetFormVersion() == formVersion {
+			return nil, nil, status.Errorf(codes.AlreadyExists,
+				"consent already granted: participant %v has active %v consent using form version %v", p.GetId(), consentType, formVersion)
+		}
+		consent.Withdrawn = timestamp
+	}
+
+	// record the new consent
+	consent := &api.Consent{
+		Type:        consentType,
+		FormVersion: formVersion,
+		Granted:     timestamp,
+	}
+	updated.Consents = append(updated.Consents, consent)
+	return updated, consent, nil
+}
+
+// Withdraw will return a copy of the participant with active
+// consent of the provided type withdrawn at the provided time.
+// All active consent is withdrawn if the type is empty.
+func Withdraw(p *api.Participant, consentType string, now time.Time) (*api.Participant, []*api.Consent, error) {
+	timestamp, err := ptypes.TimestampProto(now)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "could not record consent time: %v", err)
+	}
+	updated := proto.Clone(p).(*api.Participant)
+	withdrawn := []*api.Consent{}
+	for _, consent := range updated.GetConsents() {
+		if !IsActive(consent) || (consentType != "" && consent.GetType() != consentType) {
+			continue
+		}
+		consent.Withdrawn = timestamp
+		withdrawn = append(withdrawn, consent)
+	}
+	if len(withdrawn) == 0 && consentType == "" {
+		return nil, nil, status.Errorf(codes.FailedPrecondition,
+			"no active consent: participant %v has no active consent to withdraw", p.GetId())
+	}
+	if len(withdrawn) == 0 {
+		return nil, nil, status.Errorf(codes.FailedPrecondition,
+			"no active consent: participant %v has no active %v consent to withdraw", p.GetId(), consentType)
+	}
+	return updated, withdrawn, nil
+}
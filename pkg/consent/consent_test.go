@@ -0,0 +1,64 @@
+package consent
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// TestGrant will check that consent is recorded
+// and replaced when a new form version is signed.
+func TestGrant(t *testing.T) {
+	now := time.Now()
+	p := &api.Participant{Id: "KFG-734"}
+	assert.Equal(t, IsWithdrawn(p), false)
+	p, granted, err := Grant(p, "data-sharing", "1.0", now)
+	assert.NilError(t, err)
+	assert.Equal(t, IsActive(granted), true)
+
+	// the same form version can not be granted twice
+	_, _, err = Grant(p, "data-sharing", "1.0", now)
+	assert.Equal(t, status.Code(err), codes.AlreadyExists)
+	_, _, err = Grant(p, "", "1.0", now)
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+
+	// a new form version replaces the old consent
+	updated, _, err := Grant(p, "data-sharing", "2.0", now)
+	assert.NilError(t, err)
+	assert.Equal(t, len(updated.GetConsents()), 2)
+	assert.Equal(t, IsActive(updated.GetConsents()[0]), false)
+	assert.Equal(t, IsActive(updated.GetConsents()[1]), true)
+	assert.Equal(t, IsActive(p.GetConsents()[0]), true)
+}
+
+// TestWithdraw will check that consent is withdrawn
+// and participants without active consent are withdrawn.
+func TestWithdraw(t *testing.T) {
+	now := time.Now()
+	p := &api.Participant{Id: "KFG-734"}
+	_, _, err := Withdraw(p, "", now)
+	assert.Equal(t, status.Code(err), codes.FailedPrecondition)
+	p, _, err = Grant(p, "data-sharing", "1.0", now)
+	assert.NilError(t, err)
+	p, _, err = Grant(p, "contact", "1.0", now)
+	assert.NilError(t, err)
+
+	// withdraw one type of consent
+	p, withdrawn, err := Withdraw(p, "contact", now)
+	assert.NilError(t, err)
+	assert.Equal(t, len(withdrawn), 1)
+	assert.Equal(t, IsWithdrawn(p), false)
+	_, _, err = Withdraw(p, "contact", now)
+	assert.Equal(t, status.Code(err), codes.FailedPrecondition)
+
+	// withdraw everything
+	p, withdrawn, err = Withdraw(p, "", now)
+	assert.NilError(t, err)
+	assert.Equal(t, len(withdrawn), 1)
+	assert.Equal(t, IsWithdrawn(p), true)
+}
@@ -0,0 +1,160 @@
+//Package dedupe finds participants which may have been registered
+//more than once, e.g. under different reference numbers.
+package dedupe
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/normalise"
+)
+
+// DefaultMinScore is the lowest score reported as a possible
+// duplicate if no score is given. With the default weights, a
+// match needs the same date of birth and either a shared phone
+// number or the same address, so that e.g. people sharing a
+// household phone and address are not reported.
+const DefaultMinScore = 0.7
+
+// weights of each detail in the score,
+// which add up to 1
+const (
+	weightDOB     = 0.45
+	weightPhone   = 0.3
+	weightAddress = 0.25
+)
+
+// Match is a pair of participants which
+// may be the same person.
+type Match struct {
+
+	// ID of the participant
+	ID string
+
+	// DuplicateID is the ID of the possible duplicate
+	DuplicateID string
+
+	// Score between 0 and 1, higher scores
+	// are more likely to be duplicates
+	Score float64
+
+	// Reasons the participants matched
+	Reasons []string
+}
+
+// Score will score how likely it is that two participants
+// are the same person, using the date of birth, phone
+// numbers (which are normalised before storage) and the
+// similarity of their addresses.
+func Score(a, b *api.Participant) (float64, []string) {
+	score := 0.0
+	reasons := []string{}
+
+	// same date of birth
+	if a.GetDob() != nil && b.GetDob() != nil {
+		dobA, dobB := a.GetDob().AsTime().Format("2006-01-02"), b.GetDob().AsTime().Format("2006-01-02")
+		if dobA == dobB {
+			score += weightDOB
+			reasons = append(reasons, "same date of birth")
+		}
+	}
+
+	// any shared phone number
+	phones := make(map[string]bool)
+	for _, phone := range a.GetPhones() {
+		phones[phone.GetNumber()] = true
+	}
+	for _, phone := range b.GetPhones() {
+		if phones[phone.GetNumber()] {
+			score += weightPhone
+			reasons = append(reasons, fmt.Sprintf("shared phone number %v", phone.GetNumber()))
+			break
+		}
+	}
+
+	// similar addresses
+	if similarity := AddressSimilarity(a.GetAddress(), b.GetAddress()); similarity > 0 {
+		score += weightAddress * similarity
+		if similarity == 1 {
+			reasons = append(reasons, "same address")
+		} else {
+			reasons = append(reasons, fmt.Sprintf("similar address (%.0f%%)", similarity*100))
+		}
+	}
+	return score, reasons
+}
+
+// AddressSimilarity returns the proportion of words
+// shared by two addresses (the Jaccard index), ignoring
+// case and punctuation.
+func AddressSimilarity(a, b *api.PostalAddress) float64 {
+	wordsA, wordsB := words(a), words(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+	shared := 0
+	for word := range wordsA {
+		if wordsB[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wordsA)+len(wordsB)-shared)
+}
+
+// Find will return the participants which may be duplicates
+// of the participant, highest score first.
+func Find(p *api.Participant, participants []*api.Participant, minScore float64) []Match {
+	matches := []Match{}
+	for _, other := range participants {
+		if other.GetId() == p.GetId() {
+			continue
+		}
+		if score, reasons := Score(p, other); score >= minScore {
+			matches = append(matches, Match{p.GetId(), other.GetId(), score, reasons})
+		}
+	}
+	sortMatches(matches)
+	return matches
+}
+
+// Report will return every pair of participants which may
+// be duplicates, highest score first. Each pair is only
+// reported once. Every pair is compared, which is fine for
+// the size of the in-memory registry.
+func Report(participants []*api.Participant, minScore float64) []Match {
+	matches := []Match{}
+	for i, p := range participants {
+		for _, other := range participants[i+1:] {
+			if score, reasons := Score(p, other); score >= minScore {
+				matches = append(matches, Match{p.GetId(), other.GetId(), score, reasons})
+			}
+		}
+	}
+	sortMatches(matches)
+	return matches
+}
+
+// words returns the set of words in an address.
+func words(address *api.PostalAddress) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(normalise.AddressKey(address)) {
+		set[word] = true
+	}
+	return set
+}
+
+// sortMatches will sort matches by score,
+// then by the participant IDs.
+func sortMatches(matches []Match) {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if matches[i].ID != matches[j].ID {
+			return matches[i].ID < matches[j].ID
+		}
+		return matches[i].DuplicateID < matches[j].DuplicateID
+	})
+}
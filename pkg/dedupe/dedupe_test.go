@@ -0,0 +1,64 @@
+package dedupe
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// newParticipant is a helper function to
+// create a populated Participant struct
+// for use in the tests.
+func newParticipant(id string, dob time.Time, phone string, address ...string) *api.Participant {
+	return &api.Participant{
+		Id:      id,
+		Dob:     timestamppb.New(dob),
+		Phones:  []*api.PhoneNumber{{Number: phone}},
+		Address: &api.PostalAddress{Lines: address},
+	}
+}
+
+// TestScore will check that participants are
+// scored on their details.
+func TestScore(t *testing.T) {
+	dob := time.Date(1980, 1, 2, 0, 0, 0, 0, time.UTC)
+	a := newParticipant("KFG-734", dob, "+447700900123", "House 1", "Street 2")
+	tests := []struct {
+		other     *api.Participant
+		duplicate bool
+	}{
+		{newParticipant("ABC-123", dob, "+447700900123", "House 1 Street 2"), true},
+		{newParticipant("ABC-123", dob, "+447700900999", "house 1, street 2"), true},
+		{newParticipant("ABC-123", dob, "+447700900123", "Flat 9", "Road 3"), true},
+		{newParticipant("ABC-123", dob.AddDate(10, 0, 0), "+447700900123", "House 1", "Street 2"), false},
+		{newParticipant("ABC-123", dob, "+447700900999", "Flat 9", "Road 3"), false},
+	}
+	for i, test := range tests {
+		score, reasons := Score(a, test.other)
+		assert.Equal(t, score >= DefaultMinScore, test.duplicate, "test %d: %v %v", i, score, reasons)
+	}
+	assert.Equal(t, AddressSimilarity(a.GetAddress(), &api.PostalAddress{Lines: []string{"house 1 street 3"}}), 0.6)
+	assert.Equal(t, AddressSimilarity(a.GetAddress(), nil), 0.0)
+}
+
+// TestReport will check that each pair of possible
+// duplicates is reported once, highest score first.
+func TestReport(t *testing.T) {
+	dob := time.Date(1980, 1, 2, 0, 0, 0, 0, time.UTC)
+	participants := []*api.Participant{
+		newParticipant("ABC-123", dob, "+447700900123", "House 1"),
+		newParticipant("KFG-734", dob, "+447700900123", "House 1"),
+		newParticipant("LMN-456", dob, "+447700900123", "Flat 9"),
+		newParticipant("XYZ-999", dob.AddDate(1, 0, 0), "+447700900999", "Flat 9"),
+	}
+	matches := Report(participants, DefaultMinScore)
+	assert.Equal(t, len(matches), 3)
+	assert.Equal(t, matches[0].ID, "ABC-123")
+	assert.Equal(t, matches[0].DuplicateID, "KFG-734")
+	matches = Find(participants[3], participants, DefaultMinScore)
+	assert.Equal(t, len(matches), 0)
+}
@@ -0,0 +1,125 @@
+//Package erasure creates and verifies the signed receipts which are
+//kept when participants are erased from the registry. Receipts do
+//not identify the participant, but can be checked against the
+//erasure request using the nonce which is only given to the requester.
+package erasure
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// nonceLength is the number of random bytes in a nonce,
+// which is also used for the receipt id.
+const nonceLength = 16
+
+// Counts are the number of records erased for
+// a participant in each part of the store.
+type Counts struct {
+	Aliases     uint32
+	Enrollments uint32
+	Identifiers uint32
+	History     uint32
+}
+
+// Signer signs erasure receipts.
+type Signer struct {
+
+	// key is the signing key
+	key ed25519.PrivateKey
+}
+
+// NewSigner creates a Signer using
+// an Ed25519 private key seed.
+func NewSigner(seed []byte) (*Signer, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("erasure signing key must be a %d byte Ed25519 seed", ed25519.SeedSize)
+	}
+	return &Signer{
+		key: ed25519.NewKeyFromSeed(seed),
+	}, nil
+}
+
+// NewRandomSigner creates a Signer with a random key,
+// so receipts can only be verified whilst it is in use.
+func NewRandomSigner() (*Signer, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("could not create erasure signing key: %w", err)
+	}
+	return &Signer{
+		key: key,
+	}, nil
+}
+
+// PublicKey returns the key which verifies receipts.
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// Receipt will create a signed receipt for the
+// erasure of a participant at the provided time,
+// returning it with the nonce of its subject digest.
+// The nonce is not part of the receipt, so that the
+// receipt cannot be checked against reference numbers
+// by anyone but the requester.
+func (s *Signer) Receipt(id string, counts Counts, now time.Time) (*api.ErasureReceipt, string, error) {
+	random := make([]byte, 2*nonceLength)
+	if _, err := rand.Read(random); err != nil {
+		return nil, "", fmt.Errorf("could not create erasure receipt: %w", err)
+	}
+	erased, err := ptypes.TimestampProto(now.UTC().Truncate(time.Second))
+	if err != nil {
+		return nil, "", fmt.Errorf("could not create erasure receipt: %w", err)
+	}
+	nonce := hex.EncodeToString(random[nonceLength:])
+	receipt := &api.ErasureReceipt{
+		ReceiptId:              hex.EncodeToString(random[:nonceLength]),
+		Erased:                 erased,
+		SubjectDigest:          Digest(id, nonce),
+		AliasesErased:          counts.Aliases,
+		EnrollmentsErased:      counts.Enrollments,
+		IdentifiersErased:      counts.Identifiers,
+		HistoryEntriesScrubbed: counts.History,
+	}
+	receipt.Signature = ed25519.Sign(s.key, Canonical(receipt))
+	return receipt, nonce, nil
+}
+
+// Digest returns the subject digest of a receipt,
+// which is the hex SHA-256 of the nonce followed
+// by the participant reference number.
+func Digest(id, nonce string) string {
+	digest := sha256.Sum256([]byte(nonce + id))
+	return hex.EncodeToString(digest[:])
+}
+
+// Canonical returns the signed form of a receipt.
+func Canonical(receipt *api.ErasureReceipt) []byte {
+	erased := receipt.GetErased().AsTime().UTC().Format(time.RFC3339)
+	return []byte(fmt.Sprintf("registry-erasure-receipt\nreceipt_id=%s\nerased=%s\nsubject_digest=%s\naliases_erased=%d\nenrollments_erased=%d\nidentifiers_erased=%d\nhistory_entries_scrubbed=%d\n",
+		receipt.GetReceiptId(), erased, receipt.GetSubjectDigest(),
+		receipt.GetAliasesErased(), receipt.GetEnrollmentsErased(), receipt.GetIdentifiersErased(), receipt.GetHistoryEntriesScrubbed()))
+}
+
+// Verify returns true if the receipt was signed using the
+// public key and, if the reference number is not empty, is
+// for the erasure of that participant, which is checked
+// using the nonce given with the receipt.
+func Verify(receipt *api.ErasureReceipt, publicKey ed25519.PublicKey, id, nonce string) bool {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return false
+	}
+	if id != "" && Digest(id, nonce) != receipt.GetSubjectDigest() {
+		return false
+	}
+	return ed25519.Verify(publicKey, Canonical(receipt), receipt.GetSignature())
+}
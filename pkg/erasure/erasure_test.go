@@ -0,0 +1,33 @@
+package erasure
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+// TestReceipt will check that receipts can be verified
+// and that changes to them are detected.
+func TestReceipt(t *testing.T) {
+	_, err := NewSigner([]byte("short"))
+	assert.Assert(t, err != nil)
+	signer, err := NewSigner(bytes.Repeat([]byte{7}, 32))
+	assert.NilError(t, err)
+	receipt, nonce, err := signer.Receipt("KFG-734", Counts{Aliases: 1, History: 3}, time.Now())
+	assert.NilError(t, err)
+	assert.Assert(t, !bytes.Contains(Canonical(receipt), []byte("KFG-734")))
+	assert.Assert(t, !bytes.Contains(Canonical(receipt), []byte(nonce)))
+	assert.Equal(t, Verify(receipt, signer.PublicKey(), "KFG-734", nonce), true)
+	assert.Equal(t, Verify(receipt, signer.PublicKey(), "", ""), true)
+
+	// receipts are checked against the reference number, nonce and key
+	assert.Equal(t, Verify(receipt, signer.PublicKey(), "ABC-123", nonce), false)
+	assert.Equal(t, Verify(receipt, signer.PublicKey(), "KFG-734", ""), false)
+	other, err := NewRandomSigner()
+	assert.NilError(t, err)
+	assert.Equal(t, Verify(receipt, other.PublicKey(), "KFG-734", nonce), false)
+	receipt.HistoryEntriesScrubbed = 0
+	assert.Equal(t, Verify(receipt, signer.PublicKey(), "KFG-734", nonce), false)
+}
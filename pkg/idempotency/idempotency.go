@@ -0,0 +1,315 @@
+//Package idempotency lets clients safely retry the requests
+//which change participants. Requests sent with an idempotency
+//key have their result kept for a window, and a request with
+//the same key is given the original result rather than being
+//run again.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// request metadata keys
+const (
+
+	// MetadataKey is the request metadata
+	// key holding the idempotency key
+	MetadataKey = "idempotency-key"
+
+	// ReplayedKey is the response header metadata key
+	// which is set to "true" when a result is replayed
+	ReplayedKey = "idempotency-replayed"
+)
+
+// MaxKeyLength is the longest idempotency key accepted.
+const MaxKeyLength = 255
+
+// DefaultWindow is how long results are kept by default.
+const DefaultWindow = 24 * time.Hour
+
+// Methods are the gRPC methods which accept idempotency keys.
+var Methods = []string{
+	"/v1.RegistryService/Create",
+	"/v1.RegistryService/Update",
+	"/v1.RegistryService/Delete",
+	"/v2.RegistryService/Create",
+	"/v2.RegistryService/Update",
+	"/v2.RegistryService/Delete",
+}
+
+// transient are the error codes which are not kept, as
+// the request may succeed if it is run again.
+var transient = map[codes.Code]bool{
+	codes.Unknown:          true,
+	codes.Canceled:         true,
+	codes.DeadlineExceeded: true,
+	codes.Unavailable:      true,
+	codes.Internal:         true,
+}
+
+// result is the result of a request with an idempotency key.
+type result struct {
+	key            string
+	fingerprint    [sha256.Size]byte
+	participantIDs []string
+	response       proto.Message
+	err            error
+	expires        time.Time
+
+	// done is closed once the request has finished,
+	// kept is true if the result was kept
+	done chan struct{}
+	kept bool
+
+	// erased holds the participants erased whilst the
+	// request was running, whose results are not kept
+	erased map[string]bool
+}
+
+// Cache holds the results of requests with idempotency keys.
+// It is a store Observer, so that the results for erased
+// participants can be removed.
+type Cache struct {
+	window  time.Duration
+	methods map[string]bool
+	results map[string]*result
+
+	// expiry holds the kept results, oldest first
+	expiry []*result
+
+	sync.Mutex
+}
+
+// New creates a Cache which keeps results for the window.
+func New(window time.Duration) *Cache {
+	c := &Cache{
+		window:  window,
+		methods: make(map[string]bool),
+		results: make(map[string]*result),
+	}
+	for _, method := range Methods {
+		c.methods[method] = true
+	}
+	return c
+}
+
+// Len returns the number of results held.
+func (c *Cache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	c.expire(time.Now())
+	return len(c.results)
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor which
+// replays the result of a request if its idempotency key
+// has been seen within the window. A key can not be reused
+// for a different request. Concurrent requests with the
+// same key wait for the first request to finish, and
+// requests which fail with a transient error are not kept,
+// so that they can be retried.
+func (c *Cache) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		key := requestKey(ctx)
+		if !c.methods[info.FullMethod] || key == "" {
+			return handler(ctx, req)
+		}
+		if len(key) > MaxKeyLength {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"invalid idempotency key: keys must be at most %d characters", MaxKeyLength)
+		}
+		request, ok := req.(proto.Message)
+		if !ok {
+			return handler(ctx, req)
+		}
+		fingerprint, err := fingerprint(info.FullMethod, request)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "could not check idempotency key: %v", err)
+		}
+		for {
+			r, first := c.start(key, fingerprint, request)
+			if r.fingerprint != fingerprint {
+				return nil, status.Errorf(codes.InvalidArgument,
+					"idempotency key reused: key %q was used for a different request", key)
+			}
+			if first {
+				response, err := handler(ctx, req)
+				c.finish(r, response, err)
+				return response, err
+			}
+
+			// wait for the first request to finish, then
+			// replay its result or, if it was not kept,
+			// try to run the request again
+			select {
+			case <-ctx.Done():
+				return nil, status.FromContextError(ctx.Err()).Err()
+			case <-r.done:
+			}
+			if r.kept {
+				grpc.SetHeader(ctx, metadata.Pairs(ReplayedKey, "true"))
+				var response proto.Message
+				if r.response != nil {
+					response = proto.Clone(r.response)
+				}
+				return response, r.err
+			}
+		}
+	}
+}
+
+// start returns the result for the key, adding one if the
+// key has not been seen, in which case first is true.
+func (c *Cache) start(key string, fingerprint [sha256.Size]byte, request proto.Message) (*result, bool) {
+	c.Lock()
+	defer c.Unlock()
+	c.expire(time.Now())
+	if r, ok := c.results[key]; ok {
+		return r, false
+	}
+	r := &result{
+		key:         key,
+		fingerprint: fingerprint,
+		done:        make(chan struct{}),
+	}
+	if id := participantID(request); id != "" {
+		r.participantIDs = append(r.participantIDs, id)
+	}
+	c.results[key] = r
+	return r, true
+}
+
+// finish will keep the result of a request, unless
+// it failed with a transient error or the participant
+// was erased whilst the request was running.
+func (c *Cache) finish(r *result, response interface{}, err error) {
+	c.Lock()
+	defer c.Unlock()
+	defer close(r.done)
+	if err != nil && transient[status.Code(err)] {
+		delete(c.results, r.key)
+		return
+	}
+	m, ok := response.(proto.Message)
+	if ok && err == nil {
+		if id := participantID(m); id != "" {
+			r.participantIDs = append(r.participantIDs, id)
+		}
+	}
+	for _, id := range r.participantIDs {
+		if r.erased[id] {
+			delete(c.results, r.key)
+			return
+		}
+	}
+	if ok && err == nil {
+		r.response = proto.Clone(m)
+	}
+	r.err = err
+	r.expires = time.Now().Add(c.window)
+	r.kept = true
+	c.expiry = append(c.expiry, r)
+}
+
+// expire will remove the results which have expired, the
+// caller must hold the lock. Results removed on erasure
+// are skipped.
+func (c *Cache) expire(now time.Time) {
+	n := 0
+	for ; n < len(c.expiry) && !c.expiry[n].expires.After(now); n++ {
+		if c.results[c.expiry[n].key] == c.expiry[n] {
+			delete(c.results, c.expiry[n].key)
+		}
+		c.expiry[n] = nil
+	}
+	c.expiry = c.expiry[n:]
+}
+
+// Mutated does nothing, results are kept by the interceptor.
+func (c *Cache) Mutated(mutation *api.Mutation, previous *api.Participant) {}
+
+// Erased will remove the kept results for erased
+// participants, as they may hold participant details.
+// Requests which are still running are marked, so that
+// their results are not kept once they finish, as the
+// reference number allocated by a create is not yet
+// known. A request repeated after the erasure is run
+// again.
+func (c *Cache) Erased(ids []string) {
+	erased := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		erased[id] = true
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	for key, r := range c.results {
+		if !r.kept {
+			if r.erased == nil {
+				r.erased = make(map[string]bool, len(ids))
+			}
+			for _, id := range ids {
+				r.erased[id] = true
+			}
+			continue
+		}
+		for _, id := range r.participantIDs {
+			if erased[id] {
+				delete(c.results, key)
+				break
+			}
+		}
+	}
+}
+
+// requestKey returns the idempotency key of the request.
+func requestKey(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if keys := md.Get(MetadataKey); len(keys) != 0 {
+		return keys[0]
+	}
+	return ""
+}
+
+// fingerprint returns a digest of the method and
+// request, which identifies the request for a key.
+func fingerprint(method string, request proto.Message) ([sha256.Size]byte, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(request)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(append([]byte(method+"\n"), data...)), nil
+}
+
+// participantID returns the participant reference number
+// of a request or response, which is either the id field
+// or the id of the participant field.
+func participantID(m proto.Message) string {
+	message := m.ProtoReflect()
+	fields := message.Descriptor().Fields()
+	if field := fields.ByName("participant"); field != nil && field.Kind() == protoreflect.MessageKind {
+		participant := message.Get(field).Message()
+		if id := participant.Descriptor().Fields().ByName("id"); id != nil {
+			return participant.Get(id).String()
+		}
+	}
+	if field := fields.ByName("id"); field != nil && field.Kind() == protoreflect.StringKind {
+		return message.Get(field).String()
+	}
+	return ""
+}
@@ -0,0 +1,203 @@
+package idempotency
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gotest.tools/assert"
+
+	"github.com/will-rowe/registry-microservice/pkg/allocate"
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	"github.com/will-rowe/registry-microservice/pkg/normalise"
+	servicev1 "github.com/will-rowe/registry-microservice/pkg/service/v1"
+	servicev2 "github.com/will-rowe/registry-microservice/pkg/service/v2"
+	"github.com/will-rowe/registry-microservice/pkg/store"
+)
+
+// method info for the tests
+var (
+	createInfo = &grpc.UnaryServerInfo{FullMethod: "/v1.RegistryService/Create"}
+	updateInfo = &grpc.UnaryServerInfo{FullMethod: "/v1.RegistryService/Update"}
+	listInfo   = &grpc.UnaryServerInfo{FullMethod: "/v1.RegistryService/List"}
+)
+
+// withKey returns a context for a request
+// sent with the idempotency key.
+func withKey(key string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, key))
+}
+
+// newRequest returns a create request for a
+// participant without a reference number.
+func newRequest() *api.CreateRequest {
+	dob, _ := ptypes.TimestampProto(time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC))
+	return &api.CreateRequest{ApiVersion: "1", Participant: &api.Participant{
+		Dob:     dob,
+		Phone:   "+447700900123",
+		Address: "The moon",
+	}}
+}
+
+// TestReplay will check that a repeated create
+// request is given the original response rather
+// than allocating another reference number.
+func TestReplay(t *testing.T) {
+	normaliser, err := normalise.New("GB")
+	assert.NilError(t, err)
+	allocator, err := allocate.New(allocate.DefaultPattern)
+	assert.NilError(t, err)
+	rs := servicev1.NewRegistryService(servicev2.NewRegistryService(store.New(), normaliser, servicev2.Options{Allocator: allocator}))
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return rs.Create(ctx, req.(*api.CreateRequest))
+	}
+	cache := New(time.Hour)
+	interceptor := cache.UnaryServerInterceptor()
+
+	// the first request is run
+	res, err := interceptor(withKey("key-1"), newRequest(), createInfo, handler)
+	assert.NilError(t, err)
+	id := res.(*api.CreateResponse).GetId()
+	assert.Assert(t, id != "")
+
+	// the repeat is given the same reference number
+	res, err = interceptor(withKey("key-1"), newRequest(), createInfo, handler)
+	assert.NilError(t, err)
+	assert.Equal(t, res.(*api.CreateResponse).GetId(), id)
+	list, err := rs.List(context.Background(), &api.ListRequest{ApiVersion: "1"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(list.GetParticipants()), 1)
+
+	// a new key runs the request again
+	res, err = interceptor(withKey("key-2"), newRequest(), createInfo, handler)
+	assert.NilError(t, err)
+	assert.Assert(t, res.(*api.CreateResponse).GetId() != id)
+
+	// a key can not be reused for a different request
+	_, err = interceptor(withKey("key-1"), newRequest(), updateInfo, handler)
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+	other := newRequest()
+	other.Participant.Phone = "+447700900456"
+	_, err = interceptor(withKey("key-1"), other, createInfo, handler)
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+
+	// keys which are too long are rejected
+	_, err = interceptor(withKey(strings.Repeat("k", MaxKeyLength+1)), newRequest(), createInfo, handler)
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+	assert.Equal(t, cache.Len(), 2)
+}
+
+// TestErrors will check which errors are kept.
+func TestErrors(t *testing.T) {
+	calls := 0
+	code := codes.Unavailable
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return nil, status.Error(code, "failed")
+	}
+	cache := New(time.Hour)
+	interceptor := cache.UnaryServerInterceptor()
+
+	// transient errors are not kept
+	for i := 0; i < 2; i++ {
+		_, err := interceptor(withKey("key"), newRequest(), createInfo, handler)
+		assert.Equal(t, status.Code(err), codes.Unavailable)
+	}
+	assert.Equal(t, calls, 2)
+	assert.Equal(t, cache.Len(), 0)
+
+	// other errors are replayed
+	code = codes.AlreadyExists
+	for i := 0; i < 2; i++ {
+		_, err := interceptor(withKey("key"), newRequest(), createInfo, handler)
+		assert.Equal(t, status.Code(err), codes.AlreadyExists)
+	}
+	assert.Equal(t, calls, 3)
+
+	// requests without a key, or to other
+	// methods, are always run
+	_, err := interceptor(context.Background(), newRequest(), createInfo, handler)
+	assert.Equal(t, status.Code(err), codes.AlreadyExists)
+	_, err = interceptor(withKey("key"), &api.ListRequest{}, listInfo, handler)
+	assert.Equal(t, status.Code(err), codes.AlreadyExists)
+	assert.Equal(t, calls, 5)
+}
+
+// TestConcurrent will check that concurrent requests
+// with the same key are only run once.
+func TestConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		return &api.CreateResponse{ApiVersion: "1", Created: true, Id: "ABC-123"}, nil
+	}
+	interceptor := New(time.Hour).UnaryServerInterceptor()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := interceptor(withKey("key"), newRequest(), createInfo, handler)
+			assert.NilError(t, err)
+			assert.Equal(t, res.(*api.CreateResponse).GetId(), "ABC-123")
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, calls, 1)
+}
+
+// TestExpiry will check that results are removed
+// once the window has passed, or the participant
+// has been erased.
+func TestExpiry(t *testing.T) {
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &api.CreateResponse{ApiVersion: "1", Created: true, Id: "ABC-123"}, nil
+	}
+	cache := New(20 * time.Millisecond)
+	interceptor := cache.UnaryServerInterceptor()
+	_, err := interceptor(withKey("key"), newRequest(), createInfo, handler)
+	assert.NilError(t, err)
+	assert.Equal(t, cache.Len(), 1)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, cache.Len(), 0)
+
+	// the allocated reference number is used for erasure
+	cache = New(time.Hour)
+	interceptor = cache.UnaryServerInterceptor()
+	_, err = interceptor(withKey("key"), newRequest(), createInfo, handler)
+	assert.NilError(t, err)
+	cache.Erased([]string{"XYZ-999"})
+	assert.Equal(t, cache.Len(), 1)
+	cache.Erased([]string{"ABC-123"})
+	assert.Equal(t, cache.Len(), 0)
+
+	// results of requests running during the
+	// erasure are not kept once they finish
+	erase := make(chan struct{})
+	erased := make(chan struct{})
+	blocking := func(ctx context.Context, req interface{}) (interface{}, error) {
+		close(erase)
+		<-erased
+		return handler(ctx, req)
+	}
+	go func() {
+		<-erase
+		cache.Erased([]string{"ABC-123"})
+		close(erased)
+	}()
+	res, err := interceptor(withKey("key"), newRequest(), createInfo, blocking)
+	assert.NilError(t, err)
+	assert.Equal(t, res.(*api.CreateResponse).GetId(), "ABC-123")
+	assert.Equal(t, cache.Len(), 0)
+}
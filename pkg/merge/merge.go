@@ -0,0 +1,175 @@
+//Package merge combines the details of a participant which
+//has been registered more than once.
+package merge
+
+import (
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/normalise"
+)
+
+// attributePrefix prefixes the attribute name in
+// the resolution for a custom attribute.
+const attributePrefix = "attributes."
+
+// Fields are the participant fields which can
+// conflict, and so can be given a resolution.
+var Fields = []string{
+	"given_name",
+	"family_name",
+	"dob",
+	"sex_at_birth",
+	"address",
+	"preferred_contact_method",
+	"enrollment_date",
+}
+
+// Participants will return a copy of the survivor with the
+// details of the duplicate merged in. Fields only held by one
+// of the participants are kept, and phone numbers, email
+// addresses, external identifiers, consent records and
+// aliases are combined. Fields
+// held by both participants with different values are taken
+// from the participant given by their resolution, and a
+// FailedPrecondition error listing the fields is returned if
+// any conflicts are not resolved.
+func Participants(survivor, duplicate *api.Participant, resolutions map[string]api.MergeSource) (*api.Participant, error) {
+	if err := checkResolutions(resolutions); err != nil {
+		return nil, err
+	}
+	merged := proto.Clone(survivor).(*api.Participant)
+	from := proto.Clone(duplicate).(*api.Participant)
+	conflicts := []string{}
+
+	// merge the single value fields
+	m, d := merged.ProtoReflect(), from.ProtoReflect()
+	for _, name := range Fields {
+		field := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+		switch {
+		case !d.Has(field):
+		case !m.Has(field):
+			m.Set(field, d.Get(field))
+		case equal(field, m.Get(field), d.Get(field)):
+		case resolutions[name] == api.MergeSource_MERGE_SOURCE_SURVIVOR:
+		case resolutions[name] == api.MergeSource_MERGE_SOURCE_DUPLICATE:
+			m.Set(field, d.Get(field))
+		default:
+			conflicts = append(conflicts, name)
+		}
+	}
+
+	// merge the custom attributes
+	for name, value := range from.GetAttributes() {
+		current, ok := merged.GetAttributes()[name]
+		switch {
+		case !ok:
+			if merged.Attributes == nil {
+				merged.Attributes = make(map[string]string)
+			}
+			merged.Attributes[name] = value
+		case current == value:
+		case resolutions[attributePrefix+name] == api.MergeSource_MERGE_SOURCE_SURVIVOR:
+		case resolutions[attributePrefix+name] == api.MergeSource_MERGE_SOURCE_DUPLICATE:
+			merged.Attributes[name] = value
+		default:
+			conflicts = append(conflicts, attributePrefix+name)
+		}
+	}
+	if len(conflicts) != 0 {
+		sort.Strings(conflicts)
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"merge conflict: participants %v and %v have different %v, a resolution is required", survivor.GetId(), duplicate.GetId(), strings.Join(conflicts, ", "))
+	}
+
+	// combine the contact details, identifiers, consent and aliases
+	for _, phone := range from.GetPhones() {
+		if !hasPhone(merged, phone.GetNumber()) {
+			merged.Phones = append(merged.Phones, phone)
+		}
+	}
+	for _, email := range from.GetEmails() {
+		if !hasEmail(merged, email.GetAddress()) {
+			merged.Emails = append(merged.Emails, email)
+		}
+	}
+	for _, identifier := range from.GetIdentifiers() {
+		if !hasIdentifier(merged, identifier) {
+			merged.Identifiers = append(merged.Identifiers, identifier)
+		}
+	}
+	merged.Consents = append(merged.Consents, from.GetConsents()...)
+	merged.Aliases = append(append(merged.Aliases, from.GetId()), from.GetAliases()...)
+	return merged, nil
+}
+
+// checkResolutions returns an InvalidArgument error
+// if a resolution is not for a participant field.
+func checkResolutions(resolutions map[string]api.MergeSource) error {
+	for name := range resolutions {
+		if strings.HasPrefix(name, attributePrefix) && len(name) > len(attributePrefix) {
+			continue
+		}
+		known := false
+		for _, field := range Fields {
+			known = known || field == name
+		}
+		if !known {
+			return status.Errorf(codes.InvalidArgument,
+				"invalid resolution: %q is not a field which can be resolved (%v or %v<name>)", name, strings.Join(Fields, ", "), attributePrefix)
+		}
+	}
+	return nil
+}
+
+// equal returns true if the field values are the same,
+// addresses are compared ignoring their raw input.
+func equal(field protoreflect.FieldDescriptor, a, b protoreflect.Value) bool {
+	switch {
+	case field.Name() == "address":
+		return normalise.Equal(a.Message().Interface().(*api.PostalAddress), b.Message().Interface().(*api.PostalAddress))
+	case field.Message() != nil:
+		return proto.Equal(a.Message().Interface(), b.Message().Interface())
+	default:
+		return a.Interface() == b.Interface()
+	}
+}
+
+// hasPhone returns true if the participant
+// has the (normalised) phone number.
+func hasPhone(p *api.Participant, number string) bool {
+	for _, phone := range p.GetPhones() {
+		if phone.GetNumber() == number {
+			return true
+		}
+	}
+	return false
+}
+
+// hasEmail returns true if the participant has
+// the email address, ignoring case.
+func hasEmail(p *api.Participant, address string) bool {
+	for _, email := range p.GetEmails() {
+		if strings.EqualFold(email.GetAddress(), address) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasIdentifier returns true if the participant
+// has the external identifier.
+func hasIdentifier(p *api.Participant, identifier *api.ExternalIdentifier) bool {
+	for _, id := range p.GetIdentifiers() {
+		if id.GetSystem() == identifier.GetSystem() && id.GetValue() == identifier.GetValue() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package merge
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// TestParticipants will check that participant details
+// are combined and conflicts must be resolved.
+func TestParticipants(t *testing.T) {
+	survivor := &api.Participant{
+		Id:         "KFG-734",
+		GivenName:  "Ada",
+		FamilyName: "Lovelace",
+		Phones:     []*api.PhoneNumber{{Number: "+447700900123"}},
+		Emails:     []*api.EmailAddress{{Address: "ada@example.com"}},
+		Attributes: map[string]string{"site": "Leeds"},
+	}
+	duplicate := &api.Participant{
+		Id:         "ABC-123",
+		FamilyName: "King",
+		Address:    &api.PostalAddress{Lines: []string{"1 Crater Road"}},
+		Phones:     []*api.PhoneNumber{{Number: "+447700900123"}, {Number: "+442079460018"}},
+		Emails:     []*api.EmailAddress{{Address: "ADA@example.com"}},
+		Attributes: map[string]string{"site": "York", "cohort": "A"},
+		Aliases:    []string{"XYZ-999"},
+	}
+
+	// unresolved conflicts are listed
+	_, err := Participants(survivor, duplicate, nil)
+	assert.Equal(t, status.Code(err), codes.FailedPrecondition)
+	assert.ErrorContains(t, err, "attributes.site, family_name")
+	_, err = Participants(survivor, duplicate, map[string]api.MergeSource{"phones": api.MergeSource_MERGE_SOURCE_SURVIVOR})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+
+	// resolved conflicts take the value from the resolution
+	merged, err := Participants(survivor, duplicate, map[string]api.MergeSource{
+		"family_name":     api.MergeSource_MERGE_SOURCE_DUPLICATE,
+		"attributes.site": api.MergeSource_MERGE_SOURCE_SURVIVOR,
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, merged.GetId(), "KFG-734")
+	assert.Equal(t, merged.GetGivenName(), "Ada")
+	assert.Equal(t, merged.GetFamilyName(), "King")
+	assert.DeepEqual(t, merged.GetAddress().GetLines(), []string{"1 Crater Road"})
+	assert.Equal(t, len(merged.GetPhones()), 2)
+	assert.Equal(t, len(merged.GetEmails()), 1)
+	assert.DeepEqual(t, merged.GetAttributes(), map[string]string{"site": "Leeds", "cohort": "A"})
+	assert.DeepEqual(t, merged.GetAliases(), []string{"ABC-123", "XYZ-999"})
+	assert.Equal(t, survivor.GetFamilyName(), "Lovelace")
+}
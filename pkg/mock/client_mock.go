@@ -76,6 +76,126 @@ func (mr *MockRegistryServiceClientMockRecorder) Delete(arg0, arg1 interface{},
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRegistryServiceClient)(nil).Delete), varargs...)
 }
 
+// Erase mocks base method.
+func (m *MockRegistryServiceClient) Erase(arg0 context.Context, arg1 *v1.EraseRequest, arg2 ...grpc.CallOption) (*v1.EraseResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Erase", varargs...)
+	ret0, _ := ret[0].(*v1.EraseResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Erase indicates an expected call of Erase.
+func (mr *MockRegistryServiceClientMockRecorder) Erase(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Erase", reflect.TypeOf((*MockRegistryServiceClient)(nil).Erase), varargs...)
+}
+
+// ExportPseudonymised mocks base method.
+func (m *MockRegistryServiceClient) ExportPseudonymised(arg0 context.Context, arg1 *v1.ExportPseudonymisedRequest, arg2 ...grpc.CallOption) (*v1.ExportPseudonymisedResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ExportPseudonymised", varargs...)
+	ret0, _ := ret[0].(*v1.ExportPseudonymisedResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExportPseudonymised indicates an expected call of ExportPseudonymised.
+func (mr *MockRegistryServiceClientMockRecorder) ExportPseudonymised(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportPseudonymised", reflect.TypeOf((*MockRegistryServiceClient)(nil).ExportPseudonymised), varargs...)
+}
+
+// FindDuplicates mocks base method.
+func (m *MockRegistryServiceClient) FindDuplicates(arg0 context.Context, arg1 *v1.FindDuplicatesRequest, arg2 ...grpc.CallOption) (*v1.FindDuplicatesResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "FindDuplicates", varargs...)
+	ret0, _ := ret[0].(*v1.FindDuplicatesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindDuplicates indicates an expected call of FindDuplicates.
+func (mr *MockRegistryServiceClientMockRecorder) FindDuplicates(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindDuplicates", reflect.TypeOf((*MockRegistryServiceClient)(nil).FindDuplicates), varargs...)
+}
+
+// GetServerInfo mocks base method.
+func (m *MockRegistryServiceClient) GetServerInfo(arg0 context.Context, arg1 *v1.GetServerInfoRequest, arg2 ...grpc.CallOption) (*v1.GetServerInfoResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetServerInfo", varargs...)
+	ret0, _ := ret[0].(*v1.GetServerInfoResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetServerInfo indicates an expected call of GetServerInfo.
+func (mr *MockRegistryServiceClientMockRecorder) GetServerInfo(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetServerInfo", reflect.TypeOf((*MockRegistryServiceClient)(nil).GetServerInfo), varargs...)
+}
+
+// List mocks base method.
+func (m *MockRegistryServiceClient) List(arg0 context.Context, arg1 *v1.ListRequest, arg2 ...grpc.CallOption) (*v1.ListResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "List", varargs...)
+	ret0, _ := ret[0].(*v1.ListResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockRegistryServiceClientMockRecorder) List(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockRegistryServiceClient)(nil).List), varargs...)
+}
+
+// RetentionReport mocks base method.
+func (m *MockRegistryServiceClient) RetentionReport(arg0 context.Context, arg1 *v1.RetentionReportRequest, arg2 ...grpc.CallOption) (*v1.RetentionReportResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "RetentionReport", varargs...)
+	ret0, _ := ret[0].(*v1.RetentionReportResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RetentionReport indicates an expected call of RetentionReport.
+func (mr *MockRegistryServiceClientMockRecorder) RetentionReport(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetentionReport", reflect.TypeOf((*MockRegistryServiceClient)(nil).RetentionReport), varargs...)
+}
+
 // Retrieve mocks base method.
 func (m *MockRegistryServiceClient) Retrieve(arg0 context.Context, arg1 *v1.RetrieveRequest, arg2 ...grpc.CallOption) (*v1.RetrieveResponse, error) {
 	m.ctrl.T.Helper()
@@ -96,6 +216,46 @@ func (mr *MockRegistryServiceClientMockRecorder) Retrieve(arg0, arg1 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Retrieve", reflect.TypeOf((*MockRegistryServiceClient)(nil).Retrieve), varargs...)
 }
 
+// RetrieveErasureReceipt mocks base method.
+func (m *MockRegistryServiceClient) RetrieveErasureReceipt(arg0 context.Context, arg1 *v1.RetrieveErasureReceiptRequest, arg2 ...grpc.CallOption) (*v1.RetrieveErasureReceiptResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "RetrieveErasureReceipt", varargs...)
+	ret0, _ := ret[0].(*v1.RetrieveErasureReceiptResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RetrieveErasureReceipt indicates an expected call of RetrieveErasureReceipt.
+func (mr *MockRegistryServiceClientMockRecorder) RetrieveErasureReceipt(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetrieveErasureReceipt", reflect.TypeOf((*MockRegistryServiceClient)(nil).RetrieveErasureReceipt), varargs...)
+}
+
+// Search mocks base method.
+func (m *MockRegistryServiceClient) Search(arg0 context.Context, arg1 *v1.SearchRequest, arg2 ...grpc.CallOption) (*v1.SearchResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Search", varargs...)
+	ret0, _ := ret[0].(*v1.SearchResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockRegistryServiceClientMockRecorder) Search(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockRegistryServiceClient)(nil).Search), varargs...)
+}
+
 // Update mocks base method.
 func (m *MockRegistryServiceClient) Update(arg0 context.Context, arg1 *v1.UpdateRequest, arg2 ...grpc.CallOption) (*v1.UpdateResponse, error) {
 	m.ctrl.T.Helper()
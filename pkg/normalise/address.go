@@ -0,0 +1,226 @@
+package normalise
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// lineSeparator separates the parts of an
+// address when it is written as a single line.
+const lineSeparator = ", "
+
+// countryAliases maps common country names
+// to their ISO 3166-1 alpha-2 code.
+var countryAliases = map[string]string{
+	"UK":                       "GB",
+	"UNITED KINGDOM":           "GB",
+	"GREAT BRITAIN":            "GB",
+	"ENGLAND":                  "GB",
+	"SCOTLAND":                 "GB",
+	"WALES":                    "GB",
+	"NORTHERN IRELAND":         "GB",
+	"IRELAND":                  "IE",
+	"USA":                      "US",
+	"UNITED STATES":            "US",
+	"UNITED STATES OF AMERICA": "US",
+	"CANADA":                   "CA",
+	"NETHERLANDS":              "NL",
+}
+
+// postcodeFormat describes the postcode format for
+// a country. The pattern is matched against the postcode
+// with spaces and hyphens removed, and a matching postcode
+// has the separator inserted at the offset (counted from
+// the end if negative). Offsets past the end are skipped.
+type postcodeFormat struct {
+	pattern   *regexp.Regexp
+	offset    int
+	separator string
+}
+
+// PostcodeFormat configures the postcode format
+// for a country, adding to or replacing the
+// default formats.
+type PostcodeFormat struct {
+
+	// Country is the ISO 3166-1 alpha-2 code
+	Country string `mapstructure:"country"`
+
+	// Pattern is matched against the whole
+	// postcode, in upper case and with spaces
+	// and hyphens removed
+	Pattern string `mapstructure:"pattern"`
+
+	// Offset is where the separator is inserted,
+	// counted from the end if negative
+	Offset int `mapstructure:"offset"`
+
+	// Separator is inserted at the offset,
+	// a space if not set
+	Separator string `mapstructure:"separator"`
+}
+
+// defaultPostcodeFormats are the countries whose
+// postcodes are formatted by default.
+var defaultPostcodeFormats = map[string]postcodeFormat{
+	"GB": {regexp.MustCompile(`^[A-Z]{1,2}[0-9][A-Z0-9]?[0-9][A-Z]{2}$`), -3, " "},
+	"IE": {regexp.MustCompile(`^[A-Z][0-9][0-9W][A-Z0-9]{4}$`), 3, " "},
+	"CA": {regexp.MustCompile(`^[A-Z][0-9][A-Z][0-9][A-Z][0-9]$`), 3, " "},
+	"NL": {regexp.MustCompile(`^[1-9][0-9]{3}[A-Z]{2}$`), 4, " "},
+	"US": {regexp.MustCompile(`^[0-9]{5}([0-9]{4})?$`), 5, "-"},
+}
+
+// postcodeFormats returns the default postcode
+// formats with the configured formats added.
+func postcodeFormats(formats []PostcodeFormat) (map[string]postcodeFormat, error) {
+	compiled := make(map[string]postcodeFormat, len(defaultPostcodeFormats)+len(formats))
+	for country, format := range defaultPostcodeFormats {
+		compiled[country] = format
+	}
+	for _, format := range formats {
+		if err := checkRegion(format.Country); err != nil {
+			return nil, fmt.Errorf("invalid postcode format: %w", err)
+		}
+		pattern, err := regexp.Compile(`^(?:` + format.Pattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid postcode format for %v: %w", format.Country, err)
+		}
+		separator := format.Separator
+		if separator == "" {
+			separator = " "
+		}
+		compiled[format.Country] = postcodeFormat{pattern, format.Offset, separator}
+	}
+	return compiled, nil
+}
+
+// Address will return a normalised copy of the address.
+// Whitespace is collapsed, address lines are split on
+// commas, words in a single case are capitalised, the
+// country is converted to a country code and postcodes
+// are formatted for the countries with a default format.
+// The raw field is set to the address as provided.
+func Address(address *api.PostalAddress) *api.PostalAddress {
+	return normaliseAddress(address, defaultPostcodeFormats)
+}
+
+// Address will return a normalised copy of the address,
+// formatting postcodes for the configured countries.
+func (n *Normaliser) Address(address *api.PostalAddress) *api.PostalAddress {
+	return normaliseAddress(address, n.postcodes)
+}
+
+// normaliseAddress will normalise the address,
+// using the provided postcode formats.
+func normaliseAddress(address *api.PostalAddress, formats map[string]postcodeFormat) *api.PostalAddress {
+	if address == nil {
+		return nil
+	}
+	normalised := &api.PostalAddress{
+		Locality: capitalise(collapse(address.GetLocality())),
+		Region:   capitalise(collapse(address.GetRegion())),
+		Country:  country(address.GetCountry()),
+		Raw:      Format(address),
+	}
+	normalised.Postcode = postcode(address.GetPostcode(), normalised.GetCountry(), formats)
+	for _, line := range address.GetLines() {
+		for _, part := range strings.Split(line, ",") {
+			if part = capitalise(collapse(part)); part != "" {
+				normalised.Lines = append(normalised.Lines, part)
+			}
+		}
+	}
+	return normalised
+}
+
+// Equal returns true if two normalised addresses
+// are the same, ignoring their raw input.
+func Equal(a, b *api.PostalAddress) bool {
+	return Format(a) == Format(b)
+}
+
+// Format will write the address as a single line.
+func Format(address *api.PostalAddress) string {
+	parts := append([]string{}, address.GetLines()...)
+	for _, part := range []string{address.GetLocality(), address.GetRegion(), address.GetPostcode(), address.GetCountry()} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, lineSeparator)
+}
+
+// AddressKey returns a key for comparing addresses,
+// which ignores case, punctuation and line breaks. For
+// example, "House 1, Street 2" and "house 1 street 2"
+// have the same key.
+func AddressKey(address *api.PostalAddress) string {
+	words := strings.FieldsFunc(strings.ToLower(Format(address)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// collapse will trim the string and replace runs of
+// whitespace with a single space.
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// capitalise will capitalise the first letter of each
+// word which is all lower or all upper case. Words with
+// numbers, e.g. 12b or SW1A, are converted to upper case.
+// Mixed case words, e.g. McDonald, and upper case words
+// of up to two letters, e.g. PO, are left as they are.
+func capitalise(s string) string {
+	words := strings.Split(s, " ")
+	for i, word := range words {
+		switch {
+		case strings.IndexFunc(word, unicode.IsNumber) != -1:
+			words[i] = strings.ToUpper(word)
+		case word != strings.ToLower(word) && word != strings.ToUpper(word):
+		case word == strings.ToUpper(word) && len([]rune(word)) <= 2:
+		default:
+			runes := []rune(strings.ToLower(word))
+			runes[0] = unicode.ToUpper(runes[0])
+			words[i] = string(runes)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// country will convert a country to upper case,
+// using the country code if it is a known name.
+func country(s string) string {
+	s = strings.ToUpper(collapse(s))
+	if code, ok := countryAliases[s]; ok {
+		return code
+	}
+	return s
+}
+
+// postcode will convert a postcode to upper case and
+// format it if the country has a known postcode format.
+func postcode(s, country string, formats map[string]postcodeFormat) string {
+	s = strings.ToUpper(collapse(s))
+	format, ok := formats[country]
+	if !ok {
+		return s
+	}
+	compact := strings.NewReplacer(" ", "", "-", "").Replace(s)
+	if !format.pattern.MatchString(compact) {
+		return s
+	}
+	offset := format.offset
+	if offset < 0 {
+		offset += len(compact)
+	}
+	if offset <= 0 || offset >= len(compact) {
+		return compact
+	}
+	return compact[:offset] + format.separator + compact[offset:]
+}
@@ -0,0 +1,96 @@
+package normalise
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// TestAddress will check that addresses
+// are normalised.
+func TestAddress(t *testing.T) {
+	address := Address(&api.PostalAddress{
+		Lines:    []string{"  house 1,   STREET 2 ", "flat 12b"},
+		Locality: "little   WHINGING",
+		Region:   "Surrey",
+		Postcode: "sw1a1aa",
+		Country:  "united kingdom",
+	})
+	assert.DeepEqual(t, address.GetLines(), []string{"House 1", "Street 2", "Flat 12B"})
+	assert.Equal(t, address.GetLocality(), "Little Whinging")
+	assert.Equal(t, address.GetPostcode(), "SW1A 1AA")
+	assert.Equal(t, address.GetCountry(), "GB")
+	assert.Equal(t, address.GetRaw(), "  house 1,   STREET 2 , flat 12b, little   WHINGING, Surrey, sw1a1aa, united kingdom")
+
+	// mixed case words are kept
+	address = Address(&api.PostalAddress{Lines: []string{"1 McDonald road, PO box 7"}})
+	assert.DeepEqual(t, address.GetLines(), []string{"1 McDonald Road", "PO Box 7"})
+}
+
+// TestPostcode will check that postcodes are
+// formatted for the configured countries.
+func TestPostcode(t *testing.T) {
+	tests := []struct {
+		postcode, country, expected string
+	}{
+		{"sw1a1aa", "GB", "SW1A 1AA"},
+		{"m1 1ae", "GB", "M1 1AE"},
+		{"not a postcode", "GB", "NOT A POSTCODE"},
+		{"d02af30", "IE", "D02 AF30"},
+		{"k1a0b1", "CA", "K1A 0B1"},
+		{"1234ab", "NL", "1234 AB"},
+		{"12345", "US", "12345"},
+		{"123456789", "US", "12345-6789"},
+		{"75008", "FR", "75008"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, postcode(test.postcode, test.country, defaultPostcodeFormats), test.expected)
+	}
+}
+
+// TestNormaliser_Postcodes will check that postcode
+// formats can be added to the defaults or replace them.
+func TestNormaliser_Postcodes(t *testing.T) {
+	n, err := New("GB",
+		PostcodeFormat{Country: "PL", Pattern: "[0-9]{5}", Offset: 2, Separator: "-"},
+		PostcodeFormat{Country: "US", Pattern: "[0-9]{5}"},
+	)
+	assert.NilError(t, err)
+	assert.Equal(t, n.Address(&api.PostalAddress{Postcode: "00950", Country: "PL"}).GetPostcode(), "00-950")
+	assert.Equal(t, n.Address(&api.PostalAddress{Postcode: "123456789", Country: "US"}).GetPostcode(), "123456789")
+	assert.Equal(t, n.Address(&api.PostalAddress{Postcode: "sw1a1aa", Country: "GB"}).GetPostcode(), "SW1A 1AA")
+	assert.Equal(t, Address(&api.PostalAddress{Postcode: "00950", Country: "PL"}).GetPostcode(), "00950")
+
+	// formats must be for a known country and compile
+	_, err = New("GB", PostcodeFormat{Country: "XX", Pattern: "[0-9]{5}"})
+	assert.ErrorContains(t, err, "unknown region")
+	_, err = New("GB", PostcodeFormat{Country: "PL", Pattern: "[0-9"})
+	assert.ErrorContains(t, err, "invalid postcode format for PL")
+}
+
+// TestAddressKey will check that addresses which only
+// differ by case and punctuation have the same key.
+func TestAddressKey(t *testing.T) {
+	a := Address(&api.PostalAddress{Lines: []string{"House 1, Street 2"}})
+	b := Address(&api.PostalAddress{Lines: []string{"house 1 street 2"}})
+	assert.Equal(t, AddressKey(a), AddressKey(b))
+	assert.Equal(t, Equal(a, b), false)
+}
+
+// TestParticipant will check that the raw address
+// is kept when an update does not change the address.
+func TestParticipant(t *testing.T) {
+	n, err := New("GB")
+	assert.NilError(t, err)
+	created, err := n.Participant(&api.Participant{Id: "KFG-734", Address: &api.PostalAddress{Lines: []string{"house 1,street 2"}}}, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, created.GetAddress().GetRaw(), "house 1,street 2")
+	updated, err := n.Participant(&api.Participant{Id: "KFG-734", Address: &api.PostalAddress{Lines: []string{"House 1", "Street 2"}}}, created)
+	assert.NilError(t, err)
+	assert.Equal(t, updated.GetAddress().GetRaw(), "house 1,street 2")
+	updated, err = n.Participant(&api.Participant{Id: "KFG-734", Address: &api.PostalAddress{Lines: []string{"House 3"}}}, created)
+	assert.NilError(t, err)
+	assert.Equal(t, updated.GetAddress().GetRaw(), "House 3")
+}
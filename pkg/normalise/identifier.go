@@ -0,0 +1,45 @@
+package normalise
+
+import (
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// Identifier will return a normalised copy of an external
+// identifier. The system is trimmed and lower cased, and
+// whitespace is removed from the value so that numbers
+// written in groups (e.g. 943 476 5919) match. An
+// InvalidArgument error is returned if either is empty.
+func Identifier(identifier *api.ExternalIdentifier) (*api.ExternalIdentifier, error) {
+	normalised := &api.ExternalIdentifier{
+		System: strings.ToLower(collapse(identifier.GetSystem())),
+		Value:  strings.Join(strings.Fields(identifier.GetValue()), ""),
+	}
+	if normalised.System == "" || normalised.Value == "" {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"invalid external identifier: a system and value are required (got %q and %q)", identifier.GetSystem(), identifier.GetValue())
+	}
+	return normalised, nil
+}
+
+// identifiers will normalise external identifiers,
+// dropping any that are given more than once.
+func identifiers(ids []*api.ExternalIdentifier) ([]*api.ExternalIdentifier, error) {
+	normalised := []*api.ExternalIdentifier{}
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		n, err := Identifier(id)
+		if err != nil {
+			return nil, err
+		}
+		if key := n.GetSystem() + ":" + n.GetValue(); !seen[key] {
+			seen[key] = true
+			normalised = append(normalised, n)
+		}
+	}
+	return normalised, nil
+}
@@ -0,0 +1,27 @@
+package normalise
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// TestIdentifier will check that external
+// identifiers are normalised.
+func TestIdentifier(t *testing.T) {
+	identifier, err := Identifier(&api.ExternalIdentifier{System: " NHS ", Value: "943 476 5919"})
+	assert.NilError(t, err)
+	assert.Equal(t, identifier.GetSystem(), "nhs")
+	assert.Equal(t, identifier.GetValue(), "9434765919")
+	_, err = Identifier(&api.ExternalIdentifier{System: "mrn-leeds"})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+
+	// repeated identifiers are dropped
+	ids, err := identifiers([]*api.ExternalIdentifier{{System: "nhs", Value: "9434765919"}, {System: "NHS", Value: "943 476 5919"}})
+	assert.NilError(t, err)
+	assert.Equal(t, len(ids), 1)
+}
@@ -0,0 +1,86 @@
+//Package normalise cleans up participant details before they are
+//stored, so that the same details entered in different ways are
+//stored in the same way. The normaliser works offline.
+package normalise
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// Normaliser normalises participant details.
+type Normaliser struct {
+
+	// defaultRegion is the region used for phone
+	// numbers not in international format, when the
+	// participant's address does not have a country
+	defaultRegion string
+
+	// postcodes are the postcode formats by country
+	postcodes map[string]postcodeFormat
+}
+
+// New creates a Normaliser which uses the provided
+// default region (an ISO 3166-1 alpha-2 code) for
+// phone numbers. The postcode formats are added to,
+// or replace, the default formats.
+func New(defaultRegion string, postcodes ...PostcodeFormat) (*Normaliser, error) {
+	if err := checkRegion(defaultRegion); err != nil {
+		return nil, err
+	}
+	formats, err := postcodeFormats(postcodes)
+	if err != nil {
+		return nil, err
+	}
+	return &Normaliser{
+		defaultRegion: defaultRegion,
+		postcodes:     formats,
+	}, nil
+}
+
+// Participant will return a normalised copy of the participant.
+// If the previous participant is provided (i.e. for an update)
+// and the normalised address is unchanged, the raw address is
+// kept from the previous participant. An InvalidArgument error
+// is returned if a phone number is not possible or an external
+// identifier is incomplete, or if the participant is missing.
+func (n *Normaliser) Participant(p, previous *api.Participant) (*api.Participant, error) {
+	if p == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid participant: no participant details provided")
+	}
+	normalised := proto.Clone(p).(*api.Participant)
+	normalised.Address = n.Address(p.GetAddress())
+	if normalised.Address != nil && previous.GetAddress() != nil && Equal(normalised.GetAddress(), previous.GetAddress()) {
+		normalised.Address.Raw = previous.GetAddress().GetRaw()
+	}
+
+	// phone numbers use the address country as their region
+	region := n.defaultRegion
+	if country := normalised.GetAddress().GetCountry(); checkRegion(country) == nil {
+		region = country
+	}
+	for _, phone := range normalised.GetPhones() {
+		number, err := Phone(phone.GetNumber(), region)
+		if err != nil {
+			return nil, err
+		}
+		phone.Number = number
+	}
+	if len(normalised.GetIdentifiers()) != 0 {
+		ids, err := identifiers(normalised.GetIdentifiers())
+		if err != nil {
+			return nil, err
+		}
+		normalised.Identifiers = ids
+	}
+	return normalised, nil
+}
+
+// Phone will canonicalise a phone number to E.164 using
+// the default region if it is not in international format.
+func (n *Normaliser) Phone(number string) (string, error) {
+	return Phone(number, n.defaultRegion)
+}
@@ -0,0 +1,36 @@
+package normalise
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Phone will canonicalise a phone number to E.164, using
+// the region (an ISO 3166-1 alpha-2 code) if the number is
+// not in international format. An InvalidArgument error is
+// returned if the number can not be a phone number.
+func Phone(number, region string) (string, error) {
+	parsed, err := phonenumbers.Parse(strings.TrimSpace(number), region)
+	if err != nil {
+		return "", status.Errorf(codes.InvalidArgument,
+			"invalid phone number: could not parse %q (%v)", number, err)
+	}
+	if !phonenumbers.IsPossibleNumber(parsed) {
+		return "", status.Errorf(codes.InvalidArgument,
+			"invalid phone number: %q is not a possible phone number", number)
+	}
+	return phonenumbers.Format(parsed, phonenumbers.E164), nil
+}
+
+// checkRegion checks that the region has phone
+// number metadata.
+func checkRegion(region string) error {
+	if phonenumbers.GetCountryCodeForRegion(region) == 0 {
+		return fmt.Errorf("unknown region for phone numbers: %q", region)
+	}
+	return nil
+}
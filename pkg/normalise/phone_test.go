@@ -0,0 +1,53 @@
+package normalise
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// TestPhone will check that phone numbers are
+// canonicalised to E.164.
+func TestPhone(t *testing.T) {
+	tests := []struct {
+		number, region, expected string
+	}{
+		{"07700 900123", "GB", "+447700900123"},
+		{"(07700) 900-123\n", "GB", "+447700900123"},
+		{"+44 7700 900123", "US", "+447700900123"},
+		{"00447700900123", "GB", "+447700900123"},
+		{"(201) 555-0123", "US", "+12015550123"},
+	}
+	for _, test := range tests {
+		number, err := Phone(test.number, test.region)
+		assert.NilError(t, err)
+		assert.Equal(t, number, test.expected)
+	}
+
+	// impossible numbers are rejected
+	for _, number := range []string{"123", "", "not a number", "+44 7700 900123 456 789"} {
+		_, err := Phone(number, "GB")
+		assert.Equal(t, status.Code(err), codes.InvalidArgument, number)
+	}
+}
+
+// TestNormaliser_Phones will check that the address country
+// is used as the region for a participant's phone numbers.
+func TestNormaliser_Phones(t *testing.T) {
+	_, err := New("XX")
+	assert.ErrorContains(t, err, "unknown region")
+	n, err := New("GB")
+	assert.NilError(t, err)
+	p, err := n.Participant(&api.Participant{
+		Address: &api.PostalAddress{Country: "usa"},
+		Phones:  []*api.PhoneNumber{{Number: "201-555-0123"}},
+	}, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, p.GetPhones()[0].GetNumber(), "+12015550123")
+	_, err = n.Participant(&api.Participant{Phones: []*api.PhoneNumber{{Number: "123"}}}, nil)
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+}
@@ -0,0 +1,262 @@
+//Package outbox relays the participant changes held in the
+//store outbox to sinks, such as a file or a message broker.
+//Entries are only removed from the outbox once every sink
+//has accepted them, so each change is delivered at least
+//once, with an idempotency key to detect redeliveries.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/store"
+	"github.com/will-rowe/registry-microservice/pkg/webhook"
+)
+
+// default relay options
+const (
+	DefaultBackoff    = time.Second
+	DefaultMaxBackoff = time.Minute
+	DefaultBatchSize  = 100
+)
+
+// Message is an outbox entry to publish.
+type Message struct {
+
+	// Key is the idempotency key, which is the same
+	// each time the entry is delivered
+	Key string
+
+	// Subject of the message, participant.<event>,
+	// e.g. participant.created
+	Subject string
+
+	// Body is the JSON event, using the webhook event
+	// format with the key as the event id
+	Body []byte
+}
+
+// Sink publishes messages. Publish must return an error
+// unless the message has been accepted, and is retried
+// until it succeeds.
+type Sink interface {
+
+	// Publish will publish the message
+	Publish(ctx context.Context, message *Message) error
+
+	// Close will release the sink resources
+	Close() error
+}
+
+// Relay publishes the store outbox to the sinks. It is
+// a store Observer, so that it is woken by changes.
+type Relay struct {
+	db         *store.Store
+	sinks      []Sink
+	backoff    time.Duration
+	maxBackoff time.Duration
+	batchSize  int
+
+	// published holds the sinks which have accepted
+	// each entry, so that an entry is not resent to
+	// them whilst another sink is failing
+	published map[string]map[int]bool
+
+	wake chan struct{}
+	sync.Mutex
+}
+
+// Option is used to configure the Relay.
+type Option func(*Relay)
+
+// WithBackoff sets the time to wait before retrying a
+// failed sink, which doubles each retry up to the maximum.
+func WithBackoff(backoff, maxBackoff time.Duration) Option {
+	return func(r *Relay) {
+		r.backoff = backoff
+		r.maxBackoff = maxBackoff
+	}
+}
+
+// NewRelay creates a Relay for the sinks, enabling
+// the outbox of the store.
+func NewRelay(db *store.Store, sinks []Sink, opts ...Option) *Relay {
+	r := &Relay{
+		db:         db,
+		sinks:      sinks,
+		backoff:    DefaultBackoff,
+		maxBackoff: DefaultMaxBackoff,
+		batchSize:  DefaultBatchSize,
+		published:  make(map[string]map[int]bool),
+		wake:       make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	db.EnableOutbox()
+	db.Observe(r)
+	return r
+}
+
+// Mutated will wake the relay.
+func (r *Relay) Mutated(mutation *api.Mutation, previous *api.Participant) {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Erased does nothing, as the store removes the
+// outbox entries of erased participants.
+func (r *Relay) Erased(ids []string) {}
+
+// Run will publish the outbox until the context is
+// cancelled, retrying with an exponential backoff
+// whilst a sink is failing.
+func (r *Relay) Run(ctx context.Context) {
+	backoff := r.backoff
+	for {
+		wait := r.maxBackoff
+		if err := r.relay(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("could not publish outbox, retrying in %v: %v", backoff, err)
+			wait = backoff
+			if backoff *= 2; backoff > r.maxBackoff {
+				backoff = r.maxBackoff
+			}
+		} else {
+			backoff = r.backoff
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-r.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Drain will publish the outbox until it is empty, retrying
+// with an exponential backoff whilst a sink is failing, or
+// until the context is done. It is used during shut down,
+// once Run has returned, as the outbox is held in memory
+// and is lost when the server exits.
+func (r *Relay) Drain(ctx context.Context) error {
+	backoff := r.backoff
+	for {
+		err := r.relay(ctx)
+		if err == nil {
+			return nil
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			entries, _ := r.db.Outbox(0)
+			return fmt.Errorf("%d outbox entries were not published: %w", len(entries), err)
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > r.maxBackoff {
+			backoff = r.maxBackoff
+		}
+	}
+}
+
+// relay will publish the outbox entries in batches until
+// the outbox is empty, returning the first sink error.
+// Once a sink fails, no more entries are sent to it, so
+// each sink receives the entries in order.
+func (r *Relay) relay(ctx context.Context) error {
+	r.Lock()
+	defer r.Unlock()
+	for {
+		entries, err := r.db.Outbox(r.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+
+			// forget entries which were erased before
+			// every sink accepted them
+			r.published = make(map[string]map[int]bool)
+			return nil
+		}
+		var sinkErr error
+		failed := map[int]bool{}
+		acked := []string{}
+		for _, entry := range entries {
+			message, err := newMessage(entry)
+			if err != nil {
+				return err
+			}
+			published := r.published[entry.Key]
+			if published == nil {
+				published = map[int]bool{}
+				r.published[entry.Key] = published
+			}
+			for i, sink := range r.sinks {
+				if published[i] || failed[i] {
+					continue
+				}
+				if err := sink.Publish(ctx, message); err != nil {
+					failed[i] = true
+					if sinkErr == nil {
+						sinkErr = err
+					}
+					continue
+				}
+				published[i] = true
+			}
+			if len(published) == len(r.sinks) {
+				acked = append(acked, entry.Key)
+				delete(r.published, entry.Key)
+			}
+		}
+		if err := r.db.AckOutbox(acked...); err != nil {
+			return err
+		}
+		if sinkErr != nil {
+			return sinkErr
+		}
+	}
+}
+
+// Close will close the sinks and the outbox file
+// of the store.
+func (r *Relay) Close() error {
+	closeErr := r.db.CloseOutbox()
+	for _, sink := range r.sinks {
+		if err := sink.Close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+	return closeErr
+}
+
+// newMessage will create the message for an outbox entry.
+func newMessage(entry *store.OutboxEntry) (*Message, error) {
+	event := webhook.MutationEvent(entry.Mutation.GetType())
+	body, err := webhook.NewEvent(entry.Key, event, entry.Mutation)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{
+		Key:     entry.Key,
+		Subject: "participant." + event,
+		Body:    data,
+	}, nil
+}
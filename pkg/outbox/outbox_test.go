@@ -0,0 +1,343 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/erasure"
+	"github.com/will-rowe/registry-microservice/pkg/store"
+	"github.com/will-rowe/registry-microservice/pkg/webhook"
+)
+
+// testSink records the messages it accepts, failing
+// whilst fail returns true.
+type testSink struct {
+	messages []*Message
+	fail     func() bool
+	sync.Mutex
+}
+
+// Publish will record the message.
+func (s *testSink) Publish(ctx context.Context, message *Message) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.fail != nil && s.fail() {
+		return errors.New("sink unavailable")
+	}
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+// Close does nothing.
+func (s *testSink) Close() error {
+	return nil
+}
+
+// keys returns the keys of the accepted messages.
+func (s *testSink) keys() []string {
+	s.Lock()
+	defer s.Unlock()
+	keys := []string{}
+	for _, message := range s.messages {
+		keys = append(keys, message.Key)
+	}
+	return keys
+}
+
+// drained waits for the outbox to empty,
+// failing the test if it does not.
+func drained(t *testing.T, db *store.Store) {
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		entries, err := db.Outbox(0)
+		assert.NilError(t, err)
+		if len(entries) == 0 {
+			return
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("%d outbox entries still pending", len(entries))
+		}
+	}
+}
+
+// TestRelay will check that each change is delivered to
+// every sink, in order, whilst one of the sinks is failing.
+func TestRelay(t *testing.T) {
+	db := store.New()
+	attempts := 0
+	failing := &testSink{fail: func() bool {
+		attempts++
+		return attempts <= 3
+	}}
+	working := &testSink{}
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	file, err := NewSink(SinkConfig{Type: SinkFile, Path: path})
+	assert.NilError(t, err)
+	relay := NewRelay(db, []Sink{failing, working, file}, WithBackoff(5*time.Millisecond, 20*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go relay.Run(ctx)
+
+	// make some changes
+	_, err = db.Create(&api.Participant{Id: "KFG-734", GivenName: "Ada"})
+	assert.NilError(t, err)
+	_, err = db.Update(&api.Participant{Id: "KFG-734", GivenName: "Augusta"}, 0)
+	assert.NilError(t, err)
+	assert.NilError(t, db.Delete("KFG-734"))
+	drained(t, db)
+	assert.NilError(t, relay.Close())
+
+	// each sink has each change once, in order
+	keys := working.keys()
+	assert.Equal(t, len(keys), 3)
+	assert.DeepEqual(t, failing.keys(), keys)
+	assert.Assert(t, keys[0] != keys[1] && keys[1] != keys[2])
+	subjects := []string{}
+	for _, message := range working.messages {
+		subjects = append(subjects, message.Subject)
+	}
+	assert.DeepEqual(t, subjects, []string{"participant.created", "participant.updated", "participant.deleted"})
+	data, err := ioutil.ReadFile(path)
+	assert.NilError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Equal(t, len(lines), 3)
+	event := &webhook.Event{}
+	assert.NilError(t, json.Unmarshal([]byte(lines[1]), event))
+	assert.Equal(t, event.ID, keys[1])
+	assert.Equal(t, event.Event, webhook.EventUpdated)
+	assert.Equal(t, string(event.Participant), `{"id":"KFG-734","givenName":"Augusta"}`)
+}
+
+// TestErase will check that erasing a participant
+// removes their changes from the outbox.
+func TestErase(t *testing.T) {
+	db := store.New()
+	db.EnableOutbox()
+	signer, err := erasure.NewRandomSigner()
+	assert.NilError(t, err)
+	for _, id := range []string{"KFG-734", "ABC-123"} {
+		_, err := db.Create(&api.Participant{Id: id})
+		assert.NilError(t, err)
+	}
+	_, err = db.Erase("KFG-734", func(counts erasure.Counts) (*api.ErasureReceipt, error) {
+		receipt, _, err := signer.Receipt("KFG-734", counts, time.Now())
+		return receipt, err
+	})
+	assert.NilError(t, err)
+	entries, err := db.Outbox(0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(entries), 1)
+	assert.Equal(t, entries[0].Mutation.GetParticipantId(), "ABC-123")
+	assert.NilError(t, db.AckOutbox(entries[0].Key))
+	entries, err = db.Outbox(0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(entries), 0)
+}
+
+// TestOutboxFile will check that the outbox entries which
+// were not published before a crash are published again,
+// and that erased changes are removed from the file.
+func TestOutboxFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	db := store.New()
+	assert.NilError(t, db.OpenOutbox(path))
+	for _, id := range []string{"KFG-734", "ABC-123", "XYZ-999"} {
+		_, err := db.Create(&api.Participant{Id: id, GivenName: "Ada"})
+		assert.NilError(t, err)
+	}
+	entries, err := db.Outbox(0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(entries), 3)
+	assert.NilError(t, db.AckOutbox(entries[0].Key))
+
+	// crash part way through appending a change, without
+	// closing the store, and publish the rest on restart
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	assert.NilError(t, err)
+	_, err = f.WriteString(`{"key":"partial","mut`)
+	assert.NilError(t, err)
+	assert.NilError(t, f.Close())
+	db = store.New()
+	assert.NilError(t, db.OpenOutbox(path))
+	replayed, err := db.Outbox(0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(replayed), 2)
+	for i, entry := range replayed {
+		assert.Equal(t, entry.Key, entries[i+1].Key)
+		assert.Equal(t, entry.Mutation.GetParticipantId(), entries[i+1].Mutation.GetParticipantId())
+		assert.Equal(t, entry.Mutation.GetParticipant().GetGivenName(), "Ada")
+	}
+	sink := &testSink{}
+	relay := NewRelay(db, []Sink{sink})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NilError(t, relay.Drain(ctx))
+	assert.DeepEqual(t, sink.keys(), []string{entries[1].Key, entries[2].Key})
+	assert.NilError(t, relay.Close())
+	db = store.New()
+	assert.NilError(t, db.OpenOutbox(path))
+	replayed, err = db.Outbox(0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(replayed), 0)
+
+	// erased changes are removed from the file
+	_, err = db.Create(&api.Participant{Id: "KFG-735", GivenName: "Augusta"})
+	assert.NilError(t, err)
+	signer, err := erasure.NewRandomSigner()
+	assert.NilError(t, err)
+	_, err = db.Erase("KFG-735", func(counts erasure.Counts) (*api.ErasureReceipt, error) {
+		receipt, _, err := signer.Receipt("KFG-735", counts, time.Now())
+		return receipt, err
+	})
+	assert.NilError(t, err)
+	data, err := ioutil.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(data), "KFG-735"))
+	assert.Assert(t, !strings.Contains(string(data), "Augusta"))
+
+	// a change is not acknowledged if the file can not be written
+	assert.NilError(t, db.CloseOutbox())
+	_, err = db.Create(&api.Participant{Id: "ABC-124"})
+	assert.Equal(t, status.Code(err), codes.Unavailable)
+	_, err = db.Get("ABC-124")
+	assert.Equal(t, status.Code(err), codes.Unavailable)
+}
+
+// TestDrain will check that the outbox is published
+// once the store is closed, and that draining gives up
+// when the context is done.
+func TestDrain(t *testing.T) {
+	db := store.New()
+	attempts := 0
+	sink := &testSink{fail: func() bool {
+		attempts++
+		return attempts <= 2
+	}}
+	relay := NewRelay(db, []Sink{sink}, WithBackoff(5*time.Millisecond, 20*time.Millisecond))
+	for _, id := range []string{"KFG-734", "ABC-123"} {
+		_, err := db.Create(&api.Participant{Id: id})
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, db.Close())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NilError(t, relay.Drain(ctx))
+	assert.Equal(t, len(sink.keys()), 2)
+	entries, err := db.Outbox(0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(entries), 0)
+
+	// a failing sink is retried until the context is done
+	db = store.New()
+	relay = NewRelay(db, []Sink{&testSink{fail: func() bool { return true }}}, WithBackoff(5*time.Millisecond, 20*time.Millisecond))
+	_, err = db.Create(&api.Participant{Id: "KFG-734"})
+	assert.NilError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	assert.ErrorContains(t, relay.Drain(ctx), "1 outbox entries were not published")
+}
+
+// TestNewSink will check that invalid sinks are rejected.
+func TestNewSink(t *testing.T) {
+	for _, config := range []SinkConfig{
+		{Type: "kafka"},
+		{Type: SinkFile},
+		{Type: SinkWebhook, URL: "example.com"},
+		{Type: SinkNATS, URL: "http://localhost:4222", Subject: "registry"},
+		{Type: SinkNATS, URL: "nats://localhost:4222"},
+	} {
+		_, err := NewSink(config)
+		assert.Assert(t, err != nil, config.Type)
+	}
+}
+
+// TestWebhookSink will check the webhook sink
+// sends the idempotency key and signature.
+func TestWebhookSink(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	defer server.Close()
+	sink, err := NewSink(SinkConfig{Type: SinkWebhook, URL: server.URL, Secret: "s3cret"})
+	assert.NilError(t, err)
+	assert.NilError(t, sink.Publish(context.Background(), &Message{Key: "k-1", Subject: "participant.created", Body: []byte(`{"id":"k-1"}`)}))
+	r, body := <-requests, <-bodies
+	assert.Equal(t, r.Header.Get(HeaderIdempotencyKey), "k-1")
+	assert.Equal(t, r.Header.Get(webhook.HeaderEvent), webhook.EventCreated)
+	assert.Assert(t, webhook.Verify("s3cret", body, r.Header.Get(webhook.HeaderTimestamp), r.Header.Get(webhook.HeaderSignature), webhook.DefaultTolerance))
+}
+
+// fakeNATS is a NATS server which records the messages
+// published to it, using the minimum of the protocol.
+func fakeNATS(t *testing.T, published chan string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "INFO {\"headers\":true}\r\n")
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "PING":
+				fmt.Fprint(conn, "PONG\r\n")
+			case "CONNECT":
+				published <- strings.TrimSpace(line)
+			case "HPUB":
+				size, _ := strconv.Atoi(fields[3])
+				payload := make([]byte, size+2)
+				if _, err := io.ReadFull(reader, payload); err != nil {
+					return
+				}
+				published <- fields[1] + " " + string(payload[:size])
+			}
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// TestNATSSink will check that messages are
+// published with their idempotency key.
+func TestNATSSink(t *testing.T) {
+	published := make(chan string, 10)
+	address := fakeNATS(t, published)
+	sink, err := NewSink(SinkConfig{Type: SinkNATS, URL: "nats://user:pass@" + address, Subject: "registry"})
+	assert.NilError(t, err)
+	defer sink.Close()
+	assert.NilError(t, sink.Publish(context.Background(), &Message{Key: "k-1", Subject: "participant.created", Body: []byte(`{"id":"k-1"}`)}))
+	connect := <-published
+	assert.Assert(t, strings.Contains(connect, `"user":"user"`) && strings.Contains(connect, `"headers":true`), connect)
+	assert.Equal(t, <-published, "registry.participant.created NATS/1.0\r\nNats-Msg-Id: k-1\r\n\r\n{\"id\":\"k-1\"}")
+}
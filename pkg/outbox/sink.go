@@ -0,0 +1,150 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/will-rowe/registry-microservice/pkg/webhook"
+)
+
+// sink types
+const (
+	SinkFile    = "file"
+	SinkStdout  = "stdout"
+	SinkWebhook = "webhook"
+	SinkNATS    = "nats"
+)
+
+// SinkTypes are the supported sink types.
+var SinkTypes = []string{SinkFile, SinkStdout, SinkWebhook, SinkNATS}
+
+// HeaderIdempotencyKey is the webhook sink header
+// holding the message idempotency key.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// SinkConfig describes a sink.
+type SinkConfig struct {
+
+	// Type of the sink
+	Type string `mapstructure:"type"`
+
+	// Path of the file to append messages
+	// to, for a file sink
+	Path string `mapstructure:"path"`
+
+	// URL to POST messages to, for a webhook
+	// sink, or of the server, for a NATS sink
+	URL string `mapstructure:"url"`
+
+	// Secret to sign messages with, for a webhook sink
+	Secret string `mapstructure:"secret"`
+
+	// Subject prefix, for a NATS sink, which
+	// is followed by the message subject
+	Subject string `mapstructure:"subject"`
+}
+
+// NewSink creates a sink from its config.
+func NewSink(config SinkConfig) (Sink, error) {
+	switch config.Type {
+	case SinkFile:
+		if config.Path == "" {
+			return nil, fmt.Errorf("a path is required for a file sink")
+		}
+		file, err := os.OpenFile(config.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		return &writerSink{w: file, flush: file.Sync, close: file.Close}, nil
+	case SinkStdout:
+		return &writerSink{w: os.Stdout}, nil
+	case SinkWebhook:
+		u, err := url.Parse(config.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("an http or https URL is required for a webhook sink")
+		}
+		return &webhookSink{url: config.URL, secret: config.Secret, client: &http.Client{Timeout: webhook.DefaultTimeout}}, nil
+	case SinkNATS:
+		return newNATSSink(config.URL, config.Subject)
+	default:
+		return nil, fmt.Errorf("unsupported sink type %q (%v)", config.Type, strings.Join(SinkTypes, "|"))
+	}
+}
+
+// writerSink writes each message body as a line. The flush
+// function, if set, is called before a message is accepted.
+type writerSink struct {
+	w     io.Writer
+	flush func() error
+	close func() error
+	sync.Mutex
+}
+
+// Publish will write the message body as a line.
+func (s *writerSink) Publish(ctx context.Context, message *Message) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, err := s.w.Write(append(append([]byte{}, message.Body...), '\n')); err != nil {
+		return err
+	}
+	if s.flush != nil {
+		return s.flush()
+	}
+	return nil
+}
+
+// Close will close the writer, if it can be closed.
+func (s *writerSink) Close() error {
+	if s.close != nil {
+		return s.close()
+	}
+	return nil
+}
+
+// webhookSink POSTs each message body to a URL, which must
+// respond with a 2xx status. The idempotency key is sent in
+// the Idempotency-Key header, and the body is signed as for
+// webhook subscriptions.
+type webhookSink struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// Publish will POST the message to the URL.
+func (s *webhookSink) Publish(ctx context.Context, message *Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(message.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderIdempotencyKey, message.Key)
+	req.Header.Set(webhook.HeaderEvent, strings.TrimPrefix(message.Subject, "participant."))
+	timestamp := webhook.Timestamp(time.Now())
+	req.Header.Set(webhook.HeaderTimestamp, timestamp)
+	if s.secret != "" {
+		req.Header.Set(webhook.HeaderSignature, webhook.Sign(s.secret, timestamp, message.Body))
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook sink responded with %v", res.Status)
+	}
+	return nil
+}
+
+// Close does nothing for a webhook sink.
+func (s *webhookSink) Close() error {
+	return nil
+}
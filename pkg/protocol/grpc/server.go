@@ -4,18 +4,28 @@ package grpc
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"net"
-	"os"
-	"os/signal"
+	"time"
 
 	"google.golang.org/grpc"
 
-	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	apiv1 "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	apiv2 "github.com/will-rowe/registry-microservice/pkg/api/v2"
 )
 
 // RunServer runs a gRPC service to publish the registry service.
-func RunServer(ctx context.Context, serverAPI api.RegistryServiceServer, port string) error {
+// Each API version is registered on the same server.
+//
+// The server runs until the provided context is cancelled, at which
+// point it stops accepting new connections and waits for in-flight
+// requests to finish. If requests are still running once the drain
+// timeout has elapsed, the server is forcibly stopped. Once stopped,
+// each service is closed if it implements io.Closer so that any
+// storage it holds can be flushed. The server options, e.g.
+// interceptors, are passed to the gRPC server.
+func RunServer(ctx context.Context, v1API apiv1.RegistryServiceServer, v2API apiv2.RegistryServiceServer, port string, drainTimeout time.Duration, opts ...grpc.ServerOption) error {
 
 	// announce on the local network address
 	listen, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
@@ -23,25 +33,69 @@ func RunServer(ctx context.Context, serverAPI api.RegistryServiceServer, port st
 		return err
 	}
 
-	// register the registry service
+	// register the registry service versions
 	// TODO: add logging to the gRPC server by passing options to NewServer
-	server := grpc.NewServer()
-	api.RegisterRegistryServiceServer(server, serverAPI)
+	server := grpc.NewServer(opts...)
+	apiv1.RegisterRegistryServiceServer(server, v1API)
+	apiv2.RegisterRegistryServiceServer(server, v2API)
 
-	// prepare a graceful shutdown
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, os.Interrupt)
+	// start the gRPC server
+	log.Println("starting gRPC server...")
+	errChan := make(chan error, 1)
 	go func() {
+		errChan <- server.Serve(listen)
+	}()
 
-		// wait for incoming shutdown signal
-		for range signalChan {
-			log.Println("shut down signal received")
-			server.GracefulStop()
-			<-ctx.Done()
-		}
+	// wait for the server to fail or for a shut down
+	select {
+	case err := <-errChan:
+		closeServices(v1API, v2API)
+		return err
+	case <-ctx.Done():
+		log.Println("shut down signal received")
+	}
+
+	// drain the server, forcing a stop if it takes too long
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
 	}()
+	timer := time.NewTimer(drainTimeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+		log.Println("gRPC server drained")
+	case <-timer.C:
+		log.Printf("gRPC server did not drain within %v, forcing stop", drainTimeout)
+		server.Stop()
+		<-stopped
+	}
 
-	// start the gRPC server
-	log.Println("starting gRPC server...")
-	return server.Serve(listen)
+	// flush the service storage
+	if err := closeServices(v1API, v2API); err != nil {
+		return err
+	}
+
+	// Serve returns nil after GracefulStop or Stop, unless
+	// the shut down happened before it started serving
+	if err := <-errChan; err != nil && err != grpc.ErrServerStopped {
+		return err
+	}
+	return nil
+}
+
+// closeServices will close each service that
+// implements io.Closer.
+func closeServices(services ...interface{}) error {
+	for _, service := range services {
+		closer, ok := service.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil {
+			return fmt.Errorf("could not close registry service: %w", err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,111 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	apiv2 "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// blockingService is a registry service whose
+// Retrieve rpc blocks until the request is cancelled.
+type blockingService struct {
+	api.UnimplementedRegistryServiceServer
+	started chan struct{}
+	closed  bool
+}
+
+// Retrieve blocks until the request context is done.
+func (bs *blockingService) Retrieve(ctx context.Context, request *api.RetrieveRequest) (*api.RetrieveResponse, error) {
+	close(bs.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// Close records that the service was closed.
+func (bs *blockingService) Close() error {
+	bs.closed = true
+	return nil
+}
+
+// freePort is a helper function to find
+// an available TCP port for the tests.
+func freePort(t *testing.T) string {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+// TestRunServer_Shutdown will check that the server
+// returns and closes the service once the context
+// is cancelled.
+func TestRunServer_Shutdown(t *testing.T) {
+	bs := &blockingService{started: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- RunServer(ctx, bs, &apiv2.UnimplementedRegistryServiceServer{}, freePort(t), time.Second)
+	}()
+	cancel()
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down after context was cancelled")
+	}
+	if !bs.closed {
+		t.Fatal("service was not closed during shut down")
+	}
+}
+
+// TestRunServer_DrainTimeout will check that the server
+// is forcibly stopped when in-flight requests do not
+// finish within the drain timeout.
+func TestRunServer_DrainTimeout(t *testing.T) {
+	bs := &blockingService{started: make(chan struct{})}
+	port := freePort(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- RunServer(ctx, bs, &apiv2.UnimplementedRegistryServiceServer{}, port, 100*time.Millisecond)
+	}()
+
+	// start a request that will never finish by itself
+	conn, err := grpc.Dial("localhost:"+port, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := api.NewRegistryServiceClient(conn)
+	go client.Retrieve(context.Background(), &api.RetrieveRequest{}, grpc.WaitForReady(true))
+	select {
+	case <-bs.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request did not reach the server")
+	}
+
+	// shut down and check the drain timeout is respected
+	cancel()
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server was not forcibly stopped after the drain timeout")
+	}
+	if !bs.closed {
+		t.Fatal("service was not closed during shut down")
+	}
+}
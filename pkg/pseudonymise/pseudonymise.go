@@ -0,0 +1,146 @@
+//Package pseudonymise removes direct identifiers from participant
+//details so that they can be exported for research. Reference numbers
+//are replaced by keyed pseudonyms, which are the same in every export
+//using the same key.
+package pseudonymise
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// MinKeyLength is the shortest key, in bytes,
+// which can be used to create pseudonyms.
+const MinKeyLength = 16
+
+// pseudonymLength is the number of hex
+// characters kept from the HMAC.
+const pseudonymLength = 16
+
+// ageBandWidth is the number of years in an age
+// band, and maxAgeBand is the start of the oldest,
+// open ended, age band.
+const (
+	ageBandWidth = 10
+	maxAgeBand   = 90
+)
+
+// Record is a pseudonymised participant.
+type Record struct {
+
+	// Pseudonym replaces the reference number
+	Pseudonym string
+
+	// BirthYear is the year of birth, which is 0
+	// if the age band is used or the DOB is unknown
+	BirthYear int32
+
+	// AgeBand is the age of the participant in
+	// years, e.g. 30-39, which is only set if
+	// requested
+	AgeBand string
+
+	// PostcodeDistrict is the first part of the
+	// postcode, if it can be truncated
+	PostcodeDistrict string
+
+	// Country of the participant's address
+	Country string
+
+	// SexAtBirth of the participant
+	SexAtBirth api.SexAtBirth
+}
+
+// Pseudonymiser creates pseudonymised
+// participants using a secret key.
+type Pseudonymiser struct {
+
+	// key for the pseudonym HMAC
+	key []byte
+}
+
+// New creates a Pseudonymiser which uses the key
+// to create pseudonyms, which must be at least
+// MinKeyLength bytes.
+func New(key []byte) (*Pseudonymiser, error) {
+	if len(key) < MinKeyLength {
+		return nil, fmt.Errorf("pseudonym key must be at least %d bytes", MinKeyLength)
+	}
+	return &Pseudonymiser{
+		key: append([]byte{}, key...),
+	}, nil
+}
+
+// Pseudonym will return the pseudonym for a reference
+// number, which is a truncated HMAC-SHA256 in hex.
+func (p *Pseudonymiser) Pseudonym(id string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))[:pseudonymLength]
+}
+
+// Participant will pseudonymise a participant. The reference
+// number is replaced by its pseudonym, the DOB is coarsened
+// to the year of birth or, if ageBand is true, the age band
+// at the provided time, the postcode is truncated to the
+// district and all other details are dropped.
+func (p *Pseudonymiser) Participant(participant *api.Participant, ageBand bool, now time.Time) *Record {
+	record := &Record{
+		Pseudonym:        p.Pseudonym(participant.GetId()),
+		PostcodeDistrict: PostcodeDistrict(participant.GetAddress().GetPostcode(), participant.GetAddress().GetCountry()),
+		Country:          participant.GetAddress().GetCountry(),
+		SexAtBirth:       participant.GetSexAtBirth(),
+	}
+	dob, err := ptypes.Timestamp(participant.GetDob())
+	if participant.GetDob() == nil || err != nil {
+		return record
+	}
+	if ageBand {
+		record.AgeBand = AgeBand(dob, now)
+	} else {
+		record.BirthYear = int32(dob.Year())
+	}
+	return record
+}
+
+// AgeBand returns the age band of someone born on
+// the DOB at the provided time, e.g. 30-39 or 90+.
+func AgeBand(dob, now time.Time) string {
+	age := now.Year() - dob.Year()
+	if now.Month() < dob.Month() || (now.Month() == dob.Month() && now.Day() < dob.Day()) {
+		age--
+	}
+	switch {
+	case age < 0:
+		return ""
+	case age >= maxAgeBand:
+		return fmt.Sprintf("%d+", maxAgeBand)
+	}
+	start := age - age%ageBandWidth
+	return fmt.Sprintf("%d-%d", start, start+ageBandWidth-1)
+}
+
+// PostcodeDistrict returns the first part of a normalised
+// postcode, e.g. SW1A for SW1A 1AA, or the first three
+// digits of a US zip code. Postcodes which can not be
+// truncated are dropped.
+func PostcodeDistrict(postcode, country string) string {
+	switch {
+	case postcode == "":
+		return ""
+	case country == "US" && len(postcode) >= 3:
+		return postcode[:3]
+	case strings.Contains(postcode, " "):
+		return postcode[:strings.Index(postcode, " ")]
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,71 @@
+package pseudonymise
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// TestPseudonym will check that pseudonyms are
+// stable for a key and differ between keys.
+func TestPseudonym(t *testing.T) {
+	_, err := New([]byte("short"))
+	assert.Assert(t, err != nil)
+	a, err := New([]byte("0123456789abcdef"))
+	assert.NilError(t, err)
+	b, err := New([]byte("0123456789abcdef"))
+	assert.NilError(t, err)
+	c, err := New([]byte("fedcba9876543210"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(a.Pseudonym("KFG-734")), pseudonymLength)
+	assert.Equal(t, a.Pseudonym("KFG-734"), b.Pseudonym("KFG-734"))
+	assert.Assert(t, a.Pseudonym("KFG-734") != a.Pseudonym("KFG-735"))
+	assert.Assert(t, a.Pseudonym("KFG-734") != c.Pseudonym("KFG-734"))
+}
+
+// TestParticipant will check that direct
+// identifiers are removed.
+func TestParticipant(t *testing.T) {
+	p, err := New([]byte("0123456789abcdef"))
+	assert.NilError(t, err)
+	participant := &api.Participant{
+		Id:         "KFG-734",
+		GivenName:  "Ada",
+		Dob:        timestamppb.New(time.Date(1980, 6, 2, 0, 0, 0, 0, time.UTC)),
+		SexAtBirth: api.SexAtBirth_SEX_AT_BIRTH_FEMALE,
+		Address:    &api.PostalAddress{Lines: []string{"1 Crater Road"}, Postcode: "SW1A 1AA", Country: "GB"},
+		Phones:     []*api.PhoneNumber{{Number: "+447700900123"}},
+	}
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	assert.DeepEqual(t, *p.Participant(participant, false, now), Record{
+		Pseudonym:        p.Pseudonym("KFG-734"),
+		BirthYear:        1980,
+		PostcodeDistrict: "SW1A",
+		Country:          "GB",
+		SexAtBirth:       api.SexAtBirth_SEX_AT_BIRTH_FEMALE,
+	})
+	assert.Equal(t, p.Participant(participant, true, now).AgeBand, "30-39")
+	assert.Equal(t, p.Participant(participant, true, now.AddDate(0, 0, 1)).AgeBand, "40-49")
+}
+
+// TestPostcodeDistrict will check
+// that postcodes are truncated.
+func TestPostcodeDistrict(t *testing.T) {
+	tests := []struct {
+		postcode, country, expected string
+	}{
+		{"SW1A 1AA", "GB", "SW1A"},
+		{"D02 AF30", "IE", "D02"},
+		{"12345-6789", "US", "123"},
+		{"75008", "FR", ""},
+		{"", "GB", ""},
+	}
+	for _, test := range tests {
+		assert.Equal(t, PostcodeDistrict(test.postcode, test.country), test.expected)
+	}
+	assert.Equal(t, AgeBand(time.Date(1920, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)), "90+")
+}
@@ -0,0 +1,281 @@
+//Package retention evaluates the data retention rules which say
+//when participant details must be destroyed, e.g. contact details
+//five years after the end of the participant's studies.
+package retention
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/consent"
+)
+
+// participant statuses which rules apply to
+const (
+
+	// StatusStudyEnded applies to participants whose
+	// studies have all ended, from the last end date
+	StatusStudyEnded = "study-ended"
+
+	// StatusWithdrawn applies to participants who have
+	// withdrawn all of their consent, from the last
+	// withdrawal
+	StatusWithdrawn = "withdrawn"
+)
+
+// rule actions
+const (
+
+	// ActionRedact clears the rule fields
+	ActionRedact = "redact"
+
+	// ActionDelete deletes the participant
+	ActionDelete = "delete"
+)
+
+// protectedFields can not be redacted as they are
+// needed to keep the registry consistent.
+var protectedFields = map[string]bool{"id": true, "consents": true, "aliases": true}
+
+// periodFormat matches a retention period, e.g. 5y or 18m.
+var periodFormat = regexp.MustCompile(`^([0-9]+)([ymd])$`)
+
+// Rule says when participant details must be destroyed.
+type Rule struct {
+
+	// Name of the rule, which is recorded
+	// in the audit entries
+	Name string `mapstructure:"name"`
+
+	// Status of the participants the rule applies to
+	Status string `mapstructure:"status"`
+
+	// After is how long after the status began that the
+	// rule applies, in years, months or days, e.g. 5y
+	After string `mapstructure:"after"`
+
+	// Action to take, redact or delete
+	Action string `mapstructure:"action"`
+
+	// Fields of the participant to redact,
+	// using their v2 field names
+	Fields []string `mapstructure:"fields"`
+}
+
+// Subject is a participant which rules are evaluated for.
+type Subject struct {
+
+	// Participant details
+	Participant *api.Participant
+
+	// StudyEnded is the last end date of the studies the
+	// participant is enrolled in, which is zero if they
+	// are not enrolled or a study has not ended
+	StudyEnded time.Time
+}
+
+// Action is what must be done to a participant
+// to comply with the retention rules.
+type Action struct {
+
+	// ParticipantID is the participant reference number
+	ParticipantID string
+
+	// Delete is true if the participant must be deleted
+	Delete bool
+
+	// Fields to redact, if not deleting
+	Fields []string
+
+	// Rules which apply to the participant
+	Rules []string
+
+	// Due is when the earliest rule applied
+	Due time.Time
+}
+
+// Reason describes the action for the audit entry.
+func (a *Action) Reason() string {
+	if a.Delete {
+		return fmt.Sprintf("retention rule %v: deleted", strings.Join(a.Rules, ", "))
+	}
+	return fmt.Sprintf("retention rule %v: redacted %v", strings.Join(a.Rules, ", "), strings.Join(a.Fields, ", "))
+}
+
+// Policy is a set of retention rules.
+type Policy struct {
+
+	// rules in the policy
+	rules []Rule
+}
+
+// New creates a Policy from the rules, returning
+// an error if any of the rules are invalid.
+func New(rules []Rule) (*Policy, error) {
+	for i, rule := range rules {
+		if err := checkRule(rule); err != nil {
+			return nil, fmt.Errorf("invalid retention rule %d (%v): %w", i+1, rule.Name, err)
+		}
+	}
+	return &Policy{
+		rules: append([]Rule{}, rules...),
+	}, nil
+}
+
+// checkRule returns an error if the rule is invalid.
+func checkRule(rule Rule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("a name is required")
+	}
+	if rule.Status != StatusStudyEnded && rule.Status != StatusWithdrawn {
+		return fmt.Errorf("unsupported status %q (%v|%v)", rule.Status, StatusStudyEnded, StatusWithdrawn)
+	}
+	if _, err := expires(time.Time{}, rule.After); err != nil {
+		return err
+	}
+	switch rule.Action {
+	case ActionDelete:
+		if len(rule.Fields) != 0 {
+			return fmt.Errorf("fields can not be given for a delete")
+		}
+	case ActionRedact:
+		if len(rule.Fields) == 0 {
+			return fmt.Errorf("fields are required for a redact")
+		}
+		for _, field := range rule.Fields {
+			if err := checkField(field); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported action %q (%v|%v)", rule.Action, ActionRedact, ActionDelete)
+	}
+	return nil
+}
+
+// checkField returns an error if the field is not
+// a participant field which can be redacted.
+func checkField(field string) error {
+	fields := (&api.Participant{}).ProtoReflect().Descriptor().Fields()
+	if fields.ByName(protoreflect.Name(field)) == nil || protectedFields[field] {
+		return fmt.Errorf("%q is not a participant field which can be redacted", field)
+	}
+	return nil
+}
+
+// expires returns the time a period after the start.
+func expires(start time.Time, period string) (time.Time, error) {
+	match := periodFormat.FindStringSubmatch(period)
+	if match == nil {
+		return time.Time{}, fmt.Errorf("unsupported period %q, use years, months or days, e.g. 5y, 18m or 30d", period)
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unsupported period %q: %w", period, err)
+	}
+	switch match[2] {
+	case "y":
+		return start.AddDate(n, 0, 0), nil
+	case "m":
+		return start.AddDate(0, n, 0), nil
+	default:
+		return start.AddDate(0, 0, n), nil
+	}
+}
+
+// Evaluate will return the action that must be taken for the
+// participant at the provided time, or nil if no rules apply.
+// A delete takes precedence over redactions, otherwise the
+// fields of each rule which applies are redacted. Rules are
+// not reported once their fields have been redacted.
+func (p *Policy) Evaluate(subject Subject, now time.Time) *Action {
+	var action *Action
+	redact := map[string]bool{}
+	for _, rule := range p.rules {
+		start := statusStart(subject, rule.Status)
+		if start.IsZero() {
+			continue
+		}
+		due, _ := expires(start, rule.After)
+		if due.After(now) {
+			continue
+		}
+		if rule.Action == ActionRedact && redacted(subject.Participant, rule.Fields) {
+			continue
+		}
+		if action == nil {
+			action = &Action{ParticipantID: subject.Participant.GetId(), Due: due}
+		}
+		action.Rules = append(action.Rules, rule.Name)
+		action.Delete = action.Delete || rule.Action == ActionDelete
+		if due.Before(action.Due) {
+			action.Due = due
+		}
+		for _, field := range rule.Fields {
+			redact[field] = true
+		}
+	}
+	if action != nil && !action.Delete {
+		for field := range redact {
+			action.Fields = append(action.Fields, field)
+		}
+		sort.Strings(action.Fields)
+	}
+	return action
+}
+
+// statusStart returns when the participant's status began,
+// which is zero if the participant does not have the status.
+func statusStart(subject Subject, status string) time.Time {
+	switch status {
+	case StatusStudyEnded:
+		return subject.StudyEnded
+	case StatusWithdrawn:
+		if !consent.IsWithdrawn(subject.Participant) {
+			return time.Time{}
+		}
+		var withdrawn time.Time
+		for _, c := range subject.Participant.GetConsents() {
+			if t, err := ptypes.Timestamp(c.GetWithdrawn()); err == nil && t.After(withdrawn) {
+				withdrawn = t
+			}
+		}
+		return withdrawn
+	default:
+		return time.Time{}
+	}
+}
+
+// redacted returns true if the participant
+// fields have all been cleared.
+func redacted(p *api.Participant, fields []string) bool {
+	m := p.ProtoReflect()
+	for _, field := range fields {
+		if m.Has(m.Descriptor().Fields().ByName(protoreflect.Name(field))) {
+			return false
+		}
+	}
+	return true
+}
+
+// Redact will return a copy of the
+// participant with the fields cleared.
+func Redact(p *api.Participant, fields []string) *api.Participant {
+	redacted := proto.Clone(p).(*api.Participant)
+	m := redacted.ProtoReflect()
+	for _, field := range fields {
+		if fd := m.Descriptor().Fields().ByName(protoreflect.Name(field)); fd != nil {
+			m.Clear(fd)
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,73 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// TestNew will check that invalid rules are rejected.
+func TestNew(t *testing.T) {
+	valid := Rule{Name: "contact", Status: StatusStudyEnded, After: "5y", Action: ActionRedact, Fields: []string{"phones", "emails"}}
+	_, err := New([]Rule{valid})
+	assert.NilError(t, err)
+	for _, rule := range []Rule{
+		{Status: StatusStudyEnded, After: "5y", Action: ActionDelete},
+		{Name: "status", Status: "active", After: "5y", Action: ActionDelete},
+		{Name: "period", Status: StatusWithdrawn, After: "5 years", Action: ActionDelete},
+		{Name: "action", Status: StatusWithdrawn, After: "5y", Action: "archive"},
+		{Name: "no fields", Status: StatusWithdrawn, After: "5y", Action: ActionRedact},
+		{Name: "delete fields", Status: StatusWithdrawn, After: "5y", Action: ActionDelete, Fields: []string{"phones"}},
+		{Name: "unknown field", Status: StatusWithdrawn, After: "5y", Action: ActionRedact, Fields: []string{"phone"}},
+		{Name: "protected field", Status: StatusWithdrawn, After: "5y", Action: ActionRedact, Fields: []string{"id"}},
+	} {
+		_, err := New([]Rule{valid, rule})
+		assert.Assert(t, err != nil, rule.Name)
+	}
+}
+
+// TestEvaluate will check the actions required
+// for participants with each status.
+func TestEvaluate(t *testing.T) {
+	policy, err := New([]Rule{
+		{Name: "contact", Status: StatusStudyEnded, After: "5y", Action: ActionRedact, Fields: []string{"phones", "emails"}},
+		{Name: "address", Status: StatusStudyEnded, After: "10y", Action: ActionRedact, Fields: []string{"address"}},
+		{Name: "withdrawn", Status: StatusWithdrawn, After: "18m", Action: ActionDelete},
+	})
+	assert.NilError(t, err)
+	p := &api.Participant{
+		Id:      "KFG-734",
+		Phones:  []*api.PhoneNumber{{Number: "+447700900123"}},
+		Address: &api.PostalAddress{Postcode: "SW1A 1AA"},
+	}
+	ended := time.Date(2010, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// rules apply once the period has passed
+	assert.Assert(t, policy.Evaluate(Subject{Participant: p}, ended.AddDate(20, 0, 0)) == nil)
+	assert.Assert(t, policy.Evaluate(Subject{Participant: p, StudyEnded: ended}, ended.AddDate(5, 0, -1)) == nil)
+	action := policy.Evaluate(Subject{Participant: p, StudyEnded: ended}, ended.AddDate(5, 0, 0))
+	assert.DeepEqual(t, action, &Action{ParticipantID: "KFG-734", Fields: []string{"emails", "phones"}, Rules: []string{"contact"}, Due: ended.AddDate(5, 0, 0)})
+	assert.Equal(t, action.Reason(), "retention rule contact: redacted emails, phones")
+	action = policy.Evaluate(Subject{Participant: p, StudyEnded: ended}, ended.AddDate(10, 0, 0))
+	assert.DeepEqual(t, action.Fields, []string{"address", "emails", "phones"})
+	assert.DeepEqual(t, action.Rules, []string{"contact", "address"})
+
+	// rules are not reported once their fields are redacted
+	redacted := Redact(p, action.Fields)
+	assert.Equal(t, len(p.GetPhones()), 1)
+	assert.Assert(t, redacted.GetAddress() == nil && len(redacted.GetPhones()) == 0)
+	assert.Equal(t, redacted.GetId(), "KFG-734")
+	assert.Assert(t, policy.Evaluate(Subject{Participant: redacted, StudyEnded: ended}, ended.AddDate(10, 0, 0)) == nil)
+
+	// a delete takes precedence over redactions
+	withdrawn := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	p.Consents = []*api.Consent{{Type: "study", FormVersion: "1", Granted: timestamppb.New(ended), Withdrawn: timestamppb.New(withdrawn)}}
+	assert.Assert(t, policy.Evaluate(Subject{Participant: p}, withdrawn.AddDate(1, 5, 0)) == nil)
+	action = policy.Evaluate(Subject{Participant: p, StudyEnded: ended}, withdrawn.AddDate(1, 6, 0))
+	assert.DeepEqual(t, action, &Action{ParticipantID: "KFG-734", Delete: true, Rules: []string{"contact", "withdrawn"}, Due: ended.AddDate(5, 0, 0)})
+	assert.Equal(t, action.Reason(), "retention rule contact, withdrawn: deleted")
+}
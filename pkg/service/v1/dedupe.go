@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	apiv2 "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// FindDuplicates will find participants which may be
+// registered more than once, highest score first.
+func (rs *registryService) FindDuplicates(ctx context.Context, request *api.FindDuplicatesRequest) (*api.FindDuplicatesResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// find the duplicates
+	req := &apiv2.FindDuplicatesRequest{}
+	if err := toLatest(request, req); err != nil {
+		return nil, err
+	}
+	res, err := rs.latest.FindDuplicates(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	response := &api.FindDuplicatesResponse{}
+	return response, rs.fromLatest(res, response)
+}
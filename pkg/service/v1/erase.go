@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	apiv2 "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// Erase will erase a participant from the registry,
+// removing every record of them held by the registry,
+// and return a signed erasure receipt.
+func (rs *registryService) Erase(ctx context.Context, request *api.EraseRequest) (*api.EraseResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// erase the participant
+	req := &apiv2.EraseRequest{}
+	if err := toLatest(request, req); err != nil {
+		return nil, err
+	}
+	res, err := rs.latest.Erase(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return, the receipt
+	// has the same fields and signature in v1
+	response := &api.EraseResponse{}
+	return response, rs.fromLatest(res, response)
+}
+
+// RetrieveErasureReceipt will retrieve an
+// erasure receipt from the registry.
+func (rs *registryService) RetrieveErasureReceipt(ctx context.Context, request *api.RetrieveErasureReceiptRequest) (*api.RetrieveErasureReceiptResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// get the receipt for the provided receipt id
+	req := &apiv2.RetrieveErasureReceiptRequest{}
+	if err := toLatest(request, req); err != nil {
+		return nil, err
+	}
+	res, err := rs.latest.RetrieveErasureReceipt(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	response := &api.RetrieveErasureReceiptResponse{}
+	return response, rs.fromLatest(res, response)
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	apiv2 "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// ExportPseudonymised will export the participants in
+// the registry with their direct identifiers removed.
+// Participants who have withdrawn are never exported.
+func (rs *registryService) ExportPseudonymised(ctx context.Context, request *api.ExportPseudonymisedRequest) (*api.ExportPseudonymisedResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// pseudonymise the participants
+	req := &apiv2.ExportPseudonymisedRequest{}
+	if err := toLatest(request, req); err != nil {
+		return nil, err
+	}
+	res, err := rs.latest.ExportPseudonymised(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return, which drops
+	// the fields only exported by later versions
+	response := &api.ExportPseudonymisedResponse{}
+	return response, rs.fromLatest(res, response)
+}
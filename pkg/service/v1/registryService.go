@@ -3,11 +3,14 @@ package service
 
 import (
 	"context"
-	"sync"
 
-	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	apiv2 "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/translate"
 )
 
 var (
@@ -21,27 +24,45 @@ type registryService struct {
 	// version of API implemented by the server
 	version string
 
-	// db is the in-memory db to store participants
-	db map[string]api.Participant
-
-	// db lock
-	sync.RWMutex
+	// latest is the service for the latest API
+	// version, which v1 requests are translated
+	// to and then run by
+	latest apiv2.RegistryServiceServer
 }
 
-// NewRegistryService creates the registry service.
-func NewRegistryService() api.RegistryServiceServer {
+// NewRegistryService creates the registry service, which
+// translates requests to the latest API version and runs
+// them using the provided service for that version, so
+// that the versions share the same participants and
+// features.
+func NewRegistryService(latest apiv2.RegistryServiceServer) api.RegistryServiceServer {
 	return &registryService{
 		version: apiVersion,
-		db:      make(map[string]api.Participant),
+		latest:  latest,
 	}
 }
 
 // checkAPI checks if requested API version is supported
 // by the server.
 func (rs *registryService) checkAPI(requestedAPI string) error {
-	if rs.version != requestedAPI {
-		return status.Errorf(codes.Unimplemented,
-			"unsupported API version requested: current service implements version '%s', but version '%s' was requested", rs.version, requestedAPI)
+	return translate.CheckVersion(rs.version, requestedAPI)
+}
+
+// toLatest will translate a request to the equivalent
+// request of the latest API version.
+func toLatest(request, translated proto.Message) error {
+	if err := translate.Message(request, translated, translate.LatestVersion); err != nil {
+		return status.Errorf(codes.Internal, "could not translate request: %v", err)
+	}
+	return nil
+}
+
+// fromLatest will translate a response of the latest API
+// version to the equivalent v1 response, dropping the
+// fields which are not in v1.
+func (rs *registryService) fromLatest(response, translated proto.Message) error {
+	if err := translate.Message(response, translated, rs.version); err != nil {
+		return status.Errorf(codes.Internal, "could not translate response: %v", err)
 	}
 	return nil
 }
@@ -54,26 +75,18 @@ func (rs *registryService) Create(ctx context.Context, request *api.CreateReques
 		return nil, err
 	}
 
-	// lock the db for RW access
-	rs.Lock()
-	defer rs.Unlock()
-
-	// check if entry already exists for provided reference number
-	if _, ok := rs.db[request.GetParticipant().GetId()]; ok {
-		return nil, status.Errorf(codes.AlreadyExists,
-			"reference number in use: participant already exists in the registry for %v", request.GetParticipant().GetId())
+	// create the up-converted participant
+	res, err := rs.latest.Create(ctx, &apiv2.CreateRequest{
+		ApiVersion:  translate.LatestVersion,
+		Participant: translate.V1ToV2(request.GetParticipant()),
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// TODO: validate the provided participant details
-
-	// add the participant as an entry in the registry db
-	rs.db[request.GetParticipant().GetId()] = *request.GetParticipant()
-
 	// create a response and return
-	return &api.CreateResponse{
-		ApiVersion: rs.version,
-		Created:    true,
-	}, nil
+	response := &api.CreateResponse{}
+	return response, rs.fromLatest(res, response)
 }
 
 // Retrieve will retrieve a participant from the registry.
@@ -84,23 +97,26 @@ func (rs *registryService) Retrieve(ctx context.Context, request *api.RetrieveRe
 		return nil, err
 	}
 
-	// check if entry already exists for provided reference number
-	participant, ok := rs.db[request.GetId()]
-	if !ok {
-		return nil, status.Errorf(codes.NotFound,
-			"reference number not found: no participant entry exists in the registry for %v", request.GetId())
+	// get the participant for the provided reference number
+	res, err := rs.latest.Retrieve(ctx, &apiv2.RetrieveRequest{
+		ApiVersion: translate.LatestVersion,
+		Id:         request.GetId(),
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// create a response and return
 	return &api.RetrieveResponse{
 		ApiVersion:  rs.version,
-		Participant: &participant,
+		Participant: translate.V2ToV1(res.GetParticipant()),
+		Revision:    res.GetRevision(),
 	}, nil
 }
 
 // Update will update a participant in the registry.
-// NOTE: this will update all fields, effectively calling delete and then create
-// TODO: implement individual field updates for a participant
+// NOTE: this will update all v1 fields, any details
+// which can only be held by later API versions are kept
 func (rs *registryService) Update(ctx context.Context, request *api.UpdateRequest) (*api.UpdateResponse, error) {
 
 	// check we have received a supported API request
@@ -108,55 +124,141 @@ func (rs *registryService) Update(ctx context.Context, request *api.UpdateReques
 		return nil, err
 	}
 
-	// lock the db for RW access
-	rs.Lock()
-	defer rs.Unlock()
-
-	// check if entry already exists for provided reference number
-	if _, ok := rs.db[request.GetParticipant().GetId()]; !ok {
-		return nil, status.Errorf(codes.NotFound,
-			"reference number not found: no participant entry exists in the registry for %v", request.GetParticipant().GetId())
+	// merge the participant into the current participant and
+	// update it at the revision it was merged into, merging
+	// again if it changed in between unless the client
+	// expected a revision
+	participant := request.GetParticipant()
+	for {
+		current, err := rs.latest.Retrieve(ctx, &apiv2.RetrieveRequest{
+			ApiVersion: translate.LatestVersion,
+			Id:         participant.GetId(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		merged := translate.MergeV1(current.GetParticipant(), participant)
+		merged.Id = participant.GetId()
+		revision := request.GetExpectedRevision()
+		if revision == 0 {
+			revision = current.GetRevision()
+		}
+		res, err := rs.latest.Update(ctx, &apiv2.UpdateRequest{
+			ApiVersion:       translate.LatestVersion,
+			Participant:      merged,
+			ExpectedRevision: revision,
+		})
+		if status.Code(err) == codes.Aborted && request.GetExpectedRevision() == 0 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// create a response and return
+		response := &api.UpdateResponse{}
+		return response, rs.fromLatest(res, response)
 	}
+}
 
-	// delete the entry from the registry db
-	delete(rs.db, request.GetParticipant().GetId())
+// Delete will delete a participant from the registry.
+func (rs *registryService) Delete(ctx context.Context, request *api.DeleteRequest) (*api.DeleteResponse, error) {
 
-	// TODO: validate the provided participant details
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
 
-	// add the participant as an entry in the registry db
-	rs.db[request.GetParticipant().GetId()] = *request.GetParticipant()
+	// delete the participant
+	req := &apiv2.DeleteRequest{}
+	if err := toLatest(request, req); err != nil {
+		return nil, err
+	}
+	res, err := rs.latest.Delete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 
 	// create a response and return
-	return &api.UpdateResponse{
-		ApiVersion: rs.version,
-		Updated:    true,
-	}, nil
+	response := &api.DeleteResponse{}
+	return response, rs.fromLatest(res, response)
 }
 
-// Delete will delete a participant from the registry.
-func (rs *registryService) Delete(ctx context.Context, request *api.DeleteRequest) (*api.DeleteResponse, error) {
+// List will list all participants in the registry,
+// ordered by their reference number. Participants who
+// have withdrawn are only listed if requested.
+func (rs *registryService) List(ctx context.Context, request *api.ListRequest) (*api.ListResponse, error) {
 
 	// check we have received a supported API request
 	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
 		return nil, err
 	}
 
-	// lock the db for RW access
-	rs.Lock()
-	defer rs.Unlock()
+	// collect the participants
+	req := &apiv2.ListRequest{}
+	if err := toLatest(request, req); err != nil {
+		return nil, err
+	}
+	res, err := rs.latest.List(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.ListResponse{
+		ApiVersion:   rs.version,
+		Participants: participants(res.GetParticipants()),
+	}, nil
+}
 
-	// check if entry already exists for provided reference number
-	if _, ok := rs.db[request.GetId()]; !ok {
-		return nil, status.Errorf(codes.NotFound,
-			"reference number not found: no participant entry exists in the registry for %v", request.GetId())
+// Search will find the participants in the registry which
+// match the request, ordered by their reference number. Phone
+// numbers match regardless of how they are formatted.
+func (rs *registryService) Search(ctx context.Context, request *api.SearchRequest) (*api.SearchResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
 	}
 
-	// delete the entry from the registry db
-	delete(rs.db, request.GetId())
+	// collect the matching participants
+	req := &apiv2.SearchRequest{}
+	if err := toLatest(request, req); err != nil {
+		return nil, err
+	}
+	res, err := rs.latest.Search(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 
 	// create a response and return
-	return &api.DeleteResponse{
-		ApiVersion: rs.version,
-		Deleted:    true,
+	return &api.SearchResponse{
+		ApiVersion:   rs.version,
+		Participants: participants(res.GetParticipants()),
 	}, nil
 }
+
+// participants will down-convert a list of participants.
+func participants(list []*apiv2.Participant) []*api.Participant {
+	participants := make([]*api.Participant, 0, len(list))
+	for _, participant := range list {
+		participants = append(participants, translate.V2ToV1(participant))
+	}
+	return participants
+}
+
+// GetServerInfo will return information about the server.
+// The requested API version is not checked so that clients
+// can discover which versions are supported.
+func (rs *registryService) GetServerInfo(ctx context.Context, request *api.GetServerInfoRequest) (*api.GetServerInfoResponse, error) {
+	req := &apiv2.GetServerInfoRequest{}
+	if err := toLatest(request, req); err != nil {
+		return nil, err
+	}
+	res, err := rs.latest.GetServerInfo(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	response := &api.GetServerInfoResponse{}
+	return response, rs.fromLatest(res, response)
+}
@@ -7,12 +7,29 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"gotest.tools/assert"
 
+	"github.com/will-rowe/registry-microservice/pkg/allocate"
 	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	"github.com/will-rowe/registry-microservice/pkg/erasure"
 	mock "github.com/will-rowe/registry-microservice/pkg/mock"
+	"github.com/will-rowe/registry-microservice/pkg/normalise"
+	servicev2 "github.com/will-rowe/registry-microservice/pkg/service/v2"
+	"github.com/will-rowe/registry-microservice/pkg/store"
 )
 
+// normaliser is used by the
+// registry service in the tests.
+var normaliser, _ = normalise.New("GB")
+
+// newService creates the registry service, which runs
+// requests using a v2 service with the options.
+func newService(opts servicev2.Options) api.RegistryServiceServer {
+	return NewRegistryService(servicev2.NewRegistryService(store.New(), normaliser, opts))
+}
+
 // newParticipant is a helper function to
 // create a populated Participant struct
 // for use in the tests.
@@ -22,7 +39,7 @@ func newParticipant() *api.Participant {
 	return &api.Participant{
 		Id:      "KFG-734",
 		Dob:     dob,
-		Phone:   "123",
+		Phone:   "+447700900123",
 		Address: "The moon",
 	}
 }
@@ -131,6 +148,32 @@ func TestRegistryService_Delete(t *testing.T) {
 	assert.Equal(t, res.Deleted, true)
 }
 
+// TestRegistryService_List will test the implementation of the
+// List rpc by the RegistryService.
+func TestRegistryService_List(t *testing.T) {
+
+	// setup go mock
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mock.NewMockRegistryServiceClient(ctrl)
+
+	// get a dummy participant and create a request
+	p := newParticipant()
+	req := &api.ListRequest{ApiVersion: apiVersion}
+
+	// run the mock
+	mockClient.EXPECT().List(
+		gomock.Any(),
+		req,
+	).Times(1).Return(&api.ListResponse{ApiVersion: apiVersion, Participants: []*api.Participant{p}}, nil)
+	res, err := mockClient.List(context.Background(), &api.ListRequest{ApiVersion: apiVersion})
+
+	// check the results
+	assert.NilError(t, err)
+	assert.Equal(t, len(res.GetParticipants()), 1)
+	assert.Equal(t, res.GetParticipants()[0].GetId(), p.GetId())
+}
+
 // TestAPIversion will check that API version requests
 // are handled appropriately.
 func TestAPIversion(t *testing.T) {
@@ -149,7 +192,7 @@ func TestAPIversion(t *testing.T) {
 // db checking.
 func TestDB(t *testing.T) {
 	req := &api.CreateRequest{ApiVersion: apiVersion, Participant: newParticipant()}
-	rs := NewRegistryService()
+	rs := newService(servicev2.Options{})
 	if _, err := rs.Create(context.Background(), req); err != nil {
 		t.Fatal(err)
 	}
@@ -161,3 +204,140 @@ func TestDB(t *testing.T) {
 		t.Fatal("non-existent participant removed from db")
 	}
 }
+
+// TestList will check that the db lists
+// participants in reference number order.
+func TestList(t *testing.T) {
+	rs := newService(servicev2.Options{})
+	for _, id := range []string{"KFG-734", "ABC-123", "XYZ-999"} {
+		p := newParticipant()
+		p.Id = id
+		if _, err := rs.Create(context.Background(), &api.CreateRequest{ApiVersion: apiVersion, Participant: p}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := rs.List(context.Background(), &api.ListRequest{ApiVersion: apiVersion})
+	assert.NilError(t, err)
+	assert.Equal(t, len(res.GetParticipants()), 3)
+	assert.Equal(t, res.GetParticipants()[0].GetId(), "ABC-123")
+	assert.Equal(t, res.GetParticipants()[2].GetId(), "XYZ-999")
+}
+
+// TestRevision will check that updates can be
+// guarded against concurrent modification.
+func TestRevision(t *testing.T) {
+	rs := newService(servicev2.Options{})
+	p := newParticipant()
+	_, err := rs.Create(context.Background(), &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+	res, err := rs.Retrieve(context.Background(), &api.RetrieveRequest{ApiVersion: apiVersion, Id: p.GetId()})
+	assert.NilError(t, err)
+	assert.Equal(t, res.GetRevision(), uint64(1))
+
+	// update at the expected revision
+	updated, err := rs.Update(context.Background(), &api.UpdateRequest{ApiVersion: apiVersion, Participant: p, ExpectedRevision: 1})
+	assert.NilError(t, err)
+	assert.Equal(t, updated.GetRevision(), uint64(2))
+
+	// update using the stale revision
+	_, err = rs.Update(context.Background(), &api.UpdateRequest{ApiVersion: apiVersion, Participant: p, ExpectedRevision: 1})
+	assert.Equal(t, status.Code(err), codes.Aborted)
+
+	// unconditional update
+	_, err = rs.Update(context.Background(), &api.UpdateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+}
+
+// TestDuplicates will check that possible duplicates are
+// reported when a participant is created and on request.
+func TestDuplicates(t *testing.T) {
+	rs := newService(servicev2.Options{})
+	p := newParticipant()
+	_, err := rs.Create(context.Background(), &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+	p.Id = "ABC-123"
+	p.Phone = "07700 900123"
+	res, err := rs.Create(context.Background(), &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+	assert.Equal(t, res.GetCreated(), true)
+	assert.Equal(t, len(res.GetPossibleDuplicates()), 1)
+	assert.Equal(t, res.GetPossibleDuplicates()[0].GetDuplicateId(), "KFG-734")
+	report, err := rs.FindDuplicates(context.Background(), &api.FindDuplicatesRequest{ApiVersion: apiVersion})
+	assert.NilError(t, err)
+	assert.Equal(t, len(report.GetDuplicates()), 1)
+	_, err = rs.FindDuplicates(context.Background(), &api.FindDuplicatesRequest{ApiVersion: apiVersion, Id: "XYZ-999"})
+	assert.Equal(t, status.Code(err), codes.NotFound)
+	_, err = rs.FindDuplicates(context.Background(), &api.FindDuplicatesRequest{ApiVersion: apiVersion, MinScore: 2})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+}
+
+// TestAllocate will check that reference numbers are allocated
+// to participants created without one, if the server allocates
+// reference numbers.
+func TestAllocate(t *testing.T) {
+	p := newParticipant()
+	p.Id = ""
+	rs := newService(servicev2.Options{})
+	_, err := rs.Create(context.Background(), &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+
+	// allocated reference numbers are returned
+	allocator, err := allocate.New(allocate.DefaultPattern)
+	assert.NilError(t, err)
+	rs = newService(servicev2.Options{Allocator: allocator})
+	res, err := rs.Create(context.Background(), &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+	assert.Assert(t, allocator.Valid(res.GetId()), res.GetId())
+	retrieved, err := rs.Retrieve(context.Background(), &api.RetrieveRequest{ApiVersion: apiVersion, Id: res.GetId()})
+	assert.NilError(t, err)
+	assert.Equal(t, retrieved.GetParticipant().GetId(), res.GetId())
+
+	// provided reference numbers are kept unless they
+	// have the form of the pattern but are mistyped
+	mistyped := []byte(res.GetId())
+	if mistyped[4] == '0' {
+		mistyped[4]++
+	} else {
+		mistyped[4]--
+	}
+	p.Id = string(mistyped)
+	_, err = rs.Create(context.Background(), &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+	p.Id = "KFG-734"
+	res, err = rs.Create(context.Background(), &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+	assert.Equal(t, res.GetId(), "KFG-734")
+}
+
+// TestLatest will check that the features of the
+// latest API version are served using v1.
+func TestLatest(t *testing.T) {
+	ctx := context.Background()
+	signer, err := erasure.NewRandomSigner()
+	assert.NilError(t, err)
+	rs := newService(servicev2.Options{Signer: signer})
+	info, err := rs.GetServerInfo(ctx, &api.GetServerInfoRequest{})
+	assert.NilError(t, err)
+	assert.Equal(t, info.GetApiVersion(), apiVersion)
+	assert.DeepEqual(t, info.GetErasurePublicKey(), []byte(signer.PublicKey()))
+
+	// erase a participant using v1
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: newParticipant()})
+	assert.NilError(t, err)
+	res, err := rs.Erase(ctx, &api.EraseRequest{ApiVersion: apiVersion, Id: "KFG-734"})
+	assert.NilError(t, err)
+	assert.Equal(t, res.GetApiVersion(), apiVersion)
+	assert.Equal(t, res.GetReceipt().GetHistoryEntriesScrubbed(), uint32(1))
+	assert.Equal(t, erasure.Digest("KFG-734", res.GetNonce()), res.GetReceipt().GetSubjectDigest())
+	retrieved, err := rs.RetrieveErasureReceipt(ctx, &api.RetrieveErasureReceiptRequest{ApiVersion: apiVersion, ReceiptId: res.GetReceipt().GetReceiptId()})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, retrieved.GetReceipt().GetSignature(), res.GetReceipt().GetSignature())
+	_, err = rs.Retrieve(ctx, &api.RetrieveRequest{ApiVersion: apiVersion, Id: "KFG-734"})
+	assert.Equal(t, status.Code(err), codes.NotFound)
+
+	// features which are not enabled are reported
+	_, err = rs.ExportPseudonymised(ctx, &api.ExportPseudonymisedRequest{ApiVersion: apiVersion})
+	assert.Equal(t, status.Code(err), codes.Unimplemented)
+	_, err = rs.Erase(ctx, &api.EraseRequest{ApiVersion: "2", Id: "KFG-734"})
+	assert.Equal(t, status.Code(err), codes.Unimplemented)
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	apiv2 "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// RetentionReport will report the actions the retention
+// rules of the server require, without taking them.
+func (rs *registryService) RetentionReport(ctx context.Context, request *api.RetentionReportRequest) (*api.RetentionReportResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// get the actions the rules require
+	req := &apiv2.RetentionReportRequest{}
+	if err := toLatest(request, req); err != nil {
+		return nil, err
+	}
+	res, err := rs.latest.RetentionReport(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	response := &api.RetentionReportResponse{}
+	return response, rs.fromLatest(res, response)
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// DefineAttribute will define a custom participant attribute.
+func (rs *registryService) DefineAttribute(ctx context.Context, request *api.DefineAttributeRequest) (*api.DefineAttributeResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// add the definition to the registry db
+	if err := rs.db.DefineAttribute(request.GetDefinition()); err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.DefineAttributeResponse{
+		ApiVersion: rs.version,
+		Defined:    true,
+	}, nil
+}
+
+// ListAttributes will list the custom participant
+// attribute definitions, ordered by name.
+func (rs *registryService) ListAttributes(ctx context.Context, request *api.ListAttributesRequest) (*api.ListAttributesResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// collect the definitions
+	definitions, err := rs.db.ListAttributes()
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.ListAttributesResponse{
+		ApiVersion:  rs.version,
+		Definitions: definitions,
+	}, nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/store"
+)
+
+// TestAttributes will check that participant attributes
+// are validated against the attribute definitions.
+func TestAttributes(t *testing.T) {
+	ctx := context.Background()
+	db := store.New()
+	rs := NewRegistryService(db, normaliser, Options{})
+	definition := &api.AttributeDefinition{Name: "site", Type: api.AttributeType_ATTRIBUTE_TYPE_STRING}
+	_, err := rs.DefineAttribute(ctx, &api.DefineAttributeRequest{ApiVersion: apiVersion, Definition: definition})
+	assert.NilError(t, err)
+	attributes, err := rs.ListAttributes(ctx, &api.ListAttributesRequest{ApiVersion: apiVersion})
+	assert.NilError(t, err)
+	assert.Equal(t, len(attributes.GetDefinitions()), 1)
+
+	// undefined attributes are rejected
+	p := newParticipant()
+	p.Attributes = map[string]string{"clinician": "Dr Who"}
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+	p.Attributes = map[string]string{"site": "12"}
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+
+	// attributes can only be redefined if participant values are valid
+	definition.Type = api.AttributeType_ATTRIBUTE_TYPE_ENUM
+	definition.EnumValues = []string{"Leeds", "York"}
+	_, err = rs.DefineAttribute(ctx, &api.DefineAttributeRequest{ApiVersion: apiVersion, Definition: definition})
+	assert.Equal(t, status.Code(err), codes.FailedPrecondition)
+	definition.Type = api.AttributeType_ATTRIBUTE_TYPE_INT
+	definition.EnumValues = nil
+	_, err = rs.DefineAttribute(ctx, &api.DefineAttributeRequest{ApiVersion: apiVersion, Definition: definition})
+	assert.NilError(t, err)
+	p.Attributes = map[string]string{"site": "Leeds"}
+	_, err = rs.Update(ctx, &api.UpdateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+
+	// the db checks attributes against the schema it holds,
+	// so values can not bypass a concurrent redefinition
+	other := newParticipant()
+	other.Id = "XYZ-999"
+	other.Attributes = map[string]string{"site": "Leeds"}
+	_, err = db.Create(other)
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+	_, err = db.Update(p, 0)
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+}
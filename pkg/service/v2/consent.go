@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/consent"
+)
+
+// GrantConsent will record consent for a participant.
+func (rs *registryService) GrantConsent(ctx context.Context, request *api.GrantConsentRequest) (*api.GrantConsentResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// add the consent to the registry db entry
+	var granted *api.Consent
+	entry, err := rs.db.UpdateFunc(request.GetId(), 0, func(current *api.Participant) (*api.Participant, error) {
+		updated, c, err := consent.Grant(current, request.GetType(), request.GetFormVersion(), time.Now())
+		granted = c
+		return updated, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.GrantConsentResponse{
+		ApiVersion: rs.version,
+		Consent:    granted,
+		Revision:   entry.Revision,
+	}, nil
+}
+
+// WithdrawConsent will withdraw consent for a participant.
+func (rs *registryService) WithdrawConsent(ctx context.Context, request *api.WithdrawConsentRequest) (*api.WithdrawConsentResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// withdraw the consent in the registry db entry
+	var withdrawn []*api.Consent
+	entry, err := rs.db.UpdateFunc(request.GetId(), 0, func(current *api.Participant) (*api.Participant, error) {
+		updated, c, err := consent.Withdraw(current, request.GetType(), time.Now())
+		withdrawn = c
+		return updated, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.WithdrawConsentResponse{
+		ApiVersion: rs.version,
+		Consents:   withdrawn,
+		Revision:   entry.Revision,
+	}, nil
+}
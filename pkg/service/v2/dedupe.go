@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/dedupe"
+)
+
+// FindDuplicates will find participants which may be
+// registered more than once, highest score first.
+func (rs *registryService) FindDuplicates(ctx context.Context, request *api.FindDuplicatesRequest) (*api.FindDuplicatesResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+	minScore := request.GetMinScore()
+	if minScore < 0 || minScore > 1 {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"invalid minimum score: %v must be between 0 and 1", minScore)
+	}
+	if minScore == 0 {
+		minScore = dedupe.DefaultMinScore
+	}
+
+	// find the duplicates of the participant, or of everyone
+	participants, err := rs.participants()
+	if err != nil {
+		return nil, err
+	}
+	var matches []dedupe.Match
+	if request.GetId() != "" {
+		entry, err := rs.db.Get(request.GetId())
+		if err != nil {
+			return nil, err
+		}
+		matches = dedupe.Find(entry.Participant, participants, minScore)
+	} else {
+		matches = dedupe.Report(participants, minScore)
+	}
+
+	// create a response and return
+	return &api.FindDuplicatesResponse{
+		ApiVersion: rs.version,
+		Duplicates: possibleDuplicates(matches),
+	}, nil
+}
+
+// duplicates will find the participants in the registry
+// which may be duplicates of the participant.
+func (rs *registryService) duplicates(p *api.Participant) ([]dedupe.Match, error) {
+	participants, err := rs.participants()
+	if err != nil {
+		return nil, err
+	}
+	return dedupe.Find(p, participants, dedupe.DefaultMinScore), nil
+}
+
+// participants returns every participant in the registry.
+func (rs *registryService) participants() ([]*api.Participant, error) {
+	entries, err := rs.db.List()
+	if err != nil {
+		return nil, err
+	}
+	participants := make([]*api.Participant, 0, len(entries))
+	for _, entry := range entries {
+		participants = append(participants, entry.Participant)
+	}
+	return participants, nil
+}
+
+// possibleDuplicates converts the matches
+// to the API message.
+func possibleDuplicates(matches []dedupe.Match) []*api.PossibleDuplicate {
+	duplicates := make([]*api.PossibleDuplicate, 0, len(matches))
+	for _, match := range matches {
+		duplicates = append(duplicates, &api.PossibleDuplicate{
+			Id:          match.ID,
+			DuplicateId: match.DuplicateID,
+			Score:       match.Score,
+			Reasons:     match.Reasons,
+		})
+	}
+	return duplicates
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/erasure"
+)
+
+// Erase will erase a participant from the registry,
+// removing every record of them held by the registry,
+// and return a signed erasure receipt.
+func (rs *registryService) Erase(ctx context.Context, request *api.EraseRequest) (*api.EraseResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+	if rs.signer == nil {
+		return nil, status.Error(codes.Unimplemented, "erasure is not enabled on the server")
+	}
+
+	// erase the participant from the registry db, keeping
+	// the nonce of the receipt out of the db so that only
+	// the requester can check the receipt
+	var nonce string
+	receipt, err := rs.db.Erase(request.GetId(), func(counts erasure.Counts) (*api.ErasureReceipt, error) {
+		receipt, n, err := rs.signer.Receipt(request.GetId(), counts, time.Now())
+		nonce = n
+		return receipt, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.EraseResponse{
+		ApiVersion: rs.version,
+		Receipt:    receipt,
+		Nonce:      nonce,
+	}, nil
+}
+
+// RetrieveErasureReceipt will retrieve an
+// erasure receipt from the registry.
+func (rs *registryService) RetrieveErasureReceipt(ctx context.Context, request *api.RetrieveErasureReceiptRequest) (*api.RetrieveErasureReceiptResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// get the receipt for the provided receipt id
+	receipt, err := rs.db.ErasureReceipt(request.GetReceiptId())
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.RetrieveErasureReceiptResponse{
+		ApiVersion: rs.version,
+		Receipt:    receipt,
+	}, nil
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/erasure"
+	"github.com/will-rowe/registry-microservice/pkg/store"
+)
+
+// TestErase will check that every record of an erased
+// participant is removed and that the receipt verifies.
+func TestErase(t *testing.T) {
+	ctx := context.Background()
+	_, err := NewRegistryService(store.New(), normaliser, Options{}).Erase(ctx, &api.EraseRequest{ApiVersion: apiVersion, Id: "KFG-734"})
+	assert.Equal(t, status.Code(err), codes.Unimplemented)
+
+	// add a participant with a merged duplicate, an
+	// enrollment and an external identifier
+	signer, err := erasure.NewRandomSigner()
+	assert.NilError(t, err)
+	rs := NewRegistryService(store.New(), normaliser, Options{Signer: signer})
+	_, err = rs.CreateStudy(ctx, &api.CreateStudyRequest{ApiVersion: apiVersion, Study: &api.Study{Id: "STUDY-1"}})
+	assert.NilError(t, err)
+	p := newParticipant()
+	p.Identifiers = []*api.ExternalIdentifier{{System: "nhs", Value: "9434765919"}}
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+	duplicate := newParticipant()
+	duplicate.Id = "ABC-123"
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: duplicate})
+	assert.NilError(t, err)
+	_, err = rs.Merge(ctx, &api.MergeRequest{ApiVersion: apiVersion, Id: "KFG-734", DuplicateId: "ABC-123"})
+	assert.NilError(t, err)
+	_, err = rs.Enroll(ctx, &api.EnrollRequest{ApiVersion: apiVersion, Enrollment: &api.Enrollment{ParticipantId: "KFG-734", StudyId: "STUDY-1"}})
+	assert.NilError(t, err)
+	other := newParticipant()
+	other.Id = "XYZ-999"
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: other})
+	assert.NilError(t, err)
+
+	// erase the participant and check the receipt
+	res, err := rs.Erase(ctx, &api.EraseRequest{ApiVersion: apiVersion, Id: "KFG-734"})
+	assert.NilError(t, err)
+	receipt := res.GetReceipt()
+	assert.Equal(t, receipt.GetAliasesErased(), uint32(1))
+	assert.Equal(t, receipt.GetEnrollmentsErased(), uint32(1))
+	assert.Equal(t, receipt.GetIdentifiersErased(), uint32(1))
+	assert.Equal(t, receipt.GetHistoryEntriesScrubbed(), uint32(3))
+	info, err := rs.GetServerInfo(ctx, &api.GetServerInfoRequest{})
+	assert.NilError(t, err)
+	assert.Equal(t, erasure.Verify(receipt, info.GetErasurePublicKey(), "KFG-734", res.GetNonce()), true)
+	retrieved, err := rs.RetrieveErasureReceipt(ctx, &api.RetrieveErasureReceiptRequest{ApiVersion: apiVersion, ReceiptId: receipt.GetReceiptId()})
+	assert.NilError(t, err)
+	assert.Equal(t, retrieved.GetReceipt().GetSubjectDigest(), receipt.GetSubjectDigest())
+
+	// the nonce is not kept, so the retrieved receipt
+	// can only be checked against the participant using
+	// the nonce given to the requester
+	assert.Assert(t, res.GetNonce() != "")
+	assert.Equal(t, erasure.Verify(retrieved.GetReceipt(), info.GetErasurePublicKey(), "KFG-734", res.GetNonce()), true)
+	assert.Assert(t, !strings.Contains(retrieved.String(), res.GetNonce()))
+
+	// no records of the participant remain
+	for _, id := range []string{"KFG-734", "ABC-123"} {
+		_, err = rs.Retrieve(ctx, &api.RetrieveRequest{ApiVersion: apiVersion, Id: id})
+		assert.Equal(t, status.Code(err), codes.NotFound)
+		_, err = rs.ListHistory(ctx, &api.ListHistoryRequest{ApiVersion: apiVersion, Id: id})
+		assert.Equal(t, status.Code(err), codes.NotFound)
+	}
+	_, err = rs.RetrieveByExternalId(ctx, &api.RetrieveByExternalIdRequest{ApiVersion: apiVersion, Identifier: &api.ExternalIdentifier{System: "nhs", Value: "9434765919"}})
+	assert.Equal(t, status.Code(err), codes.NotFound)
+	enrollments, err := rs.ListEnrollments(ctx, &api.ListEnrollmentsRequest{ApiVersion: apiVersion, StudyId: "STUDY-1"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(enrollments.GetEnrollments()), 0)
+
+	// other participants are kept
+	history, err := rs.ListHistory(ctx, &api.ListHistoryRequest{ApiVersion: apiVersion, Id: "XYZ-999"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(history.GetMutations()), 1)
+}
+
+// TestEraseDeleted will check that deleting a participant
+// removes their details from the history, and that deleted
+// participants can still be erased, including by an alias.
+func TestEraseDeleted(t *testing.T) {
+	ctx := context.Background()
+	signer, err := erasure.NewRandomSigner()
+	assert.NilError(t, err)
+	rs := NewRegistryService(store.New(), normaliser, Options{Signer: signer})
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: newParticipant()})
+	assert.NilError(t, err)
+	duplicate := newParticipant()
+	duplicate.Id = "ABC-123"
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: duplicate})
+	assert.NilError(t, err)
+	_, err = rs.Merge(ctx, &api.MergeRequest{ApiVersion: apiVersion, Id: "KFG-734", DuplicateId: "ABC-123"})
+	assert.NilError(t, err)
+
+	// the deleted participant's details are removed from the history
+	_, err = rs.Delete(ctx, &api.DeleteRequest{ApiVersion: apiVersion, Id: "KFG-734"})
+	assert.NilError(t, err)
+	history, err := rs.ListHistory(ctx, &api.ListHistoryRequest{ApiVersion: apiVersion, Id: "KFG-734"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(history.GetMutations()), 3)
+	for _, mutation := range history.GetMutations() {
+		assert.Assert(t, mutation.GetParticipant() == nil)
+	}
+
+	// the deleted participant can be erased using the merged reference number
+	res, err := rs.Erase(ctx, &api.EraseRequest{ApiVersion: apiVersion, Id: "ABC-123"})
+	assert.NilError(t, err)
+	assert.Equal(t, res.GetReceipt().GetAliasesErased(), uint32(1))
+	assert.Equal(t, res.GetReceipt().GetIdentifiersErased(), uint32(0))
+	assert.Equal(t, res.GetReceipt().GetHistoryEntriesScrubbed(), uint32(4))
+	for _, id := range []string{"KFG-734", "ABC-123"} {
+		_, err = rs.ListHistory(ctx, &api.ListHistoryRequest{ApiVersion: apiVersion, Id: id})
+		assert.Equal(t, status.Code(err), codes.NotFound)
+	}
+
+	// once erased, no records remain to erase
+	_, err = rs.Erase(ctx, &api.EraseRequest{ApiVersion: apiVersion, Id: "KFG-734"})
+	assert.Equal(t, status.Code(err), codes.NotFound)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/consent"
+)
+
+// ExportPseudonymised will export the participants in the
+// registry, or those enrolled in a study, with their direct
+// identifiers removed. Participants who have withdrawn are
+// never exported.
+func (rs *registryService) ExportPseudonymised(ctx context.Context, request *api.ExportPseudonymisedRequest) (*api.ExportPseudonymisedResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+	if rs.pseudonymiser == nil {
+		return nil, status.Error(codes.Unimplemented, "pseudonymised export is not enabled on the server")
+	}
+
+	// pseudonymise the participants
+	enrolled, err := rs.studyParticipants(request.GetStudyId())
+	if err != nil {
+		return nil, err
+	}
+	entries, err := rs.db.List()
+	if err != nil {
+		return nil, err
+	}
+	ageBand := request.GetDobPrecision() == api.DobPrecision_DOB_PRECISION_AGE_BAND
+	now := time.Now()
+	participants := make([]*api.PseudonymisedParticipant, 0, len(entries))
+	for _, entry := range entries {
+		if consent.IsWithdrawn(entry.Participant) {
+			continue
+		}
+		if enrolled != nil && !enrolled[entry.Participant.GetId()] {
+			continue
+		}
+		record := rs.pseudonymiser.Participant(entry.Participant, ageBand, now)
+		participants = append(participants, &api.PseudonymisedParticipant{
+			Pseudonym:        record.Pseudonym,
+			BirthYear:        record.BirthYear,
+			AgeBand:          record.AgeBand,
+			PostcodeDistrict: record.PostcodeDistrict,
+			Country:          record.Country,
+			SexAtBirth:       record.SexAtBirth,
+		})
+	}
+
+	// order by pseudonym, so the order does not
+	// reveal the reference numbers
+	sort.Slice(participants, func(i, j int) bool {
+		return participants[i].GetPseudonym() < participants[j].GetPseudonym()
+	})
+
+	// create a response and return
+	return &api.ExportPseudonymisedResponse{
+		ApiVersion:   rs.version,
+		Participants: participants,
+	}, nil
+}
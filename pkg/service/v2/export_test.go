@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/pseudonymise"
+	"github.com/will-rowe/registry-microservice/pkg/store"
+)
+
+// TestExportPseudonymised will check that participants are
+// exported with their direct identifiers removed, and that
+// withdrawn participants are not exported.
+func TestExportPseudonymised(t *testing.T) {
+	ctx := context.Background()
+	request := &api.ExportPseudonymisedRequest{ApiVersion: apiVersion}
+	_, err := NewRegistryService(store.New(), normaliser, Options{}).ExportPseudonymised(ctx, request)
+	assert.Equal(t, status.Code(err), codes.Unimplemented)
+
+	// add a participant who has withdrawn and one who has not
+	pseudonymiser, err := pseudonymise.New([]byte("0123456789abcdef"))
+	assert.NilError(t, err)
+	rs := NewRegistryService(store.New(), normaliser, Options{Pseudonymiser: pseudonymiser})
+	p := newParticipant()
+	p.Address.Postcode = "sw1a1aa"
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+	p.Id = "ABC-123"
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+	_, err = rs.GrantConsent(ctx, &api.GrantConsentRequest{ApiVersion: apiVersion, Id: "ABC-123", Type: "data-sharing", FormVersion: "1"})
+	assert.NilError(t, err)
+	_, err = rs.WithdrawConsent(ctx, &api.WithdrawConsentRequest{ApiVersion: apiVersion, Id: "ABC-123"})
+	assert.NilError(t, err)
+
+	// only the participant who has not withdrawn is exported
+	res, err := rs.ExportPseudonymised(ctx, request)
+	assert.NilError(t, err)
+	assert.Equal(t, len(res.GetParticipants()), 1)
+	exported := res.GetParticipants()[0]
+	assert.Equal(t, exported.GetPseudonym(), pseudonymiser.Pseudonym("KFG-734"))
+	assert.Equal(t, exported.GetBirthYear(), int32(1980))
+	assert.Equal(t, exported.GetPostcodeDistrict(), "SW1A")
+	request.DobPrecision = api.DobPrecision_DOB_PRECISION_AGE_BAND
+	res, err = rs.ExportPseudonymised(ctx, request)
+	assert.NilError(t, err)
+	assert.Equal(t, res.GetParticipants()[0].GetBirthYear(), int32(0))
+	assert.Assert(t, res.GetParticipants()[0].GetAgeBand() != "")
+}
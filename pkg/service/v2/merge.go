@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/merge"
+)
+
+// Merge will merge a duplicate participant into the
+// surviving participant, keeping the reference number
+// of the duplicate as an alias of the survivor.
+func (rs *registryService) Merge(ctx context.Context, request *api.MergeRequest) (*api.MergeResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// merge the participants in the registry db
+	entry, err := rs.db.Merge(request.GetId(), request.GetDuplicateId(), func(survivor, duplicate *api.Participant) (*api.Participant, error) {
+		return merge.Participants(survivor, duplicate, request.GetResolutions())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.MergeResponse{
+		ApiVersion:  rs.version,
+		Participant: entry.Participant,
+		Revision:    entry.Revision,
+	}, nil
+}
+
+// ListHistory will list the mutations of a participant,
+// which can be a deleted or merged participant.
+func (rs *registryService) ListHistory(ctx context.Context, request *api.ListHistoryRequest) (*api.ListHistoryResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// get the history for the provided reference number
+	mutations, err := rs.db.History(request.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.ListHistoryResponse{
+		ApiVersion: rs.version,
+		Mutations:  mutations,
+	}, nil
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gotest.tools/assert"
+	"gotest.tools/assert/cmp"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/store"
+)
+
+// TestMerge will check that merged participants are
+// retrieved using either reference number and that the
+// merge is recorded in the mutation history.
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+	rs := NewRegistryService(store.New(), normaliser, Options{})
+	_, err := rs.CreateStudy(ctx, &api.CreateStudyRequest{ApiVersion: apiVersion, Study: &api.Study{Id: "STUDY-1"}})
+	assert.NilError(t, err)
+	survivor := newParticipant()
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: survivor})
+	assert.NilError(t, err)
+	duplicate := newParticipant()
+	duplicate.Id = "ABC-123"
+	duplicate.GivenName = "Augusta"
+	duplicate.Emails = []*api.EmailAddress{{Address: "augusta@example.com"}}
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: duplicate})
+	assert.NilError(t, err)
+	_, err = rs.Enroll(ctx, &api.EnrollRequest{ApiVersion: apiVersion, Enrollment: &api.Enrollment{ParticipantId: "ABC-123", StudyId: "STUDY-1"}})
+	assert.NilError(t, err)
+
+	// conflicting fields must be resolved
+	request := &api.MergeRequest{ApiVersion: apiVersion, Id: "KFG-734", DuplicateId: "ABC-123"}
+	_, err = rs.Merge(ctx, request)
+	assert.Equal(t, status.Code(err), codes.FailedPrecondition)
+	request.Resolutions = map[string]api.MergeSource{"given_name": api.MergeSource_MERGE_SOURCE_SURVIVOR}
+	merged, err := rs.Merge(ctx, request)
+	assert.NilError(t, err)
+	assert.Equal(t, merged.GetRevision(), uint64(2))
+	assert.Equal(t, merged.GetParticipant().GetGivenName(), "Ada")
+	assert.Equal(t, len(merged.GetParticipant().GetEmails()), 2)
+	_, err = rs.Merge(ctx, request)
+	assert.Equal(t, status.Code(err), codes.NotFound)
+
+	// the duplicate is an alias of the survivor
+	retrieved, err := rs.Retrieve(ctx, &api.RetrieveRequest{ApiVersion: apiVersion, Id: "ABC-123"})
+	assert.NilError(t, err)
+	assert.Equal(t, retrieved.GetParticipant().GetId(), "KFG-734")
+	assert.DeepEqual(t, retrieved.GetParticipant().GetAliases(), []string{"ABC-123"})
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: duplicate})
+	assert.Equal(t, status.Code(err), codes.AlreadyExists)
+	list, err := rs.List(ctx, &api.ListRequest{ApiVersion: apiVersion})
+	assert.NilError(t, err)
+	assert.Equal(t, len(list.GetParticipants()), 1)
+	enrollments, err := rs.ListEnrollments(ctx, &api.ListEnrollmentsRequest{ApiVersion: apiVersion, StudyId: "STUDY-1"})
+	assert.NilError(t, err)
+	assert.Equal(t, enrollments.GetEnrollments()[0].GetParticipantId(), "KFG-734")
+
+	// aliases are kept by updates
+	_, err = rs.Update(ctx, &api.UpdateRequest{ApiVersion: apiVersion, Participant: survivor})
+	assert.NilError(t, err)
+	retrieved, err = rs.Retrieve(ctx, &api.RetrieveRequest{ApiVersion: apiVersion, Id: "KFG-734"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, retrieved.GetParticipant().GetAliases(), []string{"ABC-123"})
+
+	// the merge is recorded in the history of both participants
+	history, err := rs.ListHistory(ctx, &api.ListHistoryRequest{ApiVersion: apiVersion, Id: "KFG-734"})
+	assert.NilError(t, err)
+	types := []api.MutationType{}
+	for _, mutation := range history.GetMutations() {
+		types = append(types, mutation.GetType())
+	}
+	assert.DeepEqual(t, types, []api.MutationType{
+		api.MutationType_MUTATION_TYPE_CREATED,
+		api.MutationType_MUTATION_TYPE_MERGED,
+		api.MutationType_MUTATION_TYPE_UPDATED,
+	})
+	history, err = rs.ListHistory(ctx, &api.ListHistoryRequest{ApiVersion: apiVersion, Id: "ABC-123"})
+	assert.NilError(t, err)
+	assert.Equal(t, history.GetMutations()[1].GetMergedId(), "ABC-123")
+	assert.Assert(t, history.GetMutations()[1].GetParticipant() == nil)
+	assert.Assert(t, cmp.Contains(history.GetMutations()[1].GetChangedFields(), "aliases"))
+	_, err = rs.ListHistory(ctx, &api.ListHistoryRequest{ApiVersion: apiVersion, Id: "XYZ-999"})
+	assert.Equal(t, status.Code(err), codes.NotFound)
+}
+
+// TestHistory will check that the history holds the
+// changed fields rather than the participant details,
+// and that the oldest mutations are dropped once the
+// history is over the limit.
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	db := store.New()
+	rs := NewRegistryService(db, normaliser, Options{})
+	participant := newParticipant()
+	_, err := rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: participant})
+	assert.NilError(t, err)
+	participant.GivenName = "Augusta"
+	participant.Emails = nil
+	_, err = rs.Update(ctx, &api.UpdateRequest{ApiVersion: apiVersion, Participant: participant})
+	assert.NilError(t, err)
+	history, err := rs.ListHistory(ctx, &api.ListHistoryRequest{ApiVersion: apiVersion, Id: "KFG-734"})
+	assert.NilError(t, err)
+	mutations := history.GetMutations()
+	assert.Equal(t, len(mutations), 2)
+	assert.Assert(t, mutations[0].GetParticipant() == nil)
+	assert.Assert(t, cmp.Contains(mutations[0].GetChangedFields(), "given_name"))
+	assert.DeepEqual(t, mutations[1].GetChangedFields(), []string{"given_name", "emails"})
+
+	// the oldest mutations are dropped
+	db.SetHistoryLimit(10)
+	for i := 0; i < 10; i++ {
+		_, err = rs.Update(ctx, &api.UpdateRequest{ApiVersion: apiVersion, Participant: participant})
+		assert.NilError(t, err)
+	}
+	history, err = rs.ListHistory(ctx, &api.ListHistoryRequest{ApiVersion: apiVersion, Id: "KFG-734"})
+	assert.NilError(t, err)
+	mutations = history.GetMutations()
+	assert.Equal(t, len(mutations), 10)
+	assert.Equal(t, mutations[0].GetSequence(), uint64(3))
+	assert.Equal(t, mutations[len(mutations)-1].GetSequence(), uint64(12))
+}
@@ -0,0 +1,360 @@
+//Package service implements the v2 registry service API.
+package service
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/will-rowe/registry-microservice/pkg/allocate"
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/consent"
+	"github.com/will-rowe/registry-microservice/pkg/erasure"
+	"github.com/will-rowe/registry-microservice/pkg/normalise"
+	"github.com/will-rowe/registry-microservice/pkg/pseudonymise"
+	"github.com/will-rowe/registry-microservice/pkg/store"
+	"github.com/will-rowe/registry-microservice/pkg/translate"
+)
+
+var (
+	apiVersion = "2"
+)
+
+// registryService is an implementation
+// of the v2.RegistryServiceServer.
+type registryService struct {
+
+	// version of API implemented by the server
+	version string
+
+	// db is the participant store, which is
+	// shared with the other API versions
+	db *store.Store
+
+	// normaliser normalises participants
+	// before they are stored
+	normaliser *normalise.Normaliser
+
+	// allocator allocates reference numbers for
+	// participants created without one, which is
+	// nil if the server does not allocate them
+	allocator *allocate.Allocator
+
+	// pseudonymiser pseudonymises participants for
+	// export, which is nil if export is not enabled
+	pseudonymiser *pseudonymise.Pseudonymiser
+
+	// signer signs erasure receipts, which is
+	// nil if erasure is not enabled
+	signer *erasure.Signer
+}
+
+// Options are the optional features of the registry
+// service, which are disabled if they are not set.
+type Options struct {
+
+	// Allocator allocates reference numbers for
+	// participants created without one
+	Allocator *allocate.Allocator
+
+	// Pseudonymiser pseudonymises participants for export
+	Pseudonymiser *pseudonymise.Pseudonymiser
+
+	// Signer signs erasure receipts
+	Signer *erasure.Signer
+}
+
+// NewRegistryService creates the registry service using the
+// provided participant store and normaliser, with the
+// optional features set in the options.
+func NewRegistryService(db *store.Store, normaliser *normalise.Normaliser, opts Options) api.RegistryServiceServer {
+	return &registryService{
+		version:       apiVersion,
+		db:            db,
+		normaliser:    normaliser,
+		allocator:     opts.Allocator,
+		pseudonymiser: opts.Pseudonymiser,
+		signer:        opts.Signer,
+	}
+}
+
+// checkAPI checks if requested API version is supported
+// by the server.
+func (rs *registryService) checkAPI(requestedAPI string) error {
+	return translate.CheckVersion(rs.version, requestedAPI)
+}
+
+// Close will close the participant store.
+func (rs *registryService) Close() error {
+	return rs.db.Close()
+}
+
+// Create will create a new participant in the registry.
+func (rs *registryService) Create(ctx context.Context, request *api.CreateRequest) (*api.CreateResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// normalise the provided participant details, which
+	// are validated against the attribute schema by the db
+	participant, err := rs.prepare(request.GetParticipant(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// add the participant as an entry in the registry db
+	entry, err := rs.create(participant)
+	if err != nil {
+		return nil, err
+	}
+
+	// warn about possible duplicates, which are only advisory
+	// so the participant is still created if this fails
+	matches, _ := rs.duplicates(entry.Participant)
+
+	// create a response and return
+	return &api.CreateResponse{
+		ApiVersion:         rs.version,
+		Created:            true,
+		PossibleDuplicates: possibleDuplicates(matches),
+		Id:                 entry.Participant.GetId(),
+	}, nil
+}
+
+// create will add a participant as an entry in the registry
+// db, allocating a reference number if one was not provided.
+// If the server allocates reference numbers, a provided
+// reference number which has the form of the pattern must
+// have the correct check character, so that mistyped
+// reference numbers are rejected. Reference numbers of
+// other forms are allocated by clients, so are accepted.
+func (rs *registryService) create(participant *api.Participant) (*store.Record, error) {
+	switch {
+	case participant.GetId() != "" && rs.allocator != nil && rs.allocator.Matches(participant.GetId()) && !rs.allocator.Valid(participant.GetId()):
+		return nil, status.Errorf(codes.InvalidArgument,
+			"invalid reference number: %v has the form of the reference number pattern %v, but is mistyped", participant.GetId(), rs.allocator.Pattern())
+	case participant.GetId() != "":
+		return rs.db.Create(participant)
+	case rs.allocator == nil:
+		return nil, status.Error(codes.InvalidArgument,
+			"invalid participant: a reference number is required as the server does not allocate reference numbers")
+	default:
+		return rs.db.Allocate(participant, rs.allocator.Next)
+	}
+}
+
+// prepare will normalise participant details before they
+// are stored, keeping the consent records and aliases of
+// the current participant if it is being updated.
+func (rs *registryService) prepare(p, current *api.Participant) (*api.Participant, error) {
+	participant, err := rs.normaliser.Participant(p, current)
+	if err != nil {
+		return nil, err
+	}
+	participant.Consents = current.GetConsents()
+	participant.Aliases = current.GetAliases()
+	return participant, nil
+}
+
+// Retrieve will retrieve a participant from the registry. If
+// the reference number is an alias of a merged participant,
+// the participant it was merged into is returned.
+func (rs *registryService) Retrieve(ctx context.Context, request *api.RetrieveRequest) (*api.RetrieveResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// get the entry for the provided reference number
+	entry, err := rs.db.Get(request.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.RetrieveResponse{
+		ApiVersion:  rs.version,
+		Participant: entry.Participant,
+		Revision:    entry.Revision,
+	}, nil
+}
+
+// RetrieveByExternalId will retrieve a participant from
+// the registry using an external identifier, which is
+// normalised in the same way as stored identifiers.
+func (rs *registryService) RetrieveByExternalId(ctx context.Context, request *api.RetrieveByExternalIdRequest) (*api.RetrieveResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// get the entry holding the provided identifier
+	identifier, err := normalise.Identifier(request.GetIdentifier())
+	if err != nil {
+		return nil, err
+	}
+	entry, err := rs.db.GetByIdentifier(identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.RetrieveResponse{
+		ApiVersion:  rs.version,
+		Participant: entry.Participant,
+		Revision:    entry.Revision,
+	}, nil
+}
+
+// Update will update a participant in the registry.
+// NOTE: this will update all fields, effectively calling delete and then create,
+// apart from the consent records and aliases which are kept
+func (rs *registryService) Update(ctx context.Context, request *api.UpdateRequest) (*api.UpdateResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// replace the participant entry in the registry db with
+	// the normalised participant details, which are validated
+	// against the attribute schema by the db
+	participant := request.GetParticipant()
+	entry, err := rs.db.UpdateFunc(participant.GetId(), request.GetExpectedRevision(), func(current *api.Participant) (*api.Participant, error) {
+		return rs.prepare(participant, current)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.UpdateResponse{
+		ApiVersion: rs.version,
+		Updated:    true,
+		Revision:   entry.Revision,
+	}, nil
+}
+
+// Delete will delete a participant from the registry.
+func (rs *registryService) Delete(ctx context.Context, request *api.DeleteRequest) (*api.DeleteResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// delete the entry from the registry db
+	if err := rs.db.Delete(request.GetId()); err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.DeleteResponse{
+		ApiVersion: rs.version,
+		Deleted:    true,
+	}, nil
+}
+
+// List will list all participants in the registry, or
+// those enrolled in a study, ordered by their reference
+// number. Participants who have withdrawn are only
+// listed if requested.
+func (rs *registryService) List(ctx context.Context, request *api.ListRequest) (*api.ListResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// collect the participants
+	enrolled, err := rs.studyParticipants(request.GetStudyId())
+	if err != nil {
+		return nil, err
+	}
+	entries, err := rs.db.List()
+	if err != nil {
+		return nil, err
+	}
+	participants := make([]*api.Participant, 0, len(entries))
+	for _, entry := range entries {
+		if consent.IsWithdrawn(entry.Participant) && !request.GetIncludeWithdrawn() {
+			continue
+		}
+		if enrolled != nil && !enrolled[entry.Participant.GetId()] {
+			continue
+		}
+		participants = append(participants, entry.Participant)
+	}
+
+	// create a response and return
+	return &api.ListResponse{
+		ApiVersion:   rs.version,
+		Participants: participants,
+	}, nil
+}
+
+// Search will find the participants in the registry which
+// match the request, ordered by their reference number. Phone
+// numbers match regardless of how they are formatted, and the
+// search can be limited to participants enrolled in a study.
+func (rs *registryService) Search(ctx context.Context, request *api.SearchRequest) (*api.SearchResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// canonicalise the phone number to search for
+	phone, err := rs.normaliser.Phone(request.GetPhone())
+	if err != nil {
+		return nil, err
+	}
+
+	// collect the matching participants
+	enrolled, err := rs.studyParticipants(request.GetStudyId())
+	if err != nil {
+		return nil, err
+	}
+	entries, err := rs.db.List()
+	if err != nil {
+		return nil, err
+	}
+	participants := []*api.Participant{}
+	for _, entry := range entries {
+		if enrolled != nil && !enrolled[entry.Participant.GetId()] {
+			continue
+		}
+		for _, number := range entry.Participant.GetPhones() {
+			if number.GetNumber() == phone {
+				participants = append(participants, entry.Participant)
+				break
+			}
+		}
+	}
+
+	// create a response and return
+	return &api.SearchResponse{
+		ApiVersion:   rs.version,
+		Participants: participants,
+	}, nil
+}
+
+// GetServerInfo will return information about the server.
+// The requested API version is not checked so that clients
+// can discover which versions are supported.
+func (rs *registryService) GetServerInfo(ctx context.Context, request *api.GetServerInfoRequest) (*api.GetServerInfoResponse, error) {
+	info := &api.GetServerInfoResponse{
+		ApiVersion:           rs.version,
+		SupportedApiVersions: translate.SupportedVersions,
+		LatestApiVersion:     translate.LatestVersion,
+	}
+	if rs.signer != nil {
+		info.ErasurePublicKey = rs.signer.PublicKey()
+	}
+	return info, nil
+}
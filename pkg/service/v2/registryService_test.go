@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gotest.tools/assert"
+
+	apiv1 "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/normalise"
+	servicev1 "github.com/will-rowe/registry-microservice/pkg/service/v1"
+	"github.com/will-rowe/registry-microservice/pkg/store"
+)
+
+// normaliser is used by the
+// registry service in the tests.
+var normaliser, _ = normalise.New("GB")
+
+// newParticipant is a helper function to
+// create a populated Participant struct
+// for use in the tests.
+func newParticipant() *api.Participant {
+	dob, _ := ptypes.TimestampProto(time.Date(1980, 1, 2, 0, 0, 0, 0, time.UTC))
+	return &api.Participant{
+		Id:         "KFG-734",
+		GivenName:  "Ada",
+		FamilyName: "Lovelace",
+		Dob:        dob,
+		SexAtBirth: api.SexAtBirth_SEX_AT_BIRTH_FEMALE,
+		Address: &api.PostalAddress{
+			Lines:    []string{"1 Crater Road"},
+			Locality: "Tranquility Base",
+			Country:  "GB",
+		},
+		Phones: []*api.PhoneNumber{
+			{Number: "07700 900123", Use: api.ContactUse_CONTACT_USE_MOBILE},
+			{Number: "020 7946 0018", Use: api.ContactUse_CONTACT_USE_WORK},
+		},
+		Emails:                 []*api.EmailAddress{{Address: "ada@example.com", Use: api.ContactUse_CONTACT_USE_HOME}},
+		PreferredContactMethod: api.ContactMethod_CONTACT_METHOD_EMAIL,
+	}
+}
+
+// TestAPIversion will check that API version requests
+// are handled appropriately.
+func TestAPIversion(t *testing.T) {
+	rs := registryService{version: apiVersion}
+	if err := rs.checkAPI("2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.checkAPI("1"); err == nil {
+		t.Fatal("unsupported API missed by service API check")
+	}
+}
+
+// TestRegistryService will check the CRUD
+// rpcs using the richer participant model.
+func TestRegistryService(t *testing.T) {
+	ctx := context.Background()
+	rs := NewRegistryService(store.New(), normaliser, Options{})
+	p := newParticipant()
+	_, err := rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+	res, err := rs.Retrieve(ctx, &api.RetrieveRequest{ApiVersion: apiVersion, Id: p.GetId()})
+	assert.NilError(t, err)
+	assert.Equal(t, res.GetParticipant().GetFamilyName(), "Lovelace")
+	assert.Equal(t, len(res.GetParticipant().GetPhones()), 2)
+	assert.Equal(t, res.GetRevision(), uint64(1))
+
+	// modifying the request must not modify the stored participant
+	p.GivenName = "Augusta"
+	res, err = rs.Retrieve(ctx, &api.RetrieveRequest{ApiVersion: apiVersion, Id: p.GetId()})
+	assert.NilError(t, err)
+	assert.Equal(t, res.GetParticipant().GetGivenName(), "Ada")
+
+	// update, list and delete
+	updated, err := rs.Update(ctx, &api.UpdateRequest{ApiVersion: apiVersion, Participant: p, ExpectedRevision: 1})
+	assert.NilError(t, err)
+	assert.Equal(t, updated.GetRevision(), uint64(2))
+	list, err := rs.List(ctx, &api.ListRequest{ApiVersion: apiVersion})
+	assert.NilError(t, err)
+	assert.Equal(t, list.GetParticipants()[0].GetGivenName(), "Augusta")
+	_, err = rs.Delete(ctx, &api.DeleteRequest{ApiVersion: apiVersion, Id: p.GetId()})
+	assert.NilError(t, err)
+}
+
+// TestSharedStore will check that participants created
+// using v2 can be retrieved using v1 from the same store.
+func TestSharedStore(t *testing.T) {
+	ctx := context.Background()
+	db := store.New()
+	rs := NewRegistryService(db, normaliser, Options{})
+	rsv1 := servicev1.NewRegistryService(NewRegistryService(db, normaliser, Options{}))
+	p := newParticipant()
+	_, err := rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+	res, err := rsv1.Retrieve(ctx, &apiv1.RetrieveRequest{ApiVersion: "1", Id: p.GetId()})
+	assert.NilError(t, err)
+	assert.Equal(t, res.GetParticipant().GetPhone(), "+447700900123")
+	assert.Equal(t, res.GetParticipant().GetAddress(), "1 Crater Road, Tranquility Base, GB")
+}
+
+// TestMissingDetails will check that requests without a
+// participant, or updates removing an empty address, are
+// handled rather than crashing the server.
+func TestMissingDetails(t *testing.T) {
+	ctx := context.Background()
+	rs := NewRegistryService(store.New(), normaliser, Options{})
+	_, err := rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+
+	// an address which normalises to nothing is stored
+	// as an empty address, which the update then omits
+	for _, address := range []*api.PostalAddress{{}, {Lines: []string{","}}} {
+		p := newParticipant()
+		p.Address = address
+		_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+		assert.NilError(t, err)
+		p.Address = nil
+		_, err = rs.Update(ctx, &api.UpdateRequest{ApiVersion: apiVersion, Participant: p})
+		assert.NilError(t, err)
+		res, err := rs.Retrieve(ctx, &api.RetrieveRequest{ApiVersion: apiVersion, Id: p.GetId()})
+		assert.NilError(t, err)
+		assert.Assert(t, res.GetParticipant().GetAddress() == nil)
+		_, err = rs.Delete(ctx, &api.DeleteRequest{ApiVersion: apiVersion, Id: p.GetId()})
+		assert.NilError(t, err)
+	}
+}
+
+// TestVersionTranslation will check that v1 updates keep the
+// details which can only be held by v2, and that both versions
+// advertise the supported API versions.
+func TestVersionTranslation(t *testing.T) {
+	ctx := context.Background()
+	db := store.New()
+	rs := NewRegistryService(db, normaliser, Options{})
+	rsv1 := servicev1.NewRegistryService(NewRegistryService(db, normaliser, Options{}))
+	p := newParticipant()
+	_, err := rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+
+	// update the phone number using v1
+	res, err := rsv1.Retrieve(ctx, &apiv1.RetrieveRequest{ApiVersion: "1", Id: p.GetId()})
+	assert.NilError(t, err)
+	participant := res.GetParticipant()
+	participant.Phone = "07700 900789"
+	_, err = rsv1.Update(ctx, &apiv1.UpdateRequest{ApiVersion: "1", Participant: participant, ExpectedRevision: res.GetRevision()})
+	assert.NilError(t, err)
+	updated, err := rs.Retrieve(ctx, &api.RetrieveRequest{ApiVersion: apiVersion, Id: p.GetId()})
+	assert.NilError(t, err)
+	assert.Equal(t, updated.GetParticipant().GetPhones()[0].GetNumber(), "+447700900789")
+	assert.Equal(t, updated.GetParticipant().GetFamilyName(), "Lovelace")
+	assert.Equal(t, len(updated.GetParticipant().GetEmails()), 1)
+	assert.Equal(t, updated.GetParticipant().GetAddress().GetLocality(), "Tranquility Base")
+
+	// server info is served regardless of the requested version
+	info, err := rsv1.GetServerInfo(ctx, &apiv1.GetServerInfoRequest{ApiVersion: "3"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, info.GetSupportedApiVersions(), []string{"1", "2"})
+	assert.Equal(t, info.GetLatestApiVersion(), "2")
+}
+
+// TestSearch will check that participants are found
+// by phone number regardless of its format.
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	rs := NewRegistryService(store.New(), normaliser, Options{})
+	_, err := rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: newParticipant()})
+	assert.NilError(t, err)
+	for _, phone := range []string{"07700 900123", "+44 (0)7700-900-123", "020 7946 0018"} {
+		res, err := rs.Search(ctx, &api.SearchRequest{ApiVersion: apiVersion, Phone: phone})
+		assert.NilError(t, err)
+		assert.Equal(t, len(res.GetParticipants()), 1, phone)
+	}
+	res, err := rs.Search(ctx, &api.SearchRequest{ApiVersion: apiVersion, Phone: "07700 900999"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(res.GetParticipants()), 0)
+	_, err = rs.Search(ctx, &api.SearchRequest{ApiVersion: apiVersion, Phone: "123"})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+}
+
+// TestConsent will check that consent is managed using the
+// consent rpcs and that withdrawn participants are only
+// listed when requested.
+func TestConsent(t *testing.T) {
+	ctx := context.Background()
+	db := store.New()
+	rs := NewRegistryService(db, normaliser, Options{})
+	rsv1 := servicev1.NewRegistryService(NewRegistryService(db, normaliser, Options{}))
+	p := newParticipant()
+	p.Consents = []*api.Consent{{Type: "ignored"}}
+	_, err := rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+	granted, err := rs.GrantConsent(ctx, &api.GrantConsentRequest{ApiVersion: apiVersion, Id: p.GetId(), Type: "data-sharing", FormVersion: "1.0"})
+	assert.NilError(t, err)
+	assert.Equal(t, granted.GetRevision(), uint64(2))
+
+	// updates keep the consent records
+	_, err = rs.Update(ctx, &api.UpdateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+	res, err := rs.Retrieve(ctx, &api.RetrieveRequest{ApiVersion: apiVersion, Id: p.GetId()})
+	assert.NilError(t, err)
+	assert.Equal(t, len(res.GetParticipant().GetConsents()), 1)
+	assert.Equal(t, res.GetParticipant().GetConsents()[0].GetType(), "data-sharing")
+
+	// withdrawn participants are excluded from lists
+	_, err = rs.WithdrawConsent(ctx, &api.WithdrawConsentRequest{ApiVersion: apiVersion, Id: p.GetId()})
+	assert.NilError(t, err)
+	list, err := rs.List(ctx, &api.ListRequest{ApiVersion: apiVersion})
+	assert.NilError(t, err)
+	assert.Equal(t, len(list.GetParticipants()), 0)
+	list, err = rs.List(ctx, &api.ListRequest{ApiVersion: apiVersion, IncludeWithdrawn: true})
+	assert.NilError(t, err)
+	assert.Equal(t, len(list.GetParticipants()), 1)
+	listv1, err := rsv1.List(ctx, &apiv1.ListRequest{ApiVersion: "1"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(listv1.GetParticipants()), 0)
+}
+
+// TestExternalIdentifiers will check that participants can
+// be retrieved by external identifiers, which are unique
+// within their identifier system.
+func TestExternalIdentifiers(t *testing.T) {
+	ctx := context.Background()
+	rs := NewRegistryService(store.New(), normaliser, Options{})
+	p := newParticipant()
+	p.Identifiers = []*api.ExternalIdentifier{{System: "NHS", Value: "943 476 5919"}, {System: "mrn-leeds", Value: "A123"}}
+	_, err := rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+	retrieved, err := rs.RetrieveByExternalId(ctx, &api.RetrieveByExternalIdRequest{ApiVersion: apiVersion, Identifier: &api.ExternalIdentifier{System: "nhs", Value: "9434765919"}})
+	assert.NilError(t, err)
+	assert.Equal(t, retrieved.GetParticipant().GetId(), "KFG-734")
+
+	// identifiers can only be held by one participant
+	other := newParticipant()
+	other.Id = "ABC-123"
+	other.Identifiers = []*api.ExternalIdentifier{{System: "nhs", Value: "9434765919"}}
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: other})
+	assert.Equal(t, status.Code(err), codes.AlreadyExists)
+	other.Identifiers = []*api.ExternalIdentifier{{System: "mrn-york", Value: "A123"}}
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: other})
+	assert.NilError(t, err)
+	other.Identifiers = append(other.Identifiers, &api.ExternalIdentifier{System: "mrn-leeds", Value: "A123"})
+	_, err = rs.Update(ctx, &api.UpdateRequest{ApiVersion: apiVersion, Participant: other})
+	assert.Equal(t, status.Code(err), codes.AlreadyExists)
+
+	// identifiers are released when they are removed
+	p.Identifiers = nil
+	_, err = rs.Update(ctx, &api.UpdateRequest{ApiVersion: apiVersion, Participant: p})
+	assert.NilError(t, err)
+	_, err = rs.Update(ctx, &api.UpdateRequest{ApiVersion: apiVersion, Participant: other})
+	assert.NilError(t, err)
+	_, err = rs.RetrieveByExternalId(ctx, &api.RetrieveByExternalIdRequest{ApiVersion: apiVersion, Identifier: &api.ExternalIdentifier{System: "nhs", Value: "9434765919"}})
+	assert.Equal(t, status.Code(err), codes.NotFound)
+	_, err = rs.RetrieveByExternalId(ctx, &api.RetrieveByExternalIdRequest{ApiVersion: apiVersion, Identifier: &api.ExternalIdentifier{System: "nhs"}})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+}
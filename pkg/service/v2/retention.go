@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/retention"
+)
+
+// RetentionReport will report the actions the retention
+// rules of the server require, without taking them.
+func (rs *registryService) RetentionReport(ctx context.Context, request *api.RetentionReportRequest) (*api.RetentionReportResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// get the time to evaluate the rules at
+	now := time.Now()
+	if request.GetAsOf() != nil {
+		asOf, err := ptypes.Timestamp(request.GetAsOf())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid as of time: %v", err)
+		}
+		now = asOf
+	}
+
+	// get the actions from the registry db
+	actions, err := rs.db.RetentionReport(now)
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	response := &api.RetentionReportResponse{
+		ApiVersion: rs.version,
+		Actions:    make([]*api.RetentionAction, 0, len(actions)),
+	}
+	for _, action := range actions {
+		response.Actions = append(response.Actions, retentionAction(action))
+	}
+	return response, nil
+}
+
+// retentionAction converts a retention action to the API.
+func retentionAction(action *retention.Action) *api.RetentionAction {
+	due, _ := ptypes.TimestampProto(action.Due)
+	return &api.RetentionAction{
+		ParticipantId: action.ParticipantID,
+		Delete:        action.Delete,
+		Fields:        action.Fields,
+		Rules:         action.Rules,
+		Due:           due,
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/retention"
+	"github.com/will-rowe/registry-microservice/pkg/store"
+)
+
+// TestRetention will check that the retention report is
+// a dry run and that enforcement redacts and deletes
+// participants, recording the actions in their history.
+func TestRetention(t *testing.T) {
+	ctx := context.Background()
+	policy, err := retention.New([]retention.Rule{
+		{Name: "contact", Status: retention.StatusStudyEnded, After: "5y", Action: retention.ActionRedact, Fields: []string{"phones", "emails", "address"}},
+		{Name: "withdrawn", Status: retention.StatusWithdrawn, After: "1y", Action: retention.ActionDelete},
+	})
+	assert.NilError(t, err)
+	db := store.New()
+	db.SetRetention(policy)
+	rs := NewRegistryService(db, normaliser, Options{})
+
+	// add a participant enrolled in an ended study, and one who has withdrawn
+	ended := time.Date(2015, 3, 31, 0, 0, 0, 0, time.UTC)
+	_, err = rs.CreateStudy(ctx, &api.CreateStudyRequest{ApiVersion: apiVersion, Study: &api.Study{Id: "STUDY-1", EndDate: timestamppb.New(ended)}})
+	assert.NilError(t, err)
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: newParticipant()})
+	assert.NilError(t, err)
+	_, err = rs.Enroll(ctx, &api.EnrollRequest{ApiVersion: apiVersion, Enrollment: &api.Enrollment{ParticipantId: "KFG-734", StudyId: "STUDY-1"}})
+	assert.NilError(t, err)
+	withdrawn := newParticipant()
+	withdrawn.Id = "ABC-123"
+	_, err = rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: withdrawn})
+	assert.NilError(t, err)
+	_, err = rs.GrantConsent(ctx, &api.GrantConsentRequest{ApiVersion: apiVersion, Id: "ABC-123", Type: "registry", FormVersion: "1"})
+	assert.NilError(t, err)
+	_, err = rs.WithdrawConsent(ctx, &api.WithdrawConsentRequest{ApiVersion: apiVersion, Id: "ABC-123"})
+	assert.NilError(t, err)
+
+	// the report is evaluated at the requested time
+	res, err := rs.RetentionReport(ctx, &api.RetentionReportRequest{ApiVersion: apiVersion})
+	assert.NilError(t, err)
+	assert.Equal(t, len(res.GetActions()), 1)
+	assert.Equal(t, res.GetActions()[0].GetParticipantId(), "KFG-734")
+	assert.DeepEqual(t, res.GetActions()[0].GetFields(), []string{"address", "emails", "phones"})
+	res, err = rs.RetentionReport(ctx, &api.RetentionReportRequest{ApiVersion: apiVersion, AsOf: timestamppb.New(time.Now().AddDate(2, 0, 0))})
+	assert.NilError(t, err)
+	assert.Equal(t, len(res.GetActions()), 2)
+	assert.Equal(t, res.GetActions()[0].GetParticipantId(), "ABC-123")
+	assert.Equal(t, res.GetActions()[0].GetDelete(), true)
+	retrieved, err := rs.Retrieve(ctx, &api.RetrieveRequest{ApiVersion: apiVersion, Id: "KFG-734"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(retrieved.GetParticipant().GetPhones()), 2)
+
+	// enforcement redacts the participant, whose history
+	// only holds the names of the changed fields
+	actions, err := db.EnforceRetention(time.Now().AddDate(2, 0, 0))
+	assert.NilError(t, err)
+	assert.Equal(t, len(actions), 2)
+	retrieved, err = rs.Retrieve(ctx, &api.RetrieveRequest{ApiVersion: apiVersion, Id: "KFG-734"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(retrieved.GetParticipant().GetPhones()), 0)
+	assert.Assert(t, retrieved.GetParticipant().GetAddress() == nil)
+	assert.Equal(t, retrieved.GetParticipant().GetGivenName(), "Ada")
+	history, err := rs.ListHistory(ctx, &api.ListHistoryRequest{ApiVersion: apiVersion, Id: "KFG-734"})
+	assert.NilError(t, err)
+	mutations := history.GetMutations()
+	assert.Equal(t, len(mutations), 2)
+	assert.Assert(t, mutations[0].GetParticipant() == nil)
+	assert.Assert(t, mutations[1].GetParticipant() == nil)
+	assert.Equal(t, mutations[1].GetType(), api.MutationType_MUTATION_TYPE_REDACTED)
+	assert.DeepEqual(t, mutations[1].GetChangedFields(), []string{"address", "phones", "emails"})
+	assert.Equal(t, mutations[1].GetReason(), "retention rule contact: redacted address, emails, phones")
+
+	// enforcement deletes the withdrawn participant and their details
+	_, err = rs.Retrieve(ctx, &api.RetrieveRequest{ApiVersion: apiVersion, Id: "ABC-123"})
+	assert.Equal(t, status.Code(err), codes.NotFound)
+	history, err = rs.ListHistory(ctx, &api.ListHistoryRequest{ApiVersion: apiVersion, Id: "ABC-123"})
+	assert.NilError(t, err)
+	mutations = history.GetMutations()
+	for _, mutation := range mutations {
+		assert.Assert(t, mutation.GetParticipant() == nil)
+	}
+	assert.Equal(t, mutations[len(mutations)-1].GetType(), api.MutationType_MUTATION_TYPE_DELETED)
+	assert.Equal(t, mutations[len(mutations)-1].GetReason(), "retention rule withdrawn: deleted")
+
+	// nothing more is required
+	actions, err = db.EnforceRetention(time.Now().AddDate(2, 0, 0))
+	assert.NilError(t, err)
+	assert.Equal(t, len(actions), 0)
+}
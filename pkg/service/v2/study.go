@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// CreateStudy will create a new study in the registry.
+func (rs *registryService) CreateStudy(ctx context.Context, request *api.CreateStudyRequest) (*api.CreateStudyResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+	if request.GetStudy().GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid study: a reference number is required")
+	}
+
+	// add the study to the registry db
+	if err := rs.db.CreateStudy(request.GetStudy()); err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.CreateStudyResponse{
+		ApiVersion: rs.version,
+		Created:    true,
+	}, nil
+}
+
+// RetrieveStudy will retrieve a study from the registry.
+func (rs *registryService) RetrieveStudy(ctx context.Context, request *api.RetrieveStudyRequest) (*api.RetrieveStudyResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// get the study for the provided reference number
+	study, err := rs.db.GetStudy(request.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.RetrieveStudyResponse{
+		ApiVersion: rs.version,
+		Study:      study,
+	}, nil
+}
+
+// UpdateStudy will update a study in the registry.
+func (rs *registryService) UpdateStudy(ctx context.Context, request *api.UpdateStudyRequest) (*api.UpdateStudyResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// replace the study in the registry db
+	if err := rs.db.UpdateStudy(request.GetStudy()); err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.UpdateStudyResponse{
+		ApiVersion: rs.version,
+		Updated:    true,
+	}, nil
+}
+
+// DeleteStudy will delete a study from the registry.
+func (rs *registryService) DeleteStudy(ctx context.Context, request *api.DeleteStudyRequest) (*api.DeleteStudyResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// delete the study from the registry db
+	if err := rs.db.DeleteStudy(request.GetId()); err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.DeleteStudyResponse{
+		ApiVersion: rs.version,
+		Deleted:    true,
+	}, nil
+}
+
+// ListStudies will list all studies in the registry,
+// ordered by their reference number.
+func (rs *registryService) ListStudies(ctx context.Context, request *api.ListStudiesRequest) (*api.ListStudiesResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// collect the studies
+	studies, err := rs.db.ListStudies()
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.ListStudiesResponse{
+		ApiVersion: rs.version,
+		Studies:    studies,
+	}, nil
+}
+
+// Enroll will enroll a participant in a study. The
+// status defaults to enrolled and the enrolled date
+// defaults to now.
+func (rs *registryService) Enroll(ctx context.Context, request *api.EnrollRequest) (*api.EnrollResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+	if request.GetEnrollment() == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid enrollment: no enrollment details provided")
+	}
+
+	// set the defaults
+	enrollment := proto.Clone(request.GetEnrollment()).(*api.Enrollment)
+	if enrollment.GetStatus() == api.EnrollmentStatus_ENROLLMENT_STATUS_UNSPECIFIED {
+		enrollment.Status = api.EnrollmentStatus_ENROLLMENT_STATUS_ENROLLED
+	}
+	if enrollment.GetEnrolledDate() == nil {
+		enrollment.EnrolledDate = ptypes.TimestampNow()
+	}
+
+	// add the enrollment to the registry db
+	if err := rs.db.Enroll(enrollment); err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.EnrollResponse{
+		ApiVersion: rs.version,
+		Enrollment: enrollment,
+	}, nil
+}
+
+// UpdateEnrollment will update the enrollment
+// of a participant in a study.
+func (rs *registryService) UpdateEnrollment(ctx context.Context, request *api.UpdateEnrollmentRequest) (*api.UpdateEnrollmentResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// replace the enrollment in the registry db
+	enrollment, err := rs.db.UpdateEnrollment(request.GetEnrollment())
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.UpdateEnrollmentResponse{
+		ApiVersion: rs.version,
+		Enrollment: enrollment,
+	}, nil
+}
+
+// ListEnrollments will list the enrollments for
+// a study or participant.
+func (rs *registryService) ListEnrollments(ctx context.Context, request *api.ListEnrollmentsRequest) (*api.ListEnrollmentsResponse, error) {
+
+	// check we have received a supported API request
+	if err := rs.checkAPI(request.GetApiVersion()); err != nil {
+		return nil, err
+	}
+
+	// collect the enrollments
+	enrollments, err := rs.db.ListEnrollments(request.GetStudyId(), request.GetParticipantId())
+	if err != nil {
+		return nil, err
+	}
+
+	// create a response and return
+	return &api.ListEnrollmentsResponse{
+		ApiVersion:  rs.version,
+		Enrollments: enrollments,
+	}, nil
+}
+
+// studyParticipants returns the ids of the participants
+// enrolled in the study, or nil if no study is given.
+func (rs *registryService) studyParticipants(studyID string) (map[string]bool, error) {
+	if studyID == "" {
+		return nil, nil
+	}
+	enrollments, err := rs.db.ListEnrollments(studyID, "")
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(enrollments))
+	for _, enrollment := range enrollments {
+		ids[enrollment.GetParticipantId()] = true
+	}
+	return ids, nil
+}
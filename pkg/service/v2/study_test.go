@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/store"
+)
+
+// TestStudy will check the study rpcs and that
+// participants can be listed and searched by study.
+func TestStudy(t *testing.T) {
+	ctx := context.Background()
+	rs := NewRegistryService(store.New(), normaliser, Options{})
+	p := newParticipant()
+	other := newParticipant()
+	other.Id = "ABC-123"
+	for _, participant := range []*api.Participant{p, other} {
+		_, err := rs.Create(ctx, &api.CreateRequest{ApiVersion: apiVersion, Participant: participant})
+		assert.NilError(t, err)
+	}
+	study := &api.Study{Id: "STUDY-1", Name: "Moon dust", Arms: []string{"control", "treatment"}}
+	_, err := rs.CreateStudy(ctx, &api.CreateStudyRequest{ApiVersion: apiVersion, Study: study})
+	assert.NilError(t, err)
+	_, err = rs.CreateStudy(ctx, &api.CreateStudyRequest{ApiVersion: apiVersion, Study: study})
+	assert.Equal(t, status.Code(err), codes.AlreadyExists)
+
+	// enroll a participant
+	_, err = rs.Enroll(ctx, &api.EnrollRequest{ApiVersion: apiVersion})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+	_, err = rs.Enroll(ctx, &api.EnrollRequest{ApiVersion: apiVersion, Enrollment: &api.Enrollment{ParticipantId: p.GetId(), StudyId: study.GetId(), Arm: "placebo"}})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+	enrolled, err := rs.Enroll(ctx, &api.EnrollRequest{ApiVersion: apiVersion, Enrollment: &api.Enrollment{ParticipantId: p.GetId(), StudyId: study.GetId(), Arm: "control"}})
+	assert.NilError(t, err)
+	assert.Equal(t, enrolled.GetEnrollment().GetStatus(), api.EnrollmentStatus_ENROLLMENT_STATUS_ENROLLED)
+	assert.Assert(t, enrolled.GetEnrollment().GetEnrolledDate() != nil)
+
+	// list and search by study
+	list, err := rs.List(ctx, &api.ListRequest{ApiVersion: apiVersion, StudyId: study.GetId()})
+	assert.NilError(t, err)
+	assert.Equal(t, len(list.GetParticipants()), 1)
+	assert.Equal(t, list.GetParticipants()[0].GetId(), p.GetId())
+	found, err := rs.Search(ctx, &api.SearchRequest{ApiVersion: apiVersion, Phone: "07700 900123", StudyId: study.GetId()})
+	assert.NilError(t, err)
+	assert.Equal(t, len(found.GetParticipants()), 1)
+	_, err = rs.List(ctx, &api.ListRequest{ApiVersion: apiVersion, StudyId: "STUDY-2"})
+	assert.Equal(t, status.Code(err), codes.NotFound)
+
+	// update the enrollment
+	updated, err := rs.UpdateEnrollment(ctx, &api.UpdateEnrollmentRequest{ApiVersion: apiVersion, Enrollment: &api.Enrollment{ParticipantId: p.GetId(), StudyId: study.GetId(), Arm: "control", Status: api.EnrollmentStatus_ENROLLMENT_STATUS_COMPLETED}})
+	assert.NilError(t, err)
+	assert.Equal(t, updated.GetEnrollment().GetEnrolledDate().AsTime(), enrolled.GetEnrollment().GetEnrolledDate().AsTime())
+
+	// arms and studies in use can not be removed
+	_, err = rs.UpdateStudy(ctx, &api.UpdateStudyRequest{ApiVersion: apiVersion, Study: &api.Study{Id: study.GetId(), Arms: []string{"treatment"}}})
+	assert.Equal(t, status.Code(err), codes.FailedPrecondition)
+	_, err = rs.DeleteStudy(ctx, &api.DeleteStudyRequest{ApiVersion: apiVersion, Id: study.GetId()})
+	assert.Equal(t, status.Code(err), codes.FailedPrecondition)
+
+	// deleting the participant removes the enrollment
+	_, err = rs.Delete(ctx, &api.DeleteRequest{ApiVersion: apiVersion, Id: p.GetId()})
+	assert.NilError(t, err)
+	enrollments, err := rs.ListEnrollments(ctx, &api.ListEnrollmentsRequest{ApiVersion: apiVersion, StudyId: study.GetId()})
+	assert.NilError(t, err)
+	assert.Equal(t, len(enrollments.GetEnrollments()), 0)
+	_, err = rs.DeleteStudy(ctx, &api.DeleteStudyRequest{ApiVersion: apiVersion, Id: study.GetId()})
+	assert.NilError(t, err)
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// maxAllocateAttempts is the number of reference numbers
+// tried before allocation fails.
+const maxAllocateAttempts = 100
+
+// Allocate will add a new participant to the store using the
+// first reference number returned by next which has never
+// been used, i.e. it is not held by a participant, is not an
+// alias and has not been retired by a delete.
+func (s *Store) Allocate(participant *api.Participant, next func() string) (*Record, error) {
+
+	// lock the db for RW access
+	s.Lock()
+	defer s.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	// find an unused reference number
+	for i := 0; i < maxAllocateAttempts; i++ {
+		id := next()
+		if s.used(id) {
+			continue
+		}
+		allocated := proto.Clone(participant).(*api.Participant)
+		allocated.Id = id
+		return s.create(allocated)
+	}
+	return nil, status.Errorf(codes.ResourceExhausted,
+		"could not allocate reference number: no unused reference number found after %d attempts", maxAllocateAttempts)
+}
+
+// used returns true if the reference number has been
+// used, the caller must hold the db lock.
+func (s *Store) used(id string) bool {
+	if _, ok := s.db[id]; ok {
+		return true
+	}
+	if _, ok := s.aliases[id]; ok {
+		return true
+	}
+	_, ok := s.retired[id]
+	return ok
+}
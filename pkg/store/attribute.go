@@ -0,0 +1,96 @@
+package store
+
+import (
+	"sort"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/attribute"
+)
+
+// DefineAttribute will add or replace a custom attribute
+// definition. An attribute can only be redefined if the
+// values held by participants are valid for the new
+// definition.
+func (s *Store) DefineAttribute(definition *api.AttributeDefinition) error {
+	if err := attribute.CheckDefinition(definition); err != nil {
+		return err
+	}
+
+	// lock the db for RW access
+	s.Lock()
+	defer s.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+
+	// check the values held by participants
+	for id, entry := range s.db {
+		value, ok := entry.Participant.GetAttributes()[definition.GetName()]
+		if !ok {
+			continue
+		}
+		if _, err := attribute.Value(definition, value); err != nil {
+			return status.Errorf(codes.FailedPrecondition,
+				"attribute in use: participant %v has a value for %v which is not valid for the new definition (%v)", id, definition.GetName(), status.Convert(err).Message())
+		}
+	}
+
+	// add the definition to the schema
+	s.attributes[definition.GetName()] = proto.Clone(definition).(*api.AttributeDefinition)
+	return nil
+}
+
+// checkAttributes will validate the attributes of the
+// participant against the schema, returning a copy of
+// the participant with the attributes in canonical form,
+// the caller must hold the db lock. Checking under the
+// lock means a value can not be stored which is invalid
+// for a definition being added at the same time.
+func (s *Store) checkAttributes(participant *api.Participant) (*api.Participant, error) {
+	attributes, err := s.attributes.Attributes(participant.GetAttributes())
+	if err != nil {
+		return nil, err
+	}
+	checked := proto.Clone(participant).(*api.Participant)
+	checked.Attributes = attributes
+	return checked, nil
+}
+
+// Attributes will return the custom attribute schema.
+func (s *Store) Attributes() (attribute.Schema, error) {
+
+	// lock the db for read access
+	s.RLock()
+	defer s.RUnlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	// copy the schema so it can be used without the lock
+	schema := make(attribute.Schema, len(s.attributes))
+	for name, definition := range s.attributes {
+		schema[name] = definition
+	}
+	return schema, nil
+}
+
+// ListAttributes will return the custom attribute
+// definitions, ordered by name.
+func (s *Store) ListAttributes() ([]*api.AttributeDefinition, error) {
+	schema, err := s.Attributes()
+	if err != nil {
+		return nil, err
+	}
+	definitions := make([]*api.AttributeDefinition, 0, len(schema))
+	for _, definition := range schema {
+		definitions = append(definitions, definition)
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].GetName() < definitions[j].GetName()
+	})
+	return definitions, nil
+}
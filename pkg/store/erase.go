@@ -0,0 +1,210 @@
+package store
+
+import (
+	"sort"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/erasure"
+)
+
+// Erase will remove every record of a participant from the
+// store: the participant entry, the aliases of participants
+// merged into it, its enrollments and external identifiers,
+// and its mutation history, which is scrubbed of reference
+// numbers. Participants which have
+// been deleted can also be erased, as their mutation history
+// and outbox entries are kept, and an alias erases the
+// participant it was merged into. The receipt returned by
+// receipt, which is given the number of records erased, is
+// kept by the store. The db is locked whilst receipt runs.
+func (s *Store) Erase(id string, receipt func(counts erasure.Counts) (*api.ErasureReceipt, error)) (*api.ErasureReceipt, error) {
+
+	// lock the db for RW access
+	s.Lock()
+	defer s.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	// check if any records exist for provided reference number
+	id = s.survivor(id)
+	entry, ok := s.db[id]
+	erased := s.erasedIDs(id)
+	if !ok && !s.hasRecords(erased) {
+		return nil, status.Errorf(codes.NotFound,
+			"reference number not found: no records of a participant exist in the registry for %v", id)
+	}
+
+	// count the records to erase
+	counts := erasure.Counts{
+		Aliases: uint32(len(erased) - 1),
+	}
+	if entry != nil {
+		counts.Identifiers = uint32(len(entry.Participant.GetIdentifiers()))
+	}
+	for _, enrolled := range s.enrollments {
+		if _, ok := enrolled[id]; ok {
+			counts.Enrollments++
+		}
+	}
+	for _, mutation := range s.history {
+		if erased[mutation.GetParticipantId()] || erased[mutation.GetMergedId()] {
+			counts.History++
+		}
+	}
+	r, err := receipt(counts)
+	if err != nil {
+		return nil, err
+	}
+
+	// remove the entry, identifiers, enrollments, aliases
+	// and retired reference numbers, so none are kept
+	if entry != nil {
+		delete(s.db, id)
+		s.indexIdentifiers(entry.Participant, nil)
+	}
+	for _, enrolled := range s.enrollments {
+		delete(enrolled, id)
+	}
+	for alias := range erased {
+		delete(s.aliases, alias)
+		delete(s.retired, alias)
+	}
+
+	// scrub the history, replacing the mutations
+	// as they may be held by callers
+	for i, mutation := range s.history {
+		if !erased[mutation.GetParticipantId()] && !erased[mutation.GetMergedId()] {
+			continue
+		}
+		scrubbed := proto.Clone(mutation).(*api.Mutation)
+		if erased[scrubbed.GetParticipantId()] {
+			scrubbed.ParticipantId = ""
+		}
+		if erased[scrubbed.GetMergedId()] {
+			scrubbed.MergedId = ""
+		}
+		s.history[i] = scrubbed
+	}
+
+	// remove the outbox entries, as they
+	// hold the participant details
+	if err := s.eraseOutbox(func(entry *OutboxEntry) bool {
+		return erased[entry.Mutation.GetParticipantId()] || erased[entry.Mutation.GetMergedId()]
+	}); err != nil {
+		return nil, err
+	}
+
+	// keep the receipt and notify the observers
+	s.receipts[r.GetReceiptId()] = r
+	ids := make([]string, 0, len(erased))
+	for erasedID := range erased {
+		ids = append(ids, erasedID)
+	}
+	sort.Strings(ids)
+	for _, observer := range s.observers {
+		observer.Erased(ids)
+	}
+	return r, nil
+}
+
+// survivor returns the reference number of the participant
+// that a reference number was merged into, or the reference
+// number if it was not merged, the caller must hold the db
+// lock. The history is used once the survivor has been
+// deleted, as its aliases are removed.
+func (s *Store) survivor(id string) string {
+	if survivor, ok := s.aliases[id]; ok {
+		return survivor
+	}
+	for seen := map[string]bool{id: true}; ; {
+		if _, ok := s.db[id]; ok {
+			return id
+		}
+		merged := false
+		for i := len(s.history) - 1; i >= 0; i-- {
+			mutation := s.history[i]
+			if mutation.GetType() == api.MutationType_MUTATION_TYPE_MERGED && mutation.GetMergedId() == id && !seen[mutation.GetParticipantId()] {
+				id = mutation.GetParticipantId()
+				seen[id] = true
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			return id
+		}
+	}
+}
+
+// erasedIDs returns the reference number and the reference
+// numbers of the participants merged into it, using the
+// aliases and, as the aliases of deleted participants are
+// removed, the merges in the history, the caller must hold
+// the db lock. Reference numbers which have been reused
+// by a participant in the store are not included.
+func (s *Store) erasedIDs(id string) map[string]bool {
+	erased := map[string]bool{id: true}
+	for alias, survivor := range s.aliases {
+		if survivor == id {
+			erased[alias] = true
+		}
+	}
+	for found := true; found; {
+		found = false
+		for _, mutation := range s.history {
+			merged := mutation.GetMergedId()
+			if mutation.GetType() != api.MutationType_MUTATION_TYPE_MERGED || !erased[mutation.GetParticipantId()] || erased[merged] {
+				continue
+			}
+			if _, ok := s.db[merged]; ok {
+				continue
+			}
+			erased[merged] = true
+			found = true
+		}
+	}
+	return erased
+}
+
+// hasRecords returns true if the history or outbox hold
+// records of the participants, the caller must hold the
+// db lock.
+func (s *Store) hasRecords(ids map[string]bool) bool {
+	for _, mutation := range s.history {
+		if ids[mutation.GetParticipantId()] || ids[mutation.GetMergedId()] {
+			return true
+		}
+	}
+	if s.outbox != nil {
+		for _, entry := range s.outbox.entries {
+			if ids[entry.Mutation.GetParticipantId()] || ids[entry.Mutation.GetMergedId()] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ErasureReceipt will get an erasure receipt from the store.
+func (s *Store) ErasureReceipt(receiptID string) (*api.ErasureReceipt, error) {
+
+	// lock the db for read access
+	s.RLock()
+	defer s.RUnlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	// check if the receipt exists
+	receipt, ok := s.receipts[receiptID]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound,
+			"receipt not found: no erasure receipt exists in the registry for %v", receiptID)
+	}
+	return receipt, nil
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// SetHistoryLimit will set the number of mutations kept
+// in the history, the oldest mutations being dropped once
+// there are more. A limit of 0 means the history is kept
+// indefinitely.
+func (s *Store) SetHistoryLimit(limit int) {
+	s.Lock()
+	defer s.Unlock()
+	s.historyLimit = limit
+	s.trimHistory()
+}
+
+// record will add a mutation of the participant to the
+// history and outbox and notify the observers, the caller must hold
+// the db lock. The sequence, time, participant details and
+// changed fields of the mutation are set by record. The
+// previous entry is the participant before the mutation,
+// which is nil for a create, and the entry is the participant
+// after the mutation, which is nil for a delete. The history
+// keeps the changed fields without the participant details,
+// which are only given to the outbox and observers. An error
+// is returned, and the store is closed, if the mutation could
+// not be added to the outbox, so that the change is not
+// acknowledged.
+func (s *Store) record(mutation *api.Mutation, previous, entry *Record) error {
+	s.sequence++
+	mutation.Sequence = s.sequence
+	mutation.Time, _ = ptypes.TimestampProto(time.Now())
+	var before, after *api.Participant
+	if previous != nil {
+		before = previous.Participant
+	}
+	if entry != nil {
+		after = entry.Participant
+	}
+	mutation.Participant = after
+	mutation.ChangedFields = changedFields(before, after)
+	if err := s.addOutbox(mutation); err != nil {
+		return err
+	}
+	s.history = append(s.history, &api.Mutation{
+		Sequence:      mutation.GetSequence(),
+		Time:          mutation.GetTime(),
+		Type:          mutation.GetType(),
+		ParticipantId: mutation.GetParticipantId(),
+		Revision:      mutation.GetRevision(),
+		MergedId:      mutation.GetMergedId(),
+		Reason:        mutation.GetReason(),
+		ChangedFields: mutation.GetChangedFields(),
+	})
+	s.trimHistory()
+	for _, observer := range s.observers {
+		observer.Mutated(mutation, before)
+	}
+	return nil
+}
+
+// trimHistory will drop the oldest mutations once the
+// history is over the limit, the caller must hold the
+// db lock. A tenth of the limit is dropped at a time, so
+// that the history is not copied for every mutation.
+func (s *Store) trimHistory() {
+	if s.historyLimit <= 0 || len(s.history) <= s.historyLimit {
+		return
+	}
+	drop := len(s.history) - s.historyLimit + s.historyLimit/10
+	s.history = append([]*api.Mutation{}, s.history[drop:]...)
+}
+
+// changedFields returns the names of the fields which
+// differ between the participants, either of which may
+// be nil, in the order they are declared.
+func changedFields(before, after *api.Participant) []string {
+	if after == nil {
+		return nil
+	}
+	fields := after.ProtoReflect().Descriptor().Fields()
+	changed := []string{}
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if !proto.Equal(onlyField(before, field), onlyField(after, field)) {
+			changed = append(changed, string(field.Name()))
+		}
+	}
+	return changed
+}
+
+// onlyField returns a participant holding only the
+// field of the participant, which may be nil.
+func onlyField(participant *api.Participant, field protoreflect.FieldDescriptor) proto.Message {
+	only := &api.Participant{}
+	if m := participant.ProtoReflect(); m.Has(field) {
+		only.ProtoReflect().Set(field, m.Get(field))
+	}
+	return only
+}
+
+// History will return the mutations of a participant,
+// including it being merged into another participant,
+// oldest first. The participant does not need to be
+// in the store, so the history of deleted and merged
+// participants is available.
+func (s *Store) History(id string) ([]*api.Mutation, error) {
+
+	// lock the db for read access
+	s.RLock()
+	defer s.RUnlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	// collect the mutations of the participant
+	mutations := []*api.Mutation{}
+	for _, mutation := range s.history {
+		if mutation.GetParticipantId() == id || mutation.GetMergedId() == id {
+			mutations = append(mutations, mutation)
+		}
+	}
+	if len(mutations) == 0 {
+		return nil, status.Errorf(codes.NotFound,
+			"reference number not found: no participant history exists in the registry for %v", id)
+	}
+	return mutations, nil
+}
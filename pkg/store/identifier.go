@@ -0,0 +1,73 @@
+package store
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// identifierKey indexes an external identifier.
+type identifierKey struct {
+	system, value string
+}
+
+// keyOf returns the index key of an external identifier.
+func keyOf(identifier *api.ExternalIdentifier) identifierKey {
+	return identifierKey{identifier.GetSystem(), identifier.GetValue()}
+}
+
+// checkIdentifiers checks that the external identifiers of the
+// participant are not held by another participant, other than
+// the participants being ignored. The caller must hold the db
+// lock.
+func (s *Store) checkIdentifiers(participant *api.Participant, ignore ...string) error {
+	for _, identifier := range participant.GetIdentifiers() {
+		owner, ok := s.identifiers[keyOf(identifier)]
+		if !ok || owner == participant.GetId() {
+			continue
+		}
+		ignored := false
+		for _, id := range ignore {
+			ignored = ignored || owner == id
+		}
+		if !ignored {
+			return status.Errorf(codes.AlreadyExists,
+				"external identifier in use: %v identifier %v is held by participant %v", identifier.GetSystem(), identifier.GetValue(), owner)
+		}
+	}
+	return nil
+}
+
+// indexIdentifiers will replace the external identifiers of
+// the previous participant in the index with those of the
+// participant, either of which can be nil. The caller must
+// hold the db lock.
+func (s *Store) indexIdentifiers(previous, participant *api.Participant) {
+	for _, identifier := range previous.GetIdentifiers() {
+		delete(s.identifiers, keyOf(identifier))
+	}
+	for _, identifier := range participant.GetIdentifiers() {
+		s.identifiers[keyOf(identifier)] = participant.GetId()
+	}
+}
+
+// GetByIdentifier will get the participant holding
+// a (normalised) external identifier from the store.
+func (s *Store) GetByIdentifier(identifier *api.ExternalIdentifier) (*Record, error) {
+
+	// lock the db for read access
+	s.RLock()
+	defer s.RUnlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	// check if an entry holds the provided identifier
+	id, ok := s.identifiers[keyOf(identifier)]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound,
+			"external identifier not found: no participant entry exists in the registry for %v identifier %v", identifier.GetSystem(), identifier.GetValue())
+	}
+	return s.db[id], nil
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// Merge will replace the surviving participant with the
+// participant returned by merge, which is given the survivor
+// and the duplicate and must not modify them. The duplicate
+// is removed from the store and its reference number becomes
+// an alias of the survivor, and its enrollments are moved to
+// the survivor unless the survivor is already enrolled in the
+// same study. The db is locked whilst merge runs.
+func (s *Store) Merge(id, duplicateID string, merge func(survivor, duplicate *api.Participant) (*api.Participant, error)) (*Record, error) {
+
+	// lock the db for RW access
+	s.Lock()
+	defer s.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	// check both participants exist
+	if id == duplicateID {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"invalid merge: participant %v can not be merged into itself", id)
+	}
+	entry, ok := s.db[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound,
+			"reference number not found: no participant entry exists in the registry for %v", id)
+	}
+	duplicate, ok := s.db[duplicateID]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound,
+			"reference number not found: no participant entry exists in the registry for %v", duplicateID)
+	}
+
+	// get the merged participant
+	participant, err := merge(entry.Participant, duplicate.Participant)
+	if err != nil {
+		return nil, err
+	}
+	if participant.GetId() != id {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"reference number can not be changed: participant %v was merged with reference number %v", id, participant.GetId())
+	}
+	if err := s.checkIdentifiers(participant, duplicateID); err != nil {
+		return nil, err
+	}
+
+	// replace the survivor and remove the duplicate
+	merged := &Record{
+		Participant: proto.Clone(participant).(*api.Participant),
+		Revision:    entry.Revision + 1,
+	}
+	s.db[id] = merged
+	delete(s.db, duplicateID)
+	s.indexIdentifiers(duplicate.Participant, nil)
+	s.indexIdentifiers(entry.Participant, merged.Participant)
+
+	// redirect the duplicate and its aliases to the survivor
+	s.aliases[duplicateID] = id
+	for alias, survivor := range s.aliases {
+		if survivor == duplicateID {
+			s.aliases[alias] = id
+		}
+	}
+
+	// move the enrollments of the duplicate to the survivor
+	for _, enrolled := range s.enrollments {
+		enrollment, ok := enrolled[duplicateID]
+		if !ok {
+			continue
+		}
+		delete(enrolled, duplicateID)
+		if _, ok := enrolled[id]; ok {
+			continue
+		}
+		moved := proto.Clone(enrollment).(*api.Enrollment)
+		moved.ParticipantId = id
+		enrolled[id] = moved
+	}
+	if err := s.record(&api.Mutation{Type: api.MutationType_MUTATION_TYPE_MERGED, ParticipantId: id, Revision: merged.Revision, MergedId: duplicateID}, entry, merged); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
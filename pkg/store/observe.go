@@ -0,0 +1,30 @@
+package store
+
+import (
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// Observer is notified of changes to the participants
+// in the store. Observers are called whilst the db is
+// locked, so they must not call the store or block,
+// and must not modify the participants or mutations.
+type Observer interface {
+
+	// Mutated is called once a mutation is added to
+	// the history, with the participant before the
+	// mutation, which is nil for a create
+	Mutated(mutation *api.Mutation, previous *api.Participant)
+
+	// Erased is called once participants are erased,
+	// with the reference numbers of the participant
+	// and the participants merged into it, so that
+	// any records the observer holds can be removed
+	Erased(ids []string)
+}
+
+// Observe will add an observer of the participant changes.
+func (s *Store) Observe(observer Observer) {
+	s.Lock()
+	defer s.Unlock()
+	s.observers = append(s.observers, observer)
+}
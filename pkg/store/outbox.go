@@ -0,0 +1,379 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// compactLines is the number of lines the outbox file
+// can have before it is compacted, once most of them
+// are for entries which have been removed.
+const compactLines = 1024
+
+// OutboxEntry is a mutation waiting to be published.
+type OutboxEntry struct {
+
+	// Key is the idempotency key of the entry, which
+	// is unique across restarts of the store
+	Key string
+
+	// Mutation to publish
+	Mutation *api.Mutation
+}
+
+// outbox holds the mutations waiting to be published.
+type outbox struct {
+
+	// instance is unique to each run of the
+	// server and prefixes the keys
+	instance string
+
+	// entries oldest first
+	entries []*OutboxEntry
+
+	// path of the outbox file, which is
+	// empty if the outbox is memory only
+	path string
+
+	// file is the outbox file open for appending
+	// and lines is the number of lines in it
+	file  *os.File
+	lines int
+}
+
+// outboxLine is a line of the outbox file, which
+// either adds an entry or removes the entry with
+// the key.
+type outboxLine struct {
+	Key      string          `json:"key"`
+	Mutation json.RawMessage `json:"mutation,omitempty"`
+	Removed  bool            `json:"removed,omitempty"`
+}
+
+// EnableOutbox will start adding mutations to the outbox.
+// Each mutation is added whilst the db is locked for the
+// participant write, so a change is in the outbox if, and
+// only if, it was made. Entries are held until they are
+// removed by AckOutbox.
+func (s *Store) EnableOutbox() {
+	s.Lock()
+	defer s.Unlock()
+	if s.outbox == nil {
+		s.outbox = newOutbox("")
+	}
+}
+
+// OpenOutbox will enable the outbox, keeping it in the
+// file at the path so that it survives a crash. Each
+// mutation is appended to the file, which is synced,
+// before the participant write is acknowledged, and
+// the entries in the file which were not removed by
+// AckOutbox before the server stopped are added to
+// the outbox to be published again.
+func (s *Store) OpenOutbox(path string) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.outbox != nil {
+		return fmt.Errorf("outbox is already enabled")
+	}
+	o := newOutbox(path)
+	if err := o.load(); err != nil {
+		return fmt.Errorf("could not open outbox file: %w", err)
+	}
+	s.outbox = o
+	return nil
+}
+
+// CloseOutbox will close the outbox file, once the
+// outbox has been published during shut down.
+func (s *Store) CloseOutbox() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.outbox == nil || s.outbox.file == nil {
+		return nil
+	}
+	err := s.outbox.file.Close()
+	s.outbox.file = nil
+	return err
+}
+
+// newOutbox creates an outbox, which is held
+// in the file at the path if it is not empty.
+func newOutbox(path string) *outbox {
+	return &outbox{
+		instance: strconv.FormatInt(time.Now().UnixNano(), 36),
+		path:     path,
+	}
+}
+
+// addOutbox will add a mutation to the outbox if it is
+// enabled, the caller must hold the db lock. If the
+// outbox file can not be written, the store is closed
+// as the change can no longer be published.
+func (s *Store) addOutbox(mutation *api.Mutation) error {
+	if s.outbox == nil {
+		return nil
+	}
+	entry := &OutboxEntry{
+		Key:      fmt.Sprintf("%v-%d", s.outbox.instance, mutation.GetSequence()),
+		Mutation: mutation,
+	}
+	if err := s.outbox.append(entry); err != nil {
+		s.closed = true
+		return status.Errorf(codes.Unavailable,
+			"registry service has stopped: the change could not be added to the outbox: %v", err)
+	}
+	s.outbox.entries = append(s.outbox.entries, entry)
+	return nil
+}
+
+// Outbox will return up to limit entries from the
+// outbox, oldest first. All entries are returned
+// if the limit is 0. The outbox can still be read
+// once the store is closed, so that it can be
+// published during shut down.
+func (s *Store) Outbox(limit int) ([]*OutboxEntry, error) {
+
+	// lock the db for read access
+	s.RLock()
+	defer s.RUnlock()
+	if s.outbox == nil {
+		return []*OutboxEntry{}, nil
+	}
+	entries := s.outbox.entries
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return append([]*OutboxEntry{}, entries...), nil
+}
+
+// AckOutbox will remove the published entries from the
+// outbox, including once the store is closed. Entries
+// whose removal can not be written to the outbox file
+// are published again when the outbox is next opened.
+func (s *Store) AckOutbox(keys ...string) error {
+
+	// lock the db for RW access
+	s.Lock()
+	defer s.Unlock()
+	if s.outbox == nil {
+		return nil
+	}
+	acked := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		acked[key] = true
+	}
+	removed := s.outbox.remove(func(entry *OutboxEntry) bool {
+		return acked[entry.Key]
+	})
+	if s.outbox.path == "" || len(removed) == 0 {
+		return nil
+	}
+	if s.outbox.lines > compactLines && s.outbox.lines > 2*len(s.outbox.entries) {
+		return s.outbox.compact()
+	}
+	return s.outbox.appendRemoved(removed)
+}
+
+// eraseOutbox will remove the entries matching the
+// function from the outbox, and rewrite the outbox
+// file so that it no longer holds them, the caller
+// must hold the db lock.
+func (s *Store) eraseOutbox(match func(entry *OutboxEntry) bool) error {
+	if s.outbox == nil {
+		return nil
+	}
+	if len(s.outbox.remove(match)) == 0 {
+		return nil
+	}
+	if err := s.outbox.compact(); err != nil {
+		s.closed = true
+		return status.Errorf(codes.Unavailable,
+			"registry service has stopped: erased changes could not be removed from the outbox file: %v", err)
+	}
+	return nil
+}
+
+// remove will remove the entries matching the
+// function, returning the removed entries.
+func (o *outbox) remove(match func(entry *OutboxEntry) bool) []*OutboxEntry {
+	removed := []*OutboxEntry{}
+	entries := o.entries[:0]
+	for _, entry := range o.entries {
+		if match(entry) {
+			removed = append(removed, entry)
+		} else {
+			entries = append(entries, entry)
+		}
+	}
+	for i := len(entries); i < len(o.entries); i++ {
+		o.entries[i] = nil
+	}
+	o.entries = entries
+	return removed
+}
+
+// load will read the entries from the outbox file,
+// which is then compacted and opened for appending.
+// A partly written last line, from a crash whilst
+// it was being appended, is ignored.
+func (o *outbox) load() error {
+	data, err := ioutil.ReadFile(o.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	index := make(map[string]int)
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		l := &outboxLine{}
+		if err := json.Unmarshal(line, l); err != nil {
+			if i == len(lines)-1 {
+				break
+			}
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if l.Removed {
+			if j, ok := index[l.Key]; ok {
+				o.entries[j] = nil
+			}
+			continue
+		}
+		mutation := &api.Mutation{}
+		if err := protojson.Unmarshal(l.Mutation, mutation); err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+		index[l.Key] = len(o.entries)
+		o.entries = append(o.entries, &OutboxEntry{
+			Key:      l.Key,
+			Mutation: mutation,
+		})
+	}
+	o.remove(func(entry *OutboxEntry) bool {
+		return entry == nil
+	})
+	return o.compact()
+}
+
+// compact will rewrite the outbox file so that it only
+// holds the entries in the outbox. The file is replaced
+// once the rewrite has been synced.
+func (o *outbox) compact() error {
+	if o.path == "" {
+		return nil
+	}
+	data := []byte{}
+	for _, entry := range o.entries {
+		line, err := marshalEntry(entry)
+		if err != nil {
+			return err
+		}
+		data = append(data, line...)
+	}
+	tmp := o.path + ".tmp"
+	if err := writeSynced(tmp, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, o.path); err != nil {
+		return err
+	}
+	if o.file != nil {
+		o.file.Close()
+	}
+	file, err := os.OpenFile(o.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		o.file = nil
+		return err
+	}
+	o.file = file
+	o.lines = len(o.entries)
+	return nil
+}
+
+// append will add the entry to the outbox
+// file, if there is one, and sync the file.
+func (o *outbox) append(entry *OutboxEntry) error {
+	if o.path == "" {
+		return nil
+	}
+	line, err := marshalEntry(entry)
+	if err != nil {
+		return err
+	}
+	return o.write(line, 1)
+}
+
+// appendRemoved will add the removal of the
+// entries to the outbox file and sync the file.
+func (o *outbox) appendRemoved(entries []*OutboxEntry) error {
+	data := []byte{}
+	for _, entry := range entries {
+		line, err := json.Marshal(&outboxLine{Key: entry.Key, Removed: true})
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	return o.write(data, len(entries))
+}
+
+// write will append the lines to the
+// outbox file and sync the file.
+func (o *outbox) write(data []byte, lines int) error {
+	if o.file == nil {
+		return fmt.Errorf("outbox file is closed")
+	}
+	if _, err := o.file.Write(data); err != nil {
+		return err
+	}
+	if err := o.file.Sync(); err != nil {
+		return err
+	}
+	o.lines += lines
+	return nil
+}
+
+// marshalEntry returns the outbox file line for an entry.
+func marshalEntry(entry *OutboxEntry) ([]byte, error) {
+	mutation, err := protojson.Marshal(entry.Mutation)
+	if err != nil {
+		return nil, err
+	}
+	line, err := json.Marshal(&outboxLine{Key: entry.Key, Mutation: mutation})
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// writeSynced will write the data to a new file
+// at the path, readable only by the server user
+// as it holds participant details, and sync it.
+func writeSynced(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
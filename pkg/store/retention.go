@@ -0,0 +1,114 @@
+package store
+
+import (
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/retention"
+)
+
+// SetRetention will set the retention policy used by
+// RetentionReport and EnforceRetention. A nil policy
+// means participant details are kept indefinitely.
+func (s *Store) SetRetention(policy *retention.Policy) {
+	s.Lock()
+	defer s.Unlock()
+	s.retention = policy
+}
+
+// RetentionReport will return the actions the retention
+// policy requires at the provided time, ordered by
+// participant reference number, without taking them.
+func (s *Store) RetentionReport(now time.Time) ([]*retention.Action, error) {
+
+	// lock the db for read access
+	s.RLock()
+	defer s.RUnlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	return s.retentionActions(now), nil
+}
+
+// EnforceRetention will take the actions the retention
+// policy requires at the provided time, returning the
+// actions taken. Fields are redacted from the participant,
+// as the mutation history only holds the names of the
+// changed fields. Each action is recorded in the history
+// with the rules as the reason.
+func (s *Store) EnforceRetention(now time.Time) ([]*retention.Action, error) {
+
+	// lock the db for RW access
+	s.Lock()
+	defer s.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	actions := s.retentionActions(now)
+	for _, action := range actions {
+		id := action.ParticipantID
+		entry := s.db[id]
+		if action.Delete {
+			if err := s.remove(id, entry, action.Reason()); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		redacted := &Record{
+			Participant: retention.Redact(entry.Participant, action.Fields),
+			Revision:    entry.Revision + 1,
+		}
+		s.db[id] = redacted
+		s.indexIdentifiers(entry.Participant, redacted.Participant)
+		if err := s.record(&api.Mutation{Type: api.MutationType_MUTATION_TYPE_REDACTED, ParticipantId: id, Revision: redacted.Revision, Reason: action.Reason()}, entry, redacted); err != nil {
+			return nil, err
+		}
+	}
+	return actions, nil
+}
+
+// retentionActions will evaluate the retention policy for
+// each participant, the caller must hold the db lock.
+func (s *Store) retentionActions(now time.Time) []*retention.Action {
+	actions := []*retention.Action{}
+	if s.retention == nil {
+		return actions
+	}
+	for id, entry := range s.db {
+		subject := retention.Subject{
+			Participant: entry.Participant,
+			StudyEnded:  s.studyEnded(id),
+		}
+		if action := s.retention.Evaluate(subject, now); action != nil {
+			actions = append(actions, action)
+		}
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].ParticipantID < actions[j].ParticipantID
+	})
+	return actions
+}
+
+// studyEnded returns the last end date of the studies the
+// participant is enrolled in, or zero if they are not
+// enrolled or a study has not ended, the caller must
+// hold the db lock.
+func (s *Store) studyEnded(id string) time.Time {
+	var ended time.Time
+	for studyID, enrolled := range s.enrollments {
+		if _, ok := enrolled[id]; !ok {
+			continue
+		}
+		end, err := ptypes.Timestamp(s.studies[studyID].GetEndDate())
+		if err != nil {
+			return time.Time{}
+		}
+		if end.After(ended) {
+			ended = end
+		}
+	}
+	return ended
+}
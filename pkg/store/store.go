@@ -0,0 +1,332 @@
+//Package store is the in-memory participant store which is
+//shared by each version of the registry service API.
+package store
+
+import (
+	"sort"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/attribute"
+	"github.com/will-rowe/registry-microservice/pkg/retention"
+)
+
+// Record is a participant entry in the store.
+// Records returned by the store must not be modified.
+type Record struct {
+
+	// Participant details, held using the
+	// latest version of the participant model
+	Participant *api.Participant
+
+	// Revision is incremented each time
+	// the participant is updated
+	Revision uint64
+}
+
+// Store holds the participants in the registry.
+// Errors returned by the store are gRPC status
+// errors so that they can be returned by the
+// service as is.
+type Store struct {
+
+	// db is the in-memory db to store participants
+	db map[string]*Record
+
+	// studies is the in-memory db to store studies
+	studies map[string]*api.Study
+
+	// enrollments holds the enrollments for
+	// each study, keyed by participant id
+	enrollments map[string]map[string]*api.Enrollment
+
+	// attributes is the schema for the
+	// custom participant attributes
+	attributes attribute.Schema
+
+	// aliases maps the reference numbers of merged
+	// participants to the participant they were
+	// merged into
+	aliases map[string]string
+
+	// identifiers maps the external identifiers
+	// to the participant holding them
+	identifiers map[identifierKey]string
+
+	// history is the log of participant mutations,
+	// holding at most historyLimit mutations if the
+	// limit is not 0, and sequence is the sequence
+	// of the last mutation
+	history      []*api.Mutation
+	historyLimit int
+	sequence     uint64
+
+	// retired holds the reference numbers of deleted
+	// participants and their aliases, which are
+	// never allocated
+	retired map[string]struct{}
+
+	// receipts holds the erasure receipts,
+	// keyed by receipt id
+	receipts map[string]*api.ErasureReceipt
+
+	// retention is the policy for destroying
+	// participant details, which may be nil
+	retention *retention.Policy
+
+	// observers are notified of participant changes
+	observers []Observer
+
+	// outbox holds the mutations waiting to be
+	// published, which is nil until enabled
+	outbox *outbox
+
+	// closed is true once the store has been closed
+	closed bool
+
+	// db lock
+	sync.RWMutex
+}
+
+// New creates an empty store.
+func New() *Store {
+	return &Store{
+		db:          make(map[string]*Record),
+		studies:     make(map[string]*api.Study),
+		enrollments: make(map[string]map[string]*api.Enrollment),
+		attributes:  make(attribute.Schema),
+		aliases:     make(map[string]string),
+		identifiers: make(map[identifierKey]string),
+		retired:     make(map[string]struct{}),
+		receipts:    make(map[string]*api.ErasureReceipt),
+	}
+}
+
+// checkOpen checks that the store has not been closed,
+// the caller must hold the db lock.
+func (s *Store) checkOpen() error {
+	if s.closed {
+		return status.Error(codes.Unavailable, "registry service is shutting down")
+	}
+	return nil
+}
+
+// Close will wait for any in-progress db operations
+// to finish and then stop the store from accepting
+// further requests.
+func (s *Store) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	s.closed = true
+	return nil
+}
+
+// Create will add a new participant to the store. The
+// external identifiers of the participant must not be
+// held by another participant, and the attributes must
+// be valid for the custom attribute schema.
+func (s *Store) Create(participant *api.Participant) (*Record, error) {
+
+	// lock the db for RW access
+	s.Lock()
+	defer s.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	return s.create(participant)
+}
+
+// create will add a new participant to the
+// store, the caller must hold the db lock.
+func (s *Store) create(participant *api.Participant) (*Record, error) {
+
+	// check if entry already exists for provided reference number
+	if _, ok := s.db[participant.GetId()]; ok {
+		return nil, status.Errorf(codes.AlreadyExists,
+			"reference number in use: participant already exists in the registry for %v", participant.GetId())
+	}
+	if survivor, ok := s.aliases[participant.GetId()]; ok {
+		return nil, status.Errorf(codes.AlreadyExists,
+			"reference number in use: %v is an alias of participant %v", participant.GetId(), survivor)
+	}
+	if err := s.checkIdentifiers(participant); err != nil {
+		return nil, err
+	}
+	checked, err := s.checkAttributes(participant)
+	if err != nil {
+		return nil, err
+	}
+
+	// add the participant as an entry in the registry db
+	entry := &Record{
+		Participant: checked,
+		Revision:    1,
+	}
+	s.db[participant.GetId()] = entry
+	s.indexIdentifiers(nil, entry.Participant)
+	if err := s.record(&api.Mutation{Type: api.MutationType_MUTATION_TYPE_CREATED, ParticipantId: participant.GetId(), Revision: entry.Revision}, nil, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Get will get a participant from the store. If the
+// reference number is an alias of a merged participant,
+// the participant it was merged into is returned.
+func (s *Store) Get(id string) (*Record, error) {
+
+	// lock the db for read access
+	s.RLock()
+	defer s.RUnlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	// check if entry exists for provided reference number
+	if survivor, ok := s.aliases[id]; ok {
+		id = survivor
+	}
+	entry, ok := s.db[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound,
+			"reference number not found: no participant entry exists in the registry for %v", id)
+	}
+	return entry, nil
+}
+
+// Update will replace a participant in the store. If the
+// expected revision is not 0, the participant is only
+// replaced if it is still at that revision.
+func (s *Store) Update(participant *api.Participant, expectedRevision uint64) (*Record, error) {
+	return s.UpdateFunc(participant.GetId(), expectedRevision, func(*api.Participant) (*api.Participant, error) {
+		return participant, nil
+	})
+}
+
+// UpdateFunc will replace a participant in the store with
+// the participant returned by update, which is given the
+// current participant and must not modify it. The db is
+// locked whilst update runs, so the participant can not
+// change between being read and being replaced. The
+// attributes of the updated participant are validated
+// against the custom attribute schema.
+func (s *Store) UpdateFunc(id string, expectedRevision uint64, update func(current *api.Participant) (*api.Participant, error)) (*Record, error) {
+
+	// lock the db for RW access
+	s.Lock()
+	defer s.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	// check if entry already exists for provided reference number
+	entry, ok := s.db[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound,
+			"reference number not found: no participant entry exists in the registry for %v", id)
+	}
+
+	// check the participant has not been modified since the client retrieved it
+	if expectedRevision != 0 && expectedRevision != entry.Revision {
+		return nil, status.Errorf(codes.Aborted,
+			"revision mismatch: participant %v is at revision %d but revision %d was expected", id, entry.Revision, expectedRevision)
+	}
+
+	// get the updated participant
+	participant, err := update(entry.Participant)
+	if err != nil {
+		return nil, err
+	}
+	if participant.GetId() != id {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"reference number can not be changed: participant %v was updated with reference number %v", id, participant.GetId())
+	}
+	if err := s.checkIdentifiers(participant); err != nil {
+		return nil, err
+	}
+	checked, err := s.checkAttributes(participant)
+	if err != nil {
+		return nil, err
+	}
+
+	// replace the participant entry in the registry db
+	updated := &Record{
+		Participant: checked,
+		Revision:    entry.Revision + 1,
+	}
+	s.db[id] = updated
+	s.indexIdentifiers(entry.Participant, updated.Participant)
+	if err := s.record(&api.Mutation{Type: api.MutationType_MUTATION_TYPE_UPDATED, ParticipantId: id, Revision: updated.Revision}, entry, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Delete will remove a participant from the store, along
+// with their enrollments, aliases and external identifiers.
+// The mutation history of the participant is kept, which
+// holds the changed fields but not the participant details.
+func (s *Store) Delete(id string) error {
+
+	// lock the db for RW access
+	s.Lock()
+	defer s.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+
+	// check if entry already exists for provided reference number
+	entry, ok := s.db[id]
+	if !ok {
+		return status.Errorf(codes.NotFound,
+			"reference number not found: no participant entry exists in the registry for %v", id)
+	}
+
+	return s.remove(id, entry, "")
+}
+
+// remove will delete the entry, enrollments, aliases and
+// external identifiers of a participant from the registry
+// db, recording the delete with the reason and retiring
+// the reference number and aliases, the caller must hold
+// the db lock.
+func (s *Store) remove(id string, entry *Record, reason string) error {
+	delete(s.db, id)
+	s.retired[id] = struct{}{}
+	s.indexIdentifiers(entry.Participant, nil)
+	for _, enrolled := range s.enrollments {
+		delete(enrolled, id)
+	}
+	for alias, survivor := range s.aliases {
+		if survivor == id {
+			delete(s.aliases, alias)
+			s.retired[alias] = struct{}{}
+		}
+	}
+	return s.record(&api.Mutation{Type: api.MutationType_MUTATION_TYPE_DELETED, ParticipantId: id, Revision: entry.Revision, Reason: reason}, entry, nil)
+}
+
+// List will return all participants in the
+// store, ordered by their reference number.
+func (s *Store) List() ([]*Record, error) {
+
+	// lock the db for read access
+	s.RLock()
+	defer s.RUnlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	// collect the participants in id order
+	records := make([]*Record, 0, len(s.db))
+	for _, entry := range s.db {
+		records = append(records, entry)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Participant.GetId() < records[j].Participant.GetId()
+	})
+	return records, nil
+}
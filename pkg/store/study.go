@@ -0,0 +1,248 @@
+package store
+
+import (
+	"sort"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// CreateStudy will add a new study to the store.
+func (s *Store) CreateStudy(study *api.Study) error {
+
+	// lock the db for RW access
+	s.Lock()
+	defer s.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+
+	// check if entry already exists for provided reference number
+	if _, ok := s.studies[study.GetId()]; ok {
+		return status.Errorf(codes.AlreadyExists,
+			"reference number in use: study already exists in the registry for %v", study.GetId())
+	}
+
+	// add the study to the registry db
+	s.studies[study.GetId()] = proto.Clone(study).(*api.Study)
+	s.enrollments[study.GetId()] = make(map[string]*api.Enrollment)
+	return nil
+}
+
+// GetStudy will get a study from the store.
+func (s *Store) GetStudy(id string) (*api.Study, error) {
+
+	// lock the db for read access
+	s.RLock()
+	defer s.RUnlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	return s.getStudy(id)
+}
+
+// getStudy will get a study, the
+// caller must hold the db lock.
+func (s *Store) getStudy(id string) (*api.Study, error) {
+	study, ok := s.studies[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound,
+			"reference number not found: no study exists in the registry for %v", id)
+	}
+	return study, nil
+}
+
+// UpdateStudy will replace a study in the store. Arms can
+// not be removed whilst participants are enrolled in them.
+func (s *Store) UpdateStudy(study *api.Study) error {
+
+	// lock the db for RW access
+	s.Lock()
+	defer s.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	if _, err := s.getStudy(study.GetId()); err != nil {
+		return err
+	}
+
+	// check the enrollments are still valid
+	for _, enrollment := range s.enrollments[study.GetId()] {
+		if err := checkArm(study, enrollment.GetArm()); err != nil {
+			return status.Errorf(codes.FailedPrecondition,
+				"study arm in use: participant %v is enrolled in arm %q of study %v", enrollment.GetParticipantId(), enrollment.GetArm(), study.GetId())
+		}
+	}
+
+	// replace the study in the registry db
+	s.studies[study.GetId()] = proto.Clone(study).(*api.Study)
+	return nil
+}
+
+// DeleteStudy will remove a study from the store. Studies
+// with enrolled participants can not be removed.
+func (s *Store) DeleteStudy(id string) error {
+
+	// lock the db for RW access
+	s.Lock()
+	defer s.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	if _, err := s.getStudy(id); err != nil {
+		return err
+	}
+	if n := len(s.enrollments[id]); n != 0 {
+		return status.Errorf(codes.FailedPrecondition,
+			"study in use: %d participants are enrolled in study %v", n, id)
+	}
+
+	// delete the study from the registry db
+	delete(s.studies, id)
+	delete(s.enrollments, id)
+	return nil
+}
+
+// ListStudies will return all studies in the
+// store, ordered by their reference number.
+func (s *Store) ListStudies() ([]*api.Study, error) {
+
+	// lock the db for read access
+	s.RLock()
+	defer s.RUnlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	// collect the studies in id order
+	studies := make([]*api.Study, 0, len(s.studies))
+	for _, study := range s.studies {
+		studies = append(studies, study)
+	}
+	sort.Slice(studies, func(i, j int) bool {
+		return studies[i].GetId() < studies[j].GetId()
+	})
+	return studies, nil
+}
+
+// Enroll will add the enrollment of a participant in
+// a study. The participant and study must exist, and
+// the arm must be one of the study arms.
+func (s *Store) Enroll(enrollment *api.Enrollment) error {
+
+	// lock the db for RW access
+	s.Lock()
+	defer s.Unlock()
+	if err := s.checkEnrollment(enrollment); err != nil {
+		return err
+	}
+	if _, ok := s.enrollments[enrollment.GetStudyId()][enrollment.GetParticipantId()]; ok {
+		return status.Errorf(codes.AlreadyExists,
+			"participant already enrolled: participant %v is already enrolled in study %v", enrollment.GetParticipantId(), enrollment.GetStudyId())
+	}
+
+	// add the enrollment to the registry db
+	s.enrollments[enrollment.GetStudyId()][enrollment.GetParticipantId()] = proto.Clone(enrollment).(*api.Enrollment)
+	return nil
+}
+
+// UpdateEnrollment will replace the enrollment of a
+// participant in a study, keeping the enrolled date
+// if one is not provided.
+func (s *Store) UpdateEnrollment(enrollment *api.Enrollment) (*api.Enrollment, error) {
+
+	// lock the db for RW access
+	s.Lock()
+	defer s.Unlock()
+	if err := s.checkEnrollment(enrollment); err != nil {
+		return nil, err
+	}
+	existing, ok := s.enrollments[enrollment.GetStudyId()][enrollment.GetParticipantId()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound,
+			"enrollment not found: participant %v is not enrolled in study %v", enrollment.GetParticipantId(), enrollment.GetStudyId())
+	}
+
+	// replace the enrollment in the registry db
+	updated := proto.Clone(enrollment).(*api.Enrollment)
+	if updated.GetEnrolledDate() == nil {
+		updated.EnrolledDate = existing.GetEnrolledDate()
+	}
+	s.enrollments[enrollment.GetStudyId()][enrollment.GetParticipantId()] = updated
+	return updated, nil
+}
+
+// ListEnrollments will return the enrollments for the
+// study and participant, either of which can be empty
+// to match all, ordered by study and then participant.
+func (s *Store) ListEnrollments(studyID, participantID string) ([]*api.Enrollment, error) {
+
+	// lock the db for read access
+	s.RLock()
+	defer s.RUnlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	if studyID != "" {
+		if _, err := s.getStudy(studyID); err != nil {
+			return nil, err
+		}
+	}
+
+	// collect the matching enrollments
+	enrollments := []*api.Enrollment{}
+	for id, enrolled := range s.enrollments {
+		if studyID != "" && id != studyID {
+			continue
+		}
+		for _, enrollment := range enrolled {
+			if participantID != "" && enrollment.GetParticipantId() != participantID {
+				continue
+			}
+			enrollments = append(enrollments, enrollment)
+		}
+	}
+	sort.Slice(enrollments, func(i, j int) bool {
+		if enrollments[i].GetStudyId() != enrollments[j].GetStudyId() {
+			return enrollments[i].GetStudyId() < enrollments[j].GetStudyId()
+		}
+		return enrollments[i].GetParticipantId() < enrollments[j].GetParticipantId()
+	})
+	return enrollments, nil
+}
+
+// checkEnrollment checks the participant and study
+// exist and the arm is valid, the caller must hold
+// the db lock.
+func (s *Store) checkEnrollment(enrollment *api.Enrollment) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	study, err := s.getStudy(enrollment.GetStudyId())
+	if err != nil {
+		return err
+	}
+	if _, ok := s.db[enrollment.GetParticipantId()]; !ok {
+		return status.Errorf(codes.NotFound,
+			"reference number not found: no participant entry exists in the registry for %v", enrollment.GetParticipantId())
+	}
+	return checkArm(study, enrollment.GetArm())
+}
+
+// checkArm checks the arm is one of the study arms,
+// any arm is allowed if the study has no arms.
+func checkArm(study *api.Study, arm string) error {
+	if len(study.GetArms()) == 0 {
+		return nil
+	}
+	for _, studyArm := range study.GetArms() {
+		if arm == studyArm {
+			return nil
+		}
+	}
+	return status.Errorf(codes.InvalidArgument,
+		"invalid study arm: study %v does not have an arm %q", study.GetId(), arm)
+}
@@ -0,0 +1,94 @@
+package translate
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	apiv1 "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	apiv2 "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/normalise"
+)
+
+// V1ToV2 will up-convert a v1 participant. The v1 phone
+// number becomes the only phone number and the v1 address
+// becomes the only address line.
+func V1ToV2(p *apiv1.Participant) *apiv2.Participant {
+	participant := &apiv2.Participant{
+		Id:  p.GetId(),
+		Dob: p.GetDob(),
+	}
+	if p.GetPhone() != "" {
+		participant.Phones = []*apiv2.PhoneNumber{{Number: p.GetPhone()}}
+	}
+	if p.GetAddress() != "" {
+		participant.Address = &apiv2.PostalAddress{Lines: []string{p.GetAddress()}}
+	}
+	return participant
+}
+
+// V2ToV1 will down-convert a v2 participant, using
+// the first phone number and the address joined into
+// a single line. Fields not in v1 are dropped.
+func V2ToV1(p *apiv2.Participant) *apiv1.Participant {
+	participant := &apiv1.Participant{
+		Id:      p.GetId(),
+		Dob:     p.GetDob(),
+		Address: normalise.Format(p.GetAddress()),
+	}
+	if len(p.GetPhones()) != 0 {
+		participant.Phone = p.GetPhones()[0].GetNumber()
+	}
+	return participant
+}
+
+// MergeV1 will apply a v1 participant on top of an
+// existing v2 participant, so that fields which can
+// not be represented in v1 are kept. The phone number
+// replaces the first phone number, keeping its use, and
+// the address only replaces the structured address if
+// it has been changed by more than case or punctuation.
+func MergeV1(existing *apiv2.Participant, p *apiv1.Participant) *apiv2.Participant {
+	merged := proto.Clone(existing).(*apiv2.Participant)
+	merged.Dob = p.GetDob()
+
+	// replace the first phone number
+	switch {
+	case p.GetPhone() == "" && len(merged.Phones) != 0:
+		merged.Phones = merged.Phones[1:]
+	case p.GetPhone() == "":
+	case len(merged.Phones) == 0:
+		merged.Phones = []*apiv2.PhoneNumber{{Number: p.GetPhone()}}
+	default:
+		merged.Phones[0].Number = p.GetPhone()
+	}
+
+	// replace the address if it has been changed, ignoring
+	// differences in case and punctuation
+	address := &apiv2.PostalAddress{Lines: []string{p.GetAddress()}}
+	if normalise.AddressKey(address) != normalise.AddressKey(existing.GetAddress()) {
+		merged.Address = nil
+		if p.GetAddress() != "" {
+			merged.Address = address
+		}
+	}
+	return merged
+}
+
+// Message will translate a request or response to the
+// equivalent message of another API version, which must
+// use the same field numbers, setting its API version.
+// Fields which are not in the other version are dropped.
+func Message(from, to proto.Message, version string) error {
+	data, err := proto.Marshal(from)
+	if err != nil {
+		return err
+	}
+	if err := (proto.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, to); err != nil {
+		return err
+	}
+	message := to.ProtoReflect()
+	if field := message.Descriptor().Fields().ByName("api_version"); field != nil {
+		message.Set(field, protoreflect.ValueOfString(version))
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package translate
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gotest.tools/assert"
+
+	apiv1 "github.com/will-rowe/registry-microservice/pkg/api/v1"
+	apiv2 "github.com/will-rowe/registry-microservice/pkg/api/v2"
+)
+
+// newParticipant is a helper function to
+// create a populated v2 Participant struct
+// for use in the tests.
+func newParticipant() *apiv2.Participant {
+	return &apiv2.Participant{
+		Id:        "KFG-734",
+		GivenName: "Ada",
+		Address: &apiv2.PostalAddress{
+			Lines:    []string{"1 Crater Road"},
+			Locality: "Tranquility Base",
+		},
+		Phones: []*apiv2.PhoneNumber{
+			{Number: "123", Use: apiv2.ContactUse_CONTACT_USE_MOBILE},
+			{Number: "456", Use: apiv2.ContactUse_CONTACT_USE_WORK},
+		},
+	}
+}
+
+// TestV2ToV1 will check that participants
+// are down-converted.
+func TestV2ToV1(t *testing.T) {
+	p := V2ToV1(newParticipant())
+	assert.Equal(t, p.GetPhone(), "123")
+	assert.Equal(t, p.GetAddress(), "1 Crater Road, Tranquility Base")
+	p = V2ToV1(&apiv2.Participant{Id: "KFG-734"})
+	assert.Equal(t, p.GetPhone(), "")
+	assert.Equal(t, p.GetAddress(), "")
+}
+
+// TestMergeV1 will check that fields which v1 can
+// not represent are kept when a v1 update is applied.
+func TestMergeV1(t *testing.T) {
+	existing := newParticipant()
+
+	// an unchanged v1 participant keeps everything
+	merged := MergeV1(existing, V2ToV1(existing))
+	assert.Equal(t, merged.GetGivenName(), "Ada")
+	assert.Equal(t, merged.GetAddress().GetLocality(), "Tranquility Base")
+	assert.Equal(t, len(merged.GetPhones()), 2)
+
+	// a changed phone number keeps its use and the other numbers
+	merged = MergeV1(existing, &apiv1.Participant{Id: "KFG-734", Phone: "789", Address: "1 Crater Road, Tranquility Base"})
+	assert.Equal(t, merged.GetPhones()[0].GetNumber(), "789")
+	assert.Equal(t, merged.GetPhones()[0].GetUse(), apiv2.ContactUse_CONTACT_USE_MOBILE)
+	assert.Equal(t, merged.GetPhones()[1].GetNumber(), "456")
+	assert.Equal(t, existing.GetPhones()[0].GetNumber(), "123")
+
+	// a changed address replaces the structured address
+	merged = MergeV1(existing, &apiv1.Participant{Id: "KFG-734", Phone: "123", Address: "The moon"})
+	assert.DeepEqual(t, merged.GetAddress().GetLines(), []string{"The moon"})
+	assert.Equal(t, merged.GetAddress().GetLocality(), "")
+}
+
+// TestCheckVersion will check that unsupported
+// API versions are rejected.
+func TestCheckVersion(t *testing.T) {
+	assert.NilError(t, CheckVersion("1", "1"))
+	for _, requested := range []string{"2", "3", ""} {
+		err := CheckVersion("1", requested)
+		assert.Equal(t, status.Code(err), codes.Unimplemented)
+	}
+	assert.Equal(t, IsSupported(LatestVersion), true)
+}
+
+// TestMessage will check that messages are translated
+// with their API version set, dropping the fields which
+// are not in the other version.
+func TestMessage(t *testing.T) {
+	response := &apiv2.ExportPseudonymisedResponse{
+		ApiVersion: "2",
+		Participants: []*apiv2.PseudonymisedParticipant{
+			{Pseudonym: "0f3c8a9e2b7d4c61", BirthYear: 1990, SexAtBirth: apiv2.SexAtBirth_SEX_AT_BIRTH_FEMALE},
+		},
+	}
+	translated := &apiv1.ExportPseudonymisedResponse{}
+	assert.NilError(t, Message(response, translated, "1"))
+	assert.Equal(t, translated.GetApiVersion(), "1")
+	assert.Equal(t, len(translated.GetParticipants()), 1)
+	assert.Equal(t, translated.GetParticipants()[0].GetPseudonym(), "0f3c8a9e2b7d4c61")
+	assert.Equal(t, translated.GetParticipants()[0].GetBirthYear(), int32(1990))
+	assert.Equal(t, len(translated.GetParticipants()[0].ProtoReflect().GetUnknown()), 0)
+
+	request := &apiv2.DeleteRequest{}
+	assert.NilError(t, Message(&apiv1.DeleteRequest{ApiVersion: "1", Id: "KFG-734"}, request, "2"))
+	assert.Equal(t, request.GetApiVersion(), "2")
+	assert.Equal(t, request.GetId(), "KFG-734")
+}
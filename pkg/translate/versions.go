@@ -0,0 +1,46 @@
+//Package translate converts participants and messages between
+//the registry service API versions. The store holds participants
+//using the latest model, and older API versions are translated to
+//and from it.
+package translate
+
+import (
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SupportedVersions are the API versions
+// served by the registry, oldest first.
+var SupportedVersions = []string{"1", "2"}
+
+// LatestVersion is the newest API version, which
+// is the model used to store participants.
+var LatestVersion = SupportedVersions[len(SupportedVersions)-1]
+
+// IsSupported returns true if the API
+// version is served by the registry.
+func IsSupported(version string) bool {
+	for _, supported := range SupportedVersions {
+		if version == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckVersion checks that the requested API version
+// matches the version implemented by a service. The
+// error tells the client which versions are supported.
+func CheckVersion(implemented, requested string) error {
+	if implemented == requested {
+		return nil
+	}
+	hint := "supported versions are " + strings.Join(SupportedVersions, ", ")
+	if IsSupported(requested) {
+		hint = "use the v" + requested + " service for version '" + requested + "' requests"
+	}
+	return status.Errorf(codes.Unimplemented,
+		"unsupported API version requested: current service implements version '%s', but version '%s' was requested (%s)", implemented, requested, hint)
+}
@@ -0,0 +1,159 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// queueExt is the file extension of a persisted delivery.
+const queueExt = ".json"
+
+// queue holds the deliveries waiting to be sent, which
+// are persisted as a file per delivery if a directory is
+// given. The files hold participant details, so are only
+// readable by the server user. Changes are made in memory
+// and then written separately, so that the files are not
+// written whilst the store is locked.
+type queue struct {
+	dir        string
+	deliveries map[string]*delivery
+
+	// changed holds the ids of the deliveries which have
+	// been added, updated or removed since the queue
+	// was last written
+	changed map[string]bool
+}
+
+// openQueue will create a queue, loading any
+// deliveries persisted in the directory.
+func openQueue(dir string) (*queue, error) {
+	q := &queue{
+		dir:        dir,
+		deliveries: make(map[string]*delivery),
+		changed:    make(map[string]bool),
+	}
+	if dir == "" {
+		return q, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create webhook queue: %w", err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read webhook queue: %w", err)
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), queueExt) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("could not read webhook queue: %w", err)
+		}
+		d := &delivery{}
+		if err := json.Unmarshal(data, d); err != nil {
+			return nil, fmt.Errorf("could not read webhook delivery %v: %w", file.Name(), err)
+		}
+		q.deliveries[d.ID] = d
+	}
+	return q, nil
+}
+
+// len returns the number of deliveries.
+func (q *queue) len() int {
+	return len(q.deliveries)
+}
+
+// has returns true if the delivery is in the queue.
+func (q *queue) has(id string) bool {
+	_, ok := q.deliveries[id]
+	return ok
+}
+
+// all returns the deliveries in the queue.
+func (q *queue) all() []*delivery {
+	deliveries := make([]*delivery, 0, len(q.deliveries))
+	for _, d := range q.deliveries {
+		deliveries = append(deliveries, d)
+	}
+	return deliveries
+}
+
+// put will add or replace a delivery.
+func (q *queue) put(d *delivery) {
+	q.deliveries[d.ID] = d
+	if q.dir != "" {
+		q.changed[d.ID] = true
+	}
+}
+
+// remove will remove a delivery.
+func (q *queue) remove(id string) {
+	delete(q.deliveries, id)
+	if q.dir != "" {
+		q.changed[id] = true
+	}
+}
+
+// changes returns the changes to write, which are the
+// encoded deliveries keyed by id, or nil for a removed
+// delivery, and marks them as written.
+func (q *queue) changes() map[string][]byte {
+	changes := make(map[string][]byte, len(q.changed))
+	for id := range q.changed {
+		delete(q.changed, id)
+		d, ok := q.deliveries[id]
+		if !ok {
+			changes[id] = nil
+			continue
+		}
+		data, err := json.Marshal(d)
+		if err != nil {
+			log.Printf("could not persist webhook delivery: %v", err)
+			continue
+		}
+		changes[id] = data
+	}
+	return changes
+}
+
+// write will write the changes to the queue directory,
+// returning the ids of the changes which could not be
+// written and the first error. It does not use the
+// deliveries, so is run without the dispatcher lock.
+func (q *queue) write(changes map[string][]byte) ([]string, error) {
+	var failed []string
+	var writeErr error
+	for id, data := range changes {
+		var err error
+		if data == nil {
+			if err = os.Remove(q.path(id)); os.IsNotExist(err) {
+				err = nil
+			}
+		} else {
+
+			// write then rename, so a partial file is never read
+			tmp := filepath.Join(q.dir, id+".tmp")
+			if err = ioutil.WriteFile(tmp, data, 0600); err == nil {
+				err = os.Rename(tmp, q.path(id))
+			}
+		}
+		if err != nil {
+			failed = append(failed, id)
+			if writeErr == nil {
+				writeErr = err
+			}
+		}
+	}
+	return failed, writeErr
+}
+
+// path returns the file for a delivery.
+func (q *queue) path(id string) string {
+	return filepath.Join(q.dir, id+queueExt)
+}
@@ -0,0 +1,667 @@
+//Package webhook sends HTTP callbacks to subscribers when
+//participants in the registry change. Deliveries are held
+//in a queue, which can be persisted to disk, and retried
+//with an exponential backoff until they are accepted. Each
+//subscription URL is sent its deliveries by its own worker,
+//so that a slow subscriber does not hold up the others.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/consent"
+)
+
+// event types which can be subscribed to
+const (
+	EventCreated   = "created"
+	EventUpdated   = "updated"
+	EventDeleted   = "deleted"
+	EventMerged    = "merged"
+	EventRedacted  = "redacted"
+	EventWithdrawn = "withdrawn"
+)
+
+// Events are the event types which can be subscribed to.
+var Events = []string{EventCreated, EventUpdated, EventDeleted, EventMerged, EventRedacted, EventWithdrawn}
+
+// mutationEvents maps the mutation types to their event type.
+var mutationEvents = map[api.MutationType]string{
+	api.MutationType_MUTATION_TYPE_CREATED:  EventCreated,
+	api.MutationType_MUTATION_TYPE_UPDATED:  EventUpdated,
+	api.MutationType_MUTATION_TYPE_DELETED:  EventDeleted,
+	api.MutationType_MUTATION_TYPE_MERGED:   EventMerged,
+	api.MutationType_MUTATION_TYPE_REDACTED: EventRedacted,
+}
+
+// headers sent with each delivery
+const (
+	HeaderEvent     = "X-Registry-Event"
+	HeaderDelivery  = "X-Registry-Delivery"
+	HeaderSignature = "X-Registry-Signature"
+	HeaderTimestamp = "X-Registry-Timestamp"
+)
+
+// DefaultTolerance is how far the timestamp of a delivery
+// can be from the time it is received, so that replayed
+// deliveries are rejected by Verify.
+const DefaultTolerance = 5 * time.Minute
+
+// default dispatcher options
+const (
+	DefaultBackoff     = time.Second
+	DefaultMaxBackoff  = time.Hour
+	DefaultMaxAttempts = 20
+	DefaultTimeout     = 10 * time.Second
+)
+
+// Subscription is a URL which is sent the events.
+type Subscription struct {
+
+	// URL to POST the events to
+	URL string `mapstructure:"url"`
+
+	// Events to send, all events are
+	// sent if no events are given
+	Events []string `mapstructure:"events"`
+
+	// Secret to sign the events with, the events
+	// are not signed if no secret is given. The
+	// secret is not persisted with the deliveries
+	// and they are signed when they are sent, so
+	// a changed secret is used after a restart
+	Secret string `mapstructure:"secret"`
+}
+
+// wants returns true if the subscription wants the event type.
+func (s Subscription) wants(event string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the JSON body of a delivery.
+type Event struct {
+
+	// ID of the delivery, which is also sent in the
+	// delivery header so that retries can be detected
+	ID string `json:"id"`
+
+	// Event type
+	Event string `json:"event"`
+
+	// Sequence of the mutation, which can be
+	// used to order the events as retries mean
+	// they may be delivered out of order
+	Sequence uint64 `json:"sequence"`
+
+	// Time of the mutation, as RFC3339
+	Time string `json:"time"`
+
+	// ParticipantID is the participant reference number
+	ParticipantID string `json:"participant_id"`
+
+	// Revision of the participant after the mutation
+	Revision uint64 `json:"revision"`
+
+	// MergedID is the reference number of
+	// the duplicate participant for a merge
+	MergedID string `json:"merged_id,omitempty"`
+
+	// Reason for changes made by the server
+	Reason string `json:"reason,omitempty"`
+
+	// Participant details after the mutation using the v2
+	// participant JSON, which are not set for a delete
+	Participant json.RawMessage `json:"participant,omitempty"`
+}
+
+// delivery is an event waiting to be sent to a subscription.
+type delivery struct {
+	ID             string          `json:"id"`
+	URL            string          `json:"url"`
+	Event          string          `json:"event"`
+	Sequence       uint64          `json:"sequence"`
+	ParticipantIDs []string        `json:"participant_ids"`
+	Body           json.RawMessage `json:"body"`
+	Attempts       int             `json:"attempts"`
+	NextAttempt    time.Time       `json:"next_attempt"`
+}
+
+// Dispatcher sends the participant changes to the
+// subscriptions. It is a store Observer, queueing the
+// deliveries in memory as the store changes, which are
+// then persisted and sent by Run.
+type Dispatcher struct {
+	subscriptions []Subscription
+	queue         *queue
+	client        *http.Client
+	backoff       time.Duration
+	maxBackoff    time.Duration
+	maxAttempts   int
+	wake          chan struct{}
+
+	// sending holds the subscription URLs which have
+	// a worker sending their due deliveries, which
+	// are waited for by Run
+	sending map[string]bool
+	workers sync.WaitGroup
+
+	// changed wakes the persisting of the queue, and
+	// writing is held whilst the queue is written
+	changed chan struct{}
+	writing sync.Mutex
+
+	sync.Mutex
+}
+
+// Option is used to configure the Dispatcher.
+type Option func(*Dispatcher)
+
+// WithBackoff sets the time to wait before the first retry
+// of a delivery, which doubles each retry up to the maximum.
+func WithBackoff(backoff, maxBackoff time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.backoff = backoff
+		d.maxBackoff = maxBackoff
+	}
+}
+
+// WithMaxAttempts sets how many times a delivery
+// is attempted before it is dropped.
+func WithMaxAttempts(attempts int) Option {
+	return func(d *Dispatcher) {
+		d.maxAttempts = attempts
+	}
+}
+
+// WithHTTPClient sets the client used to send deliveries.
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// New creates a Dispatcher for the subscriptions. The
+// deliveries are persisted in the queue directory, and
+// any deliveries already in the directory are resent. The
+// deliveries are only held in memory if the directory is
+// empty.
+func New(subscriptions []Subscription, dir string, opts ...Option) (*Dispatcher, error) {
+	secrets := make(map[string]string, len(subscriptions))
+	for i, subscription := range subscriptions {
+		if err := checkSubscription(subscription); err != nil {
+			return nil, fmt.Errorf("invalid webhook subscription %d (%v): %w", i+1, subscription.URL, err)
+		}
+		if secret, ok := secrets[subscription.URL]; ok && secret != subscription.Secret {
+			return nil, fmt.Errorf("invalid webhook subscription %d (%v): the secret differs from another subscription for the URL", i+1, subscription.URL)
+		}
+		secrets[subscription.URL] = subscription.Secret
+	}
+	q, err := openQueue(dir)
+	if err != nil {
+		return nil, err
+	}
+	d := &Dispatcher{
+		subscriptions: append([]Subscription{}, subscriptions...),
+		queue:         q,
+		client:        &http.Client{Timeout: DefaultTimeout},
+		backoff:       DefaultBackoff,
+		maxBackoff:    DefaultMaxBackoff,
+		maxAttempts:   DefaultMaxAttempts,
+		wake:          make(chan struct{}, 1),
+		sending:       make(map[string]bool),
+		changed:       make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d, nil
+}
+
+// checkSubscription returns an error if
+// the subscription is invalid.
+func checkSubscription(subscription Subscription) error {
+	u, err := url.Parse(subscription.URL)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("an http or https URL is required")
+	}
+	for _, event := range subscription.Events {
+		if eventOrder(event) == len(Events) {
+			return fmt.Errorf("unsupported event %q (%v)", event, strings.Join(Events, "|"))
+		}
+	}
+	return nil
+}
+
+// Pending returns the number of deliveries in the queue.
+func (d *Dispatcher) Pending() int {
+	d.Lock()
+	defer d.Unlock()
+	return d.queue.len()
+}
+
+// Mutated will queue the events for a mutation. A
+// withdrawn event is sent, as well as the update, when
+// an update withdraws all of a participant's consent.
+func (d *Dispatcher) Mutated(mutation *api.Mutation, previous *api.Participant) {
+	events := []string{MutationEvent(mutation.GetType())}
+	if mutation.GetType() == api.MutationType_MUTATION_TYPE_UPDATED && !consent.IsWithdrawn(previous) && consent.IsWithdrawn(mutation.GetParticipant()) {
+		events = append(events, EventWithdrawn)
+	}
+	ids := []string{mutation.GetParticipantId()}
+	if mutation.GetMergedId() != "" {
+		ids = append(ids, mutation.GetMergedId())
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	for _, event := range events {
+		for _, subscription := range d.subscriptions {
+			if !subscription.wants(event) {
+				continue
+			}
+			id, err := newID()
+			if err != nil {
+				log.Printf("could not create webhook event: %v", err)
+				continue
+			}
+			body, err := NewEvent(id, event, mutation)
+			if err != nil {
+				log.Printf("could not create webhook event: %v", err)
+				continue
+			}
+			delivery := &delivery{
+				ID:             body.ID,
+				URL:            subscription.URL,
+				Event:          event,
+				Sequence:       mutation.GetSequence(),
+				ParticipantIDs: ids,
+				NextAttempt:    time.Now(),
+			}
+			if delivery.Body, err = json.Marshal(body); err != nil {
+				log.Printf("could not create webhook event: %v", err)
+				continue
+			}
+			d.put(delivery)
+		}
+	}
+	d.notify()
+}
+
+// Erased will remove the queued deliveries for erased
+// participants, as they hold the participant details.
+func (d *Dispatcher) Erased(ids []string) {
+	erased := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		erased[id] = true
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	for _, delivery := range d.queue.all() {
+		for _, id := range delivery.ParticipantIDs {
+			if erased[id] {
+				d.remove(delivery)
+				break
+			}
+		}
+	}
+}
+
+// notify will wake Run if it is waiting.
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run will send the queued deliveries until the context
+// is cancelled. Failed deliveries are retried with an
+// exponential backoff and dropped once they reach the
+// maximum number of attempts. The queue is persisted
+// in the background whilst Run is running, and Run
+// waits for the delivery workers before returning.
+func (d *Dispatcher) Run(ctx context.Context) {
+	persisted := make(chan struct{})
+	go func() {
+		d.persistQueue(ctx)
+		close(persisted)
+	}()
+	defer func() { <-persisted }()
+	defer d.workers.Wait()
+	for {
+		d.deliver(ctx)
+		timer := time.NewTimer(d.wait())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-d.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// persistQueue will persist the queue each time it
+// changes, until the context is cancelled.
+func (d *Dispatcher) persistQueue(ctx context.Context) {
+	for {
+		if err := d.persist(); err != nil {
+			log.Printf("could not persist webhook deliveries: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.changed:
+		}
+	}
+}
+
+// persist will write the queue changes to the queue
+// directory. The files are written without holding the
+// lock, so that the store is not held up by the writes,
+// and changes which could not be written are kept to be
+// written by the next persist.
+func (d *Dispatcher) persist() error {
+	d.writing.Lock()
+	defer d.writing.Unlock()
+	d.Lock()
+	changes := d.queue.changes()
+	d.Unlock()
+	if len(changes) == 0 {
+		return nil
+	}
+	failed, err := d.queue.write(changes)
+	if len(failed) != 0 {
+		d.Lock()
+		for _, id := range failed {
+			d.queue.changed[id] = true
+		}
+		d.Unlock()
+	}
+	return err
+}
+
+// Close will persist the queue changes made since
+// Run returned, e.g. by requests which finished
+// during shut down.
+func (d *Dispatcher) Close() error {
+	return d.persist()
+}
+
+// wait returns the time until the next delivery is due,
+// ignoring the subscription URLs which have a worker as
+// the worker wakes Run once it is done.
+func (d *Dispatcher) wait() time.Duration {
+	d.Lock()
+	defer d.Unlock()
+	wait := d.maxBackoff
+	for _, delivery := range d.queue.all() {
+		if d.sending[delivery.URL] {
+			continue
+		}
+		if until := time.Until(delivery.NextAttempt); until < wait {
+			wait = until
+		}
+	}
+	return wait
+}
+
+// deliver will start a worker for each subscription URL
+// which has deliveries due and does not already have a
+// worker, so that a slow or unresponsive subscriber only
+// holds up its own deliveries.
+func (d *Dispatcher) deliver(ctx context.Context) {
+	d.Lock()
+	defer d.Unlock()
+	due := make(map[string][]*delivery)
+	now := time.Now()
+	for _, delivery := range d.queue.all() {
+		if !d.sending[delivery.URL] && !delivery.NextAttempt.After(now) {
+			due[delivery.URL] = append(due[delivery.URL], delivery)
+		}
+	}
+	for endpoint, deliveries := range due {
+		d.sending[endpoint] = true
+		d.workers.Add(1)
+		go func(endpoint string, deliveries []*delivery) {
+			defer d.workers.Done()
+			d.sendDue(ctx, deliveries)
+			d.Lock()
+			delete(d.sending, endpoint)
+			d.Unlock()
+			d.notify()
+		}(endpoint, deliveries)
+	}
+}
+
+// sendDue will send the due deliveries of a subscription
+// URL, in order of their mutation.
+func (d *Dispatcher) sendDue(ctx context.Context, due []*delivery) {
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].Sequence != due[j].Sequence {
+			return due[i].Sequence < due[j].Sequence
+		}
+		return eventOrder(due[i].Event) < eventOrder(due[j].Event)
+	})
+
+	for _, delivery := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		err := d.send(ctx, delivery)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// update the queue, unless the delivery
+		// was removed whilst it was being sent
+		d.Lock()
+		if d.queue.has(delivery.ID) {
+			d.update(delivery, err)
+		}
+		d.Unlock()
+	}
+}
+
+// update will remove a sent delivery from the queue, or
+// schedule the retry of a failed delivery, the caller
+// must hold the lock.
+func (d *Dispatcher) update(delivery *delivery, err error) {
+	if err == nil {
+		d.remove(delivery)
+		return
+	}
+	delivery.Attempts++
+	if delivery.Attempts >= d.maxAttempts {
+		log.Printf("dropping webhook delivery %v to %v after %d attempts: %v", delivery.ID, delivery.URL, delivery.Attempts, err)
+		d.remove(delivery)
+		return
+	}
+	backoff := d.backoff
+	for i := 1; i < delivery.Attempts && backoff < d.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > d.maxBackoff {
+		backoff = d.maxBackoff
+	}
+	delivery.NextAttempt = time.Now().Add(backoff)
+	d.put(delivery)
+}
+
+// put will add or replace a delivery in the queue,
+// the caller must hold the lock.
+func (d *Dispatcher) put(delivery *delivery) {
+	d.queue.put(delivery)
+	d.persistLater()
+}
+
+// remove will remove a delivery from the
+// queue, the caller must hold the lock.
+func (d *Dispatcher) remove(delivery *delivery) {
+	d.queue.remove(delivery.ID)
+	d.persistLater()
+}
+
+// persistLater will wake the persisting of the
+// queue, which happens outside of the lock.
+func (d *Dispatcher) persistLater() {
+	select {
+	case d.changed <- struct{}{}:
+	default:
+	}
+}
+
+// send will POST the delivery to the subscription,
+// which must respond with a 2xx status. The delivery
+// is signed with the secret of the subscription at the
+// time it is sent.
+func (d *Dispatcher) send(ctx context.Context, delivery *delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	timestamp := Timestamp(time.Now())
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if secret := d.secret(delivery.URL); secret != "" {
+		req.Header.Set(HeaderSignature, Sign(secret, timestamp, delivery.Body))
+	}
+	res, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("subscriber responded with %v", res.Status)
+	}
+	return nil
+}
+
+// secret returns the secret of the subscription for the
+// URL, which is empty if the deliveries are not signed.
+func (d *Dispatcher) secret(endpoint string) string {
+	for _, subscription := range d.subscriptions {
+		if subscription.URL == endpoint {
+			return subscription.Secret
+		}
+	}
+	return ""
+}
+
+// eventOrder returns the position of the event type in
+// Events, which orders the events of the same mutation.
+func eventOrder(event string) int {
+	for i, e := range Events {
+		if e == event {
+			return i
+		}
+	}
+	return len(Events)
+}
+
+// MutationEvent returns the event type of a mutation type.
+func MutationEvent(mutationType api.MutationType) string {
+	return mutationEvents[mutationType]
+}
+
+// newID returns a random delivery id.
+func newID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// NewEvent will create the event for a mutation,
+// with the provided delivery id.
+func NewEvent(id, event string, mutation *api.Mutation) (*Event, error) {
+	t, err := ptypes.Timestamp(mutation.GetTime())
+	if err != nil {
+		return nil, err
+	}
+	e := &Event{
+		ID:            id,
+		Event:         event,
+		Sequence:      mutation.GetSequence(),
+		Time:          t.UTC().Format(time.RFC3339),
+		ParticipantID: mutation.GetParticipantId(),
+		Revision:      mutation.GetRevision(),
+		MergedID:      mutation.GetMergedId(),
+		Reason:        mutation.GetReason(),
+	}
+	if mutation.GetParticipant() != nil {
+		if e.Participant, err = protojson.Marshal(mutation.GetParticipant()); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Timestamp returns the timestamp header value for
+// a delivery sent at the time, which is the number
+// of seconds since the Unix epoch.
+func Timestamp(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+// Sign returns the signature header value for the body
+// sent with the timestamp header value, which is the hex
+// HMAC-SHA256 of the timestamp, a ".", and the body using
+// the secret, prefixed with "sha256=". The timestamp is
+// signed so that a delivery can not be replayed later.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify returns true if the signature header value is
+// valid for the timestamp header value, body and secret,
+// and the timestamp is within the tolerance of now, so
+// that replayed deliveries are rejected.
+func Verify(secret string, body []byte, timestamp, signature string, tolerance time.Duration) bool {
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := time.Since(time.Unix(sent, 0)); age > tolerance || age < -tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
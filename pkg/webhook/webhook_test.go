@@ -0,0 +1,261 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"gotest.tools/assert"
+
+	api "github.com/will-rowe/registry-microservice/pkg/api/v2"
+	"github.com/will-rowe/registry-microservice/pkg/consent"
+	"github.com/will-rowe/registry-microservice/pkg/store"
+)
+
+// received is a request received by the test subscriber.
+type received struct {
+	path      string
+	header    http.Header
+	body      []byte
+	signature string
+}
+
+// newReceiver starts a subscriber which sends the requests
+// it receives on the channel, responding with the status
+// returned by respond.
+func newReceiver(t *testing.T, respond func(attempt int) int) (*httptest.Server, chan received) {
+	requests := make(chan received, 100)
+	attempt := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		attempt++
+		w.WriteHeader(respond(attempt))
+		requests <- received{path: r.URL.Path, header: r.Header, body: body, signature: r.Header.Get(HeaderSignature)}
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+// next returns the next request, failing the test if
+// one is not received in time.
+func next(t *testing.T, requests chan received) received {
+	select {
+	case r := <-requests:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not received")
+		return received{}
+	}
+}
+
+// drained waits for the dispatcher queue to
+// empty, failing the test if it does not.
+func drained(t *testing.T, d *Dispatcher) {
+	pending(t, d, 0)
+}
+
+// pending waits for the dispatcher queue to hold
+// n deliveries, failing the test if it does not.
+func pending(t *testing.T, d *Dispatcher, n int) {
+	for start := time.Now(); d.Pending() != n; time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("%d webhook deliveries pending, expected %d", d.Pending(), n)
+		}
+	}
+}
+
+// TestNew will check that invalid subscriptions are rejected.
+func TestNew(t *testing.T) {
+	_, err := New([]Subscription{{URL: "https://example.com/hook", Events: []string{EventCreated}}}, "")
+	assert.NilError(t, err)
+	for _, subscription := range []Subscription{
+		{URL: "example.com/hook"},
+		{URL: "ftp://example.com/hook"},
+		{URL: "https://example.com/hook", Events: []string{"enrolled"}},
+	} {
+		_, err := New([]Subscription{subscription}, "")
+		assert.Assert(t, err != nil, subscription.URL)
+	}
+
+	// subscriptions for the same URL must have the same secret
+	_, err = New([]Subscription{{URL: "https://example.com/hook", Secret: "s3cret"}, {URL: "https://example.com/hook", Events: []string{EventCreated}}}, "")
+	assert.Assert(t, err != nil)
+}
+
+// TestDispatcher will check that subscribers are sent
+// the events they subscribe to, signed with their secret.
+func TestDispatcher(t *testing.T) {
+	server, requests := newReceiver(t, func(int) int { return http.StatusOK })
+	d, err := New([]Subscription{
+		{URL: server.URL + "/all", Secret: "s3cret"},
+		{URL: server.URL + "/withdrawn", Events: []string{EventWithdrawn}},
+	}, "")
+	assert.NilError(t, err)
+	db := store.New()
+	db.Observe(d)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	// create the participant
+	_, err = db.Create(&api.Participant{Id: "KFG-734", GivenName: "Ada"})
+	assert.NilError(t, err)
+	r := next(t, requests)
+	assert.Equal(t, r.path, "/all")
+	assert.Equal(t, r.header.Get(HeaderEvent), EventCreated)
+	timestamp := r.header.Get(HeaderTimestamp)
+	assert.Assert(t, Verify("s3cret", r.body, timestamp, r.signature, DefaultTolerance))
+	assert.Assert(t, !Verify("secret", r.body, timestamp, r.signature, DefaultTolerance))
+
+	// the timestamp is signed, so a delivery can not be
+	// replayed once it is outside of the tolerance
+	old := Timestamp(time.Now().Add(-time.Hour))
+	assert.Assert(t, !Verify("s3cret", r.body, old, r.signature, DefaultTolerance))
+	assert.Assert(t, !Verify("s3cret", r.body, old, Sign("s3cret", old, r.body), DefaultTolerance))
+	assert.Assert(t, Verify("s3cret", r.body, old, Sign("s3cret", old, r.body), 2*time.Hour))
+	event := &Event{}
+	assert.NilError(t, json.Unmarshal(r.body, event))
+	assert.Equal(t, event.ID, r.header.Get(HeaderDelivery))
+	assert.Equal(t, event.Event, EventCreated)
+	assert.Equal(t, event.ParticipantID, "KFG-734")
+	assert.Equal(t, event.Revision, uint64(1))
+	assert.Equal(t, string(event.Participant), `{"id":"KFG-734","givenName":"Ada"}`)
+
+	// granting consent is an update, withdrawing it is
+	// also sent to the withdrawn subscription
+	now := time.Now()
+	for _, update := range []func(p *api.Participant) (*api.Participant, error){
+		func(p *api.Participant) (*api.Participant, error) {
+			p, _, err := consent.Grant(p, "registry", "1", now)
+			return p, err
+		},
+		func(p *api.Participant) (*api.Participant, error) {
+			p, _, err := consent.Withdraw(p, "", now)
+			return p, err
+		},
+	} {
+		_, err = db.UpdateFunc("KFG-734", 0, update)
+		assert.NilError(t, err)
+	}
+	paths := map[string][]string{}
+	for i := 0; i < 4; i++ {
+		r := next(t, requests)
+		paths[r.path] = append(paths[r.path], r.header.Get(HeaderEvent))
+		if r.path == "/withdrawn" {
+			assert.Equal(t, r.signature, "")
+		}
+	}
+	assert.DeepEqual(t, paths, map[string][]string{
+		"/all":       {EventUpdated, EventUpdated, EventWithdrawn},
+		"/withdrawn": {EventWithdrawn},
+	})
+	drained(t, d)
+}
+
+// TestRetry will check that failed deliveries are retried
+// with a backoff and dropped after the maximum attempts.
+func TestRetry(t *testing.T) {
+	server, requests := newReceiver(t, func(attempt int) int {
+		if attempt < 3 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusNoContent
+	})
+	d, err := New([]Subscription{{URL: server.URL}}, "", WithBackoff(20*time.Millisecond, 50*time.Millisecond))
+	assert.NilError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+	d.Mutated(&api.Mutation{Time: ptypes.TimestampNow(), Sequence: 1, Type: api.MutationType_MUTATION_TYPE_DELETED, ParticipantId: "KFG-734"}, nil)
+	start := time.Now()
+	ids := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		ids[next(t, requests).header.Get(HeaderDelivery)] = true
+	}
+	assert.Assert(t, time.Since(start) >= 60*time.Millisecond)
+	assert.Equal(t, len(ids), 1)
+	drained(t, d)
+
+	// deliveries are dropped after the maximum attempts
+	failing, requests := newReceiver(t, func(int) int { return http.StatusInternalServerError })
+	d, err = New([]Subscription{{URL: failing.URL}}, "", WithBackoff(time.Millisecond, time.Millisecond), WithMaxAttempts(2))
+	assert.NilError(t, err)
+	go d.Run(ctx)
+	d.Mutated(&api.Mutation{Time: ptypes.TimestampNow(), Sequence: 1, Type: api.MutationType_MUTATION_TYPE_DELETED, ParticipantId: "KFG-734"}, nil)
+	next(t, requests)
+	next(t, requests)
+	drained(t, d)
+}
+
+// TestSlowSubscriber will check that a subscriber which
+// does not respond does not hold up the other subscribers.
+func TestSlowSubscriber(t *testing.T) {
+	blocked := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-blocked
+	}))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(blocked) })
+	server, requests := newReceiver(t, func(int) int { return http.StatusOK })
+	d, err := New([]Subscription{{URL: slow.URL}, {URL: server.URL}}, "")
+	assert.NilError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+	for i := uint64(1); i <= 2; i++ {
+		d.Mutated(&api.Mutation{Time: ptypes.TimestampNow(), Sequence: i, Type: api.MutationType_MUTATION_TYPE_DELETED, ParticipantId: "KFG-734"}, nil)
+		event := &Event{}
+		assert.NilError(t, json.Unmarshal(next(t, requests).body, event))
+		assert.Equal(t, event.Sequence, i)
+	}
+	pending(t, d, 2)
+}
+
+// TestQueue will check that deliveries are persisted
+// until they are sent, and removed on erasure, and
+// that the files are not written by the store observer.
+func TestQueue(t *testing.T) {
+	dir := t.TempDir()
+	server, requests := newReceiver(t, func(int) int { return http.StatusOK })
+	subscriptions := []Subscription{{URL: server.URL}}
+	d, err := New(subscriptions, dir)
+	assert.NilError(t, err)
+	d.Mutated(&api.Mutation{Time: ptypes.TimestampNow(), Sequence: 1, Type: api.MutationType_MUTATION_TYPE_CREATED, ParticipantId: "KFG-734"}, nil)
+	d.Mutated(&api.Mutation{Time: ptypes.TimestampNow(), Sequence: 2, Type: api.MutationType_MUTATION_TYPE_MERGED, ParticipantId: "XYZ-999", MergedId: "ABC-123"}, nil)
+	assert.Equal(t, d.Pending(), 2)
+	files, err := ioutil.ReadDir(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 0)
+	assert.NilError(t, d.Close())
+
+	// the deliveries are loaded by a new dispatcher
+	d, err = New(subscriptions, dir)
+	assert.NilError(t, err)
+	assert.Equal(t, d.Pending(), 2)
+	d.Erased([]string{"ABC-123"})
+	assert.Equal(t, d.Pending(), 1)
+	files, err = ioutil.ReadDir(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 2)
+	assert.NilError(t, d.Close())
+	files, err = ioutil.ReadDir(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 1)
+
+	// sent deliveries are removed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+	assert.Equal(t, next(t, requests).header.Get(HeaderEvent), EventCreated)
+	drained(t, d)
+	cancel()
+	assert.NilError(t, d.Close())
+	files, err = ioutil.ReadDir(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 0)
+}